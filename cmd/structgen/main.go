@@ -0,0 +1,81 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Command structgen infers Go struct types from sample JSON or YAML documents
+// and writes their source to the standard output, e.g.
+//
+//	structgen -name Config -package config samples/*.json
+//
+// Samples are read from the files on the command line, or from the standard
+// input if there are none; files ending in .yaml or .yml are read as YAML,
+// all others (and the standard input, unless -yaml is given) as JSON.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dihedron/go-reflector/reflector/structgen"
+)
+
+func main() {
+	name := flag.String("name", "Root", "the name of the root type")
+	pkg := flag.String("package", "", "the package name of the generated source (none if empty)")
+	tags := flag.String("tags", "json", "a comma-separated list of struct tag keys to generate")
+	yaml := flag.Bool("yaml", false, "read the standard input as YAML")
+	flag.Parse()
+
+	generator := structgen.NewGenerator(*name)
+	generator.SetPackage(*pkg)
+	if *tags == "" {
+		generator.SetTags()
+	} else {
+		generator.SetTags(strings.Split(*tags, ",")...)
+	}
+
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		if *yaml {
+			err = generator.AddYAML(data)
+		} else {
+			err = generator.AddJSON(data)
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	for _, file := range flag.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fail(err)
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml":
+			err = generator.AddYAML(data)
+		default:
+			err = generator.AddJSON(data)
+		}
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", file, err))
+		}
+	}
+
+	source, err := generator.Generate()
+	if err != nil {
+		fail(err)
+	}
+	os.Stdout.Write(source)
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "structgen: %v\n", err)
+	os.Exit(1)
+}
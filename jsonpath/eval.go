@@ -0,0 +1,362 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// node is a node of the queried value, with its normalized path.
+type node struct {
+	location string
+	value    reflect.Value
+}
+
+// pointer identifies a pointer, map or slice being followed, to detect
+// cycles; slices are told apart by length too, as a slice and its prefixes
+// share their address.
+type pointer struct {
+	address uintptr
+	typ     reflect.Type
+	length  int
+}
+
+// evaluator evaluates queries against a root value.
+type evaluator struct {
+	root     reflect.Value
+	pointers map[pointer]bool
+}
+
+// query applies a sequence of segments to a node.
+func (ev *evaluator) query(segments []segment, start node) []node {
+	nodes := []node{start}
+	for _, s := range segments {
+		var next []node
+		for _, n := range nodes {
+			if s.descendant {
+				next = ev.descend(s.selectors, n, next)
+			} else {
+				next = ev.selectAll(s.selectors, n, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descend applies selectors to a node and all of its descendants, parents
+// before children; cycles through pointers, maps and slices are not
+// followed.
+func (ev *evaluator) descend(selectors []selector, n node, result []node) []node {
+	value := n.value
+	for ; value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface); value = value.Elem() {
+		if value.IsNil() {
+			return result
+		}
+		if !ev.enter(value) {
+			return result
+		}
+		defer ev.leave(value)
+	}
+	if value.IsValid() && (value.Kind() == reflect.Map || value.Kind() == reflect.Slice) {
+		if !ev.enter(value) {
+			return result
+		}
+		defer ev.leave(value)
+	}
+	result = ev.selectAll(selectors, n, result)
+	for _, child := range children(n) {
+		result = ev.descend(selectors, child, result)
+	}
+	return result
+}
+
+// identity returns the identity of a non-nil pointer or map, or of a
+// non-empty slice, and whether it has one.
+func identity(value reflect.Value) (pointer, bool) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if !value.IsNil() {
+			return pointer{address: value.Pointer(), typ: value.Type()}, true
+		}
+	case reflect.Slice:
+		if value.Len() > 0 {
+			return pointer{address: value.Pointer(), typ: value.Type(), length: value.Len()}, true
+		}
+	}
+	return pointer{}, false
+}
+
+// enter records that a pointer, map or slice is being followed, and returns
+// false if it already is, i.e. if it is part of a cycle.
+func (ev *evaluator) enter(value reflect.Value) bool {
+	p, ok := identity(value)
+	if !ok {
+		return true
+	}
+	if ev.pointers[p] {
+		return false
+	}
+	ev.pointers[p] = true
+	return true
+}
+
+// leave records that a pointer, map or slice is no longer being followed.
+func (ev *evaluator) leave(value reflect.Value) {
+	if p, ok := identity(value); ok {
+		delete(ev.pointers, p)
+	}
+}
+
+// selectAll applies selectors to a node.
+func (ev *evaluator) selectAll(selectors []selector, n node, result []node) []node {
+	for _, s := range selectors {
+		result = ev.selectOne(s, n, result)
+	}
+	return result
+}
+
+// selectOne applies a selector to a node.
+func (ev *evaluator) selectOne(s selector, n node, result []node) []node {
+	value := resolve(n.value)
+	switch s := s.(type) {
+	case nameSelector:
+		if kindOf(value) == objectKind {
+			if child, ok := lookup(value, s.name); ok {
+				result = append(result, node{location: n.location + "[" + quote(s.name) + "]", value: child})
+			}
+		}
+
+	case wildcardSelector:
+		result = append(result, children(n)...)
+
+	case indexSelector:
+		if kindOf(value) == arrayKind {
+			i := s.index
+			if i < 0 {
+				i += int64(value.Len())
+			}
+			if i >= 0 && i < int64(value.Len()) {
+				result = append(result, element(n, value, int(i)))
+			}
+		}
+
+	case sliceSelector:
+		if kindOf(value) == arrayKind {
+			for _, i := range s.indexes(int64(value.Len())) {
+				result = append(result, element(n, value, int(i)))
+			}
+		}
+
+	case filterSelector:
+		for _, child := range children(n) {
+			if ev.test(s.expression, child.value) {
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
+
+// indexes returns the indexes selected by a slice in an array of the given
+// length, as defined in RFC 9535.
+func (s sliceSelector) indexes(length int64) []int64 {
+	if s.step == 0 {
+		return nil
+	}
+	normalize := func(i int64) int64 {
+		if i < 0 {
+			return length + i
+		}
+		return i
+	}
+	clamp := func(i, min, max int64) int64 {
+		if i < min {
+			return min
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+	var indexes []int64
+	if s.step > 0 {
+		start, end := int64(0), length
+		if s.start != nil {
+			start = normalize(*s.start)
+		}
+		if s.end != nil {
+			end = normalize(*s.end)
+		}
+		for i := clamp(start, 0, length); i < clamp(end, 0, length); i += s.step {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+	start, end := length-1, -length-1
+	if s.start != nil {
+		start = normalize(*s.start)
+	}
+	if s.end != nil {
+		end = normalize(*s.end)
+	}
+	for i := clamp(start, -1, length-1); i > clamp(end, -1, length-1); i += s.step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// element returns the node of an element of a resolved array.
+func element(n node, array reflect.Value, i int) node {
+	return node{location: fmt.Sprintf("%s[%d]", n.location, i), value: exposed(array.Index(i))}
+}
+
+// children returns the elements of an array or the members of an object.
+func children(n node) []node {
+	value := resolve(n.value)
+	var result []node
+	switch kindOf(value) {
+	case arrayKind:
+		for i := 0; i < value.Len(); i++ {
+			result = append(result, element(n, value, i))
+		}
+	case objectKind:
+		for _, m := range members(value) {
+			result = append(result, node{location: n.location + "[" + quote(m.name) + "]", value: m.value})
+		}
+	}
+	return result
+}
+
+// quote returns a member name as a string literal in a normalized path.
+func quote(name string) string {
+	var buffer strings.Builder
+	buffer.WriteByte('\'')
+	for _, r := range name {
+		switch r {
+		case '\'':
+			buffer.WriteString(`\'`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('\'')
+	return buffer.String()
+}
+
+// test evaluates a logical expression with the given current node (@).
+func (ev *evaluator) test(expression logical, current reflect.Value) bool {
+	switch expression := expression.(type) {
+	case orExpression:
+		for _, operand := range expression.operands {
+			if ev.test(operand, current) {
+				return true
+			}
+		}
+		return false
+	case andExpression:
+		for _, operand := range expression.operands {
+			if !ev.test(operand, current) {
+				return false
+			}
+		}
+		return true
+	case notExpression:
+		return !ev.test(expression.operand, current)
+	case comparison:
+		return ev.compare(expression, current)
+	case test:
+		return ev.argument(expression.operand, logicalType, current).logical
+	case *filterQuery:
+		return ev.argument(expression, logicalType, current).logical
+	}
+	return false
+}
+
+// compare evaluates a comparison; absent values (from queries selecting
+// nothing) are only equal to each other.
+func (ev *evaluator) compare(c comparison, current reflect.Value) bool {
+	left := ev.argument(c.left, valueType, current)
+	right := ev.argument(c.right, valueType, current)
+	equals := func() bool {
+		if left.absent || right.absent {
+			return left.absent && right.absent
+		}
+		return equal(left.value, right.value)
+	}
+	lessThan := func(a, b argument) bool {
+		return !a.absent && !b.absent && less(a.value, b.value)
+	}
+	switch c.op {
+	case "==":
+		return equals()
+	case "!=":
+		return !equals()
+	case "<":
+		return lessThan(left, right)
+	case "<=":
+		return lessThan(left, right) || equals()
+	case ">":
+		return lessThan(right, left)
+	case ">=":
+		return lessThan(right, left) || equals()
+	}
+	return false
+}
+
+// argument evaluates an operand as the given type.
+func (ev *evaluator) argument(o operand, as resultType, current reflect.Value) argument {
+	var result argument
+	switch o := o.(type) {
+	case literal:
+		result = argument{value: o.value}
+	case *filterQuery:
+		start := node{location: "@", value: current}
+		if o.absolute {
+			start = node{location: "$", value: ev.root}
+		}
+		result = argument{nodes: ev.query(o.segments, start)}
+		switch as {
+		case valueType:
+			if len(result.nodes) == 1 {
+				return argument{value: result.nodes[0].value}
+			}
+			return argument{absent: true}
+		case logicalType:
+			result.logical = len(result.nodes) > 0
+		}
+		return result
+	case functionCall:
+		args := make([]argument, len(o.args))
+		for i, arg := range o.args {
+			args[i] = ev.argument(arg, o.function.params[i], current)
+		}
+		result = o.function.call(args)
+		if as == logicalType && o.function.result == nodesType {
+			result.logical = len(result.nodes) > 0
+		}
+	case logical:
+		result = argument{logical: ev.test(o, current)}
+	}
+	return result
+}
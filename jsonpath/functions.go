@@ -0,0 +1,143 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonpath
+
+import (
+	"reflect"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// resultType is the type of the parameters and results of functions.
+type resultType int8
+
+const (
+	valueType resultType = iota
+	logicalType
+	nodesType
+)
+
+// function is a function extension; its arguments are reflect.Values for
+// parameters of type value (the invalid Value being null, nothing being
+// passed as absent), []node for nodes and bool for logical ones, and so is
+// its result.
+type function struct {
+	params []resultType
+	result resultType
+	call   func(args []argument) argument
+}
+
+// argument is an argument or the result of a function.
+type argument struct {
+	value   reflect.Value
+	absent  bool
+	nodes   []node
+	logical bool
+}
+
+// functions are the functions defined by RFC 9535.
+var functions = map[string]*function{
+	"length": {
+		params: []resultType{valueType},
+		result: valueType,
+		call: func(args []argument) argument {
+			if args[0].absent {
+				return argument{absent: true}
+			}
+			value := resolve(args[0].value)
+			switch kindOf(value) {
+			case stringKind:
+				return argument{value: reflect.ValueOf(int64(utf8.RuneCountInString(value.String())))}
+			case arrayKind:
+				return argument{value: reflect.ValueOf(int64(value.Len()))}
+			case objectKind:
+				return argument{value: reflect.ValueOf(int64(len(members(value))))}
+			}
+			return argument{absent: true}
+		},
+	},
+	"count": {
+		params: []resultType{nodesType},
+		result: valueType,
+		call: func(args []argument) argument {
+			return argument{value: reflect.ValueOf(int64(len(args[0].nodes)))}
+		},
+	},
+	"match": {
+		params: []resultType{valueType, valueType},
+		result: logicalType,
+		call: func(args []argument) argument {
+			return argument{logical: matches(args, true)}
+		},
+	},
+	"search": {
+		params: []resultType{valueType, valueType},
+		result: logicalType,
+		call: func(args []argument) argument {
+			return argument{logical: matches(args, false)}
+		},
+	},
+	"value": {
+		params: []resultType{nodesType},
+		result: valueType,
+		call: func(args []argument) argument {
+			if len(args[0].nodes) != 1 {
+				return argument{absent: true}
+			}
+			return argument{value: args[0].nodes[0].value}
+		},
+	},
+}
+
+// maxExpressions is the maximum number of compiled regular expressions
+// cached; patterns can come from the queried values, so the cache is emptied
+// when full rather than left to grow.
+const maxExpressions = 1000
+
+var (
+	// expressions caches the compiled regular expressions.
+	expressions     = map[string]*regexp.Regexp{}
+	expressionsLock sync.Mutex
+)
+
+// compile returns a compiled regular expression, from the cache if there.
+func compile(source string) (*regexp.Regexp, error) {
+	expressionsLock.Lock()
+	defer expressionsLock.Unlock()
+	if re, ok := expressions[source]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(expressions) >= maxExpressions {
+		clear(expressions)
+	}
+	expressions[source] = re
+	return re, nil
+}
+
+// matches returns whether a string matches a regular expression, as a whole
+// or anywhere.
+func matches(args []argument, whole bool) bool {
+	if args[0].absent || args[1].absent {
+		return false
+	}
+	s, pattern := resolve(args[0].value), resolve(args[1].value)
+	if kindOf(s) != stringKind || kindOf(pattern) != stringKind {
+		return false
+	}
+	source := pattern.String()
+	if whole {
+		source = `\A(?:` + source + `)\z`
+	}
+	re, err := compile(source)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s.String())
+}
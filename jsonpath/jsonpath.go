@@ -0,0 +1,146 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package jsonpath implements JSONPath queries (RFC 9535) over Go values,
+// navigated through reflection as if they had been marshalled to JSON, but
+// without marshalling them: structs are objects whose members are their
+// exported fields, named and omitted according to their json tags (with the
+// fields of embedded structs promoted as encoding/json does), maps are
+// objects, slices and arrays are arrays, byte slices are base64 strings and
+// values implementing json.Marshaler or encoding.TextMarshaler are seen
+// through their JSON or text form. Pointers and interfaces are dereferenced
+// transparently, nil ones being null.
+//
+// All the RFC 9535 syntax is supported: name, wildcard, index, slice and
+// filter selectors, child and descendant segments, comparisons, logical
+// operators, existence tests and the length, count, match, search and value
+// functions; regular expressions are interpreted with the regexp package.
+// The members of objects are visited in the order of the fields of structs,
+// and in key order for maps.
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Path is a parsed JSONPath query.
+type Path struct {
+	query    string
+	segments []segment
+}
+
+// Node is a node selected by a query: its location within the queried
+// value, as a normalized path (e.g. $['items'][0]), and its value.
+type Node struct {
+	Location string
+	Value    interface{}
+}
+
+// Parse parses a JSONPath query; queries nested more than 1000 levels deep
+// are rejected, so that untrusted input cannot exhaust the stack.
+func Parse(query string) (*Path, error) {
+	p := &parser{query: query}
+	segments, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Path{query: query, segments: segments}, nil
+}
+
+// MustParse parses a JSONPath query, and panics if it is invalid.
+func MustParse(query string) *Path {
+	path, err := Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// String returns the source of the query.
+func (p *Path) String() string {
+	return p.query
+}
+
+// Query returns the nodes of a value selected by the query.
+func (p *Path) Query(object interface{}) []Node {
+	root := reflect.ValueOf(object)
+	if root.IsValid() && root.Kind() != reflect.Ptr {
+		// work on an addressable copy, so that methods with pointer
+		// receivers (e.g. MarshalJSON) can be called
+		copy := reflect.New(root.Type()).Elem()
+		copy.Set(root)
+		root = copy
+	}
+	ev := &evaluator{root: root, pointers: map[pointer]bool{}}
+	nodes := ev.query(p.segments, node{location: "$", value: root})
+	result := make([]Node, len(nodes))
+	for i, n := range nodes {
+		result[i] = Node{Location: n.location, Value: valueOf(n.value)}
+	}
+	return result
+}
+
+// Values returns the values of the nodes selected by the query.
+func (p *Path) Values(object interface{}) []interface{} {
+	nodes := p.Query(object)
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Value
+	}
+	return values
+}
+
+// Query parses a JSONPath query and returns the nodes of a value it selects.
+func Query(object interface{}, query string) ([]Node, error) {
+	path, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return path.Query(object), nil
+}
+
+// valueOf returns a reflected value as an interface{}, nil for nil.
+func valueOf(value reflect.Value) interface{} {
+	if !value.IsValid() || !value.CanInterface() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// Error is a syntax error in a query.
+type Error struct {
+	// Query is the source of the query.
+	Query string
+	// Column is the (1-based) column of the problem in the query, counted
+	// in characters.
+	Column int
+	// Message is the description of the problem.
+	Message string
+}
+
+// newError returns a new Error at a byte offset in the query.
+func newError(query string, pos int, format string, args ...interface{}) *Error {
+	if pos > len(query) {
+		pos = len(query)
+	}
+	return &Error{
+		Query:   query,
+		Column:  utf8.RuneCountInString(query[:pos]) + 1,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonpath: %s at column %d of %q", e.Message, e.Column, e.Query)
+}
+
+// Caret returns the query with a caret under the column of the problem, on
+// the following line.
+func (e *Error) Caret() string {
+	return e.Query + "\n" + strings.Repeat(" ", e.Column-1) + "^"
+}
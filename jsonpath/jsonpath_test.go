@@ -0,0 +1,231 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonpath
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBook struct {
+	Category string  `json:"category"`
+	Author   string  `json:"author"`
+	Title    string  `json:"title"`
+	ISBN     string  `json:"isbn,omitempty"`
+	Price    float64 `json:"price"`
+}
+
+type testBicycle struct {
+	Color string  `json:"color"`
+	Price float64 `json:"price"`
+}
+
+type testStore struct {
+	Book    []testBook  `json:"book"`
+	Bicycle testBicycle `json:"bicycle"`
+}
+
+type testBase struct {
+	ID      int    `json:"id"`
+	Ignored string `json:"-"`
+}
+
+type testDocument struct {
+	testBase
+	Store   testStore `json:"store"`
+	Data    []byte    `json:"data"`
+	Created time.Time `json:"created"`
+	Extra   map[string]interface{}
+	hidden  int
+}
+
+func TestQuery(t *testing.T) {
+	document := &testDocument{
+		testBase: testBase{ID: 7, Ignored: "x"},
+		Store: testStore{
+			Book: []testBook{
+				{Category: "reference", Author: "Nigel Rees", Title: "Sayings of the Century", Price: 8.95},
+				{Category: "fiction", Author: "Evelyn Waugh", Title: "Sword of Honour", Price: 12.99},
+				{Category: "fiction", Author: "Herman Melville", Title: "Moby Dick", ISBN: "0-553-21311-3", Price: 8.99},
+				{Category: "fiction", Author: "J. R. R. Tolkien", Title: "The Lord of the Rings", ISBN: "0-395-19395-8", Price: 22.99},
+			},
+			Bicycle: testBicycle{Color: "red", Price: 399},
+		},
+		Data:    []byte("hi"),
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Extra:   map[string]interface{}{"b": 2, "a": []int{1, 2, 3}},
+	}
+	tests := []struct {
+		query     string
+		locations []string
+	}{
+		{"$", []string{"$"}},
+		{"$.store.book[*].author", []string{"$['store']['book'][0]['author']", "$['store']['book'][1]['author']", "$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+		{"$..author", []string{"$['store']['book'][0]['author']", "$['store']['book'][1]['author']", "$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+		{"$.store.*", []string{"$['store']['book']", "$['store']['bicycle']"}},
+		{"$.store..price", []string{"$['store']['book'][0]['price']", "$['store']['book'][1]['price']", "$['store']['book'][2]['price']", "$['store']['book'][3]['price']", "$['store']['bicycle']['price']"}},
+		{"$..book[2]", []string{"$['store']['book'][2]"}},
+		{"$..book[-1]", []string{"$['store']['book'][3]"}},
+		{"$..book[:2]", []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+		{"$..book[::-2]", []string{"$['store']['book'][3]", "$['store']['book'][1]"}},
+		{"$..book[0,1]", []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+		{"$..book[?@.isbn]", []string{"$['store']['book'][2]", "$['store']['book'][3]"}},
+		{"$..book[?!@.isbn]", []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+		{"$..book[?@.price < 10]", []string{"$['store']['book'][0]", "$['store']['book'][2]"}},
+		{"$..book[?@.price < $.store.bicycle.price && @.category == 'reference']", []string{"$['store']['book'][0]"}},
+		{"$..book[?(@.price > 20 || @.price < 9)]", []string{"$['store']['book'][0]", "$['store']['book'][2]", "$['store']['book'][3]"}},
+		{"$..book[?match(@.author, 'J.*')]", []string{"$['store']['book'][3]"}},
+		{"$..book[?search(@.title, 'of')]", []string{"$['store']['book'][0]", "$['store']['book'][1]", "$['store']['book'][3]"}},
+		{"$..book[?length(@.title) == 9]", []string{"$['store']['book'][2]"}},
+		{"$.store[?count(@.*) == 2]", []string{"$['store']['bicycle']"}},
+		{"$..book[?value(@..isbn) == '0-553-21311-3']", []string{"$['store']['book'][2]"}},
+		{"$.Extra.*", []string{"$['Extra']['a']", "$['Extra']['b']"}},
+		{"$.Extra.a[?@ > 1]", []string{"$['Extra']['a'][1]", "$['Extra']['a'][2]"}},
+		{"$.id", []string{"$['id']"}},
+		{"$.Ignored", nil},
+		{"$.hidden", nil},
+		{"$.missing", nil},
+		{"$.store.book[10]", nil},
+	}
+	for _, test := range tests {
+		nodes, err := Query(document, test.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.query, err)
+			continue
+		}
+		var locations []string
+		for _, node := range nodes {
+			locations = append(locations, node.Location)
+		}
+		if !reflect.DeepEqual(locations, test.locations) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.locations, locations)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	document := &testDocument{
+		testBase: testBase{ID: 7},
+		Store: testStore{
+			Book: []testBook{
+				{Title: "Sayings of the Century", Price: 8.95},
+				{Title: "The Lord of the Rings", Price: 22.99},
+			},
+			Bicycle: testBicycle{Color: "red"},
+		},
+		Data:    []byte("hi"),
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	tests := []struct {
+		query  string
+		values []interface{}
+	}{
+		{"$.store.bicycle.color", []interface{}{"red"}},
+		{"$.id", []interface{}{7}},
+		{"$.store.book[?@.price > 20].title", []interface{}{"The Lord of the Rings"}},
+		{"$[?@ == 'aGk=']", []interface{}{[]byte("hi")}},
+		{"$[?@ == '2020-01-02T03:04:05Z']", []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}},
+	}
+	for _, test := range tests {
+		if values := MustParse(test.query).Values(document); !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.values, values)
+		}
+	}
+}
+
+func TestQueryCycles(t *testing.T) {
+	self := map[string]interface{}{"x": 1}
+	self["self"] = self
+
+	list := []interface{}{1, nil}
+	list[1] = list
+
+	type loop struct {
+		Name string
+		Next *loop
+	}
+	node := &loop{Name: "a"}
+	node.Next = node
+
+	tests := []struct {
+		object    interface{}
+		query     string
+		locations []string
+	}{
+		{self, "$..x", []string{"$['x']"}},
+		{list, "$..*", []string{"$[0]", "$[1]"}},
+		{node, "$..Name", []string{"$['Name']"}},
+	}
+	for _, test := range tests {
+		nodes, err := Query(test.object, test.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.query, err)
+		}
+		var locations []string
+		for _, node := range nodes {
+			locations = append(locations, node.Location)
+		}
+		if !reflect.DeepEqual(locations, test.locations) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.locations, locations)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{"", 1},
+		{"store", 1},
+		{"$.", 3},
+		{"$[", 3},
+		{"$[1", 4},
+		{"$['a'", 6},
+		{"$[?@.a ==]", 10},
+		{"$[?foo(@)]", 4},
+		{"$[?length(@, 1)]", 4},
+		{"$[?@.a == @.*]", 11},
+		{"$[01]", 3},
+		{"$ x", 2},
+		{"$[?" + strings.Repeat("(", 5000) + "@" + strings.Repeat(")", 5000) + "]", 1004},
+		{"$[?" + strings.Repeat("length(", 5000) + "@" + strings.Repeat(")", 5000) + "]", 0},
+		{"$" + strings.Repeat("[?@", 5000) + strings.Repeat("]", 5000), 0},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.query)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%.20q: expected an *Error, got %v", test.query, err)
+			continue
+		}
+		if test.column != 0 && e.Column != test.column {
+			t.Errorf("%.20q: expected column %d, got %d (%v)", test.query, test.column, e.Column, e)
+		}
+	}
+}
+
+func TestMatchPatternsFromValues(t *testing.T) {
+	type pair struct {
+		Text    string
+		Pattern string
+	}
+	var pairs []pair
+	for i := 0; i < 3*maxExpressions; i++ {
+		pairs = append(pairs, pair{Text: "a", Pattern: "a|" + strconv.Itoa(i)})
+	}
+	pairs = append(pairs, pair{Text: "(", Pattern: "("})
+	nodes := MustParse("$[?match(@.Text, @.Pattern)]").Query(pairs)
+	if len(nodes) != 3*maxExpressions {
+		t.Errorf("expected %d matches, got %d", 3*maxExpressions, len(nodes))
+	}
+	if len(expressions) > maxExpressions {
+		t.Errorf("expected at most %d cached expressions, got %d", maxExpressions, len(expressions))
+	}
+}
@@ -0,0 +1,744 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonpath
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// segment is a child segment ([...]) or, if descendant, a descendant
+// segment (..[...]) with its selectors.
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// selector is one of nameSelector, wildcardSelector, indexSelector,
+// sliceSelector and filterSelector.
+type selector interface{}
+
+type nameSelector struct {
+	name string
+}
+
+type wildcardSelector struct{}
+
+type indexSelector struct {
+	index int64
+}
+
+type sliceSelector struct {
+	start, end *int64
+	step       int64
+}
+
+type filterSelector struct {
+	expression logical
+}
+
+// logical is a logical expression in a filter: one of orExpression,
+// andExpression, notExpression, comparison and test.
+type logical interface{}
+
+type orExpression struct {
+	operands []logical
+}
+
+type andExpression struct {
+	operands []logical
+}
+
+type notExpression struct {
+	operand logical
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+// test is an existence test of a query, or the result of a function.
+type test struct {
+	operand operand
+}
+
+// operand is an operand of a comparison or a function: one of literal,
+// filterQuery and functionCall.
+type operand interface{}
+
+// literal is a JSON literal; null is the invalid Value.
+type literal struct {
+	value reflect.Value
+}
+
+// filterQuery is a query relative to the current node (@) or, if absolute,
+// to the root ($).
+type filterQuery struct {
+	absolute bool
+	segments []segment
+}
+
+type functionCall struct {
+	name     string
+	function *function
+	args     []operand
+}
+
+// singular returns whether a query selects at most one node, i.e. it is
+// made of name and index selectors only.
+func (q *filterQuery) singular() bool {
+	for _, s := range q.segments {
+		if s.descendant || len(s.selectors) != 1 {
+			return false
+		}
+		switch s.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// maxIndex is the largest integer allowed in indexes and slices, as in
+// I-JSON.
+const maxIndex = 1<<53 - 1
+
+// maxDepth is the maximum nesting depth of filter expressions and function
+// calls, which bounds the recursion of the parser and of the evaluation.
+const maxDepth = 1000
+
+// parser is a recursive descent parser for the grammar in RFC 9535.
+type parser struct {
+	query string
+	pos   int
+	depth int
+}
+
+// fail returns an error at a byte offset in the query.
+func (p *parser) fail(pos int, format string, args ...interface{}) error {
+	return newError(p.query, pos, format, args...)
+}
+
+// peek returns the next byte, 0 at the end of the query.
+func (p *parser) peek() byte {
+	if p.pos < len(p.query) {
+		return p.query[p.pos]
+	}
+	return 0
+}
+
+// consume consumes the given text if it comes next.
+func (p *parser) consume(text string) bool {
+	if strings.HasPrefix(p.query[p.pos:], text) {
+		p.pos += len(text)
+		return true
+	}
+	return false
+}
+
+// expect consumes the given text, or fails.
+func (p *parser) expect(text string) error {
+	if !p.consume(text) {
+		return p.fail(p.pos, "expected %q, found %s", text, p.found())
+	}
+	return nil
+}
+
+// found describes what comes next, for error messages.
+func (p *parser) found() string {
+	if p.pos >= len(p.query) {
+		return "end of query"
+	}
+	r, _ := utf8.DecodeRuneInString(p.query[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+// nest counts a level of nesting, and fails if the query is nested too
+// deeply; the caller restores the depth when done with the level.
+func (p *parser) nest() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.fail(p.pos, "query nested more than %d levels deep", maxDepth)
+	}
+	return nil
+}
+
+// space skips blank space.
+func (p *parser) space() {
+	for p.pos < len(p.query) && strings.IndexByte(" \t\n\r", p.query[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// parse parses a whole query.
+func (p *parser) parse() ([]segment, error) {
+	if !p.consume("$") {
+		return nil, p.fail(p.pos, "query must start with $")
+	}
+	segments, err := p.segments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.query) {
+		return nil, p.fail(p.pos, "unexpected %s", p.found())
+	}
+	return segments, nil
+}
+
+// segments parses a (possibly empty) sequence of segments.
+func (p *parser) segments() ([]segment, error) {
+	var segments []segment
+	for {
+		start := p.pos
+		p.space()
+		if c := p.peek(); c != '.' && c != '[' {
+			p.pos = start
+			return segments, nil
+		}
+		segment, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+}
+
+func (p *parser) segment() (segment, error) {
+	var s segment
+	switch {
+	case p.consume(".."):
+		s.descendant = true
+		if p.peek() == '[' {
+			selectors, err := p.bracketed()
+			s.selectors = selectors
+			return s, err
+		}
+	case p.consume("."):
+	default:
+		selectors, err := p.bracketed()
+		s.selectors = selectors
+		return s, err
+	}
+	if p.consume("*") {
+		s.selectors = []selector{wildcardSelector{}}
+		return s, nil
+	}
+	name, ok := p.shorthand()
+	if !ok {
+		return s, p.fail(p.pos, "expected member name or *, found %s", p.found())
+	}
+	s.selectors = []selector{nameSelector{name: name}}
+	return s, nil
+}
+
+// shorthand parses a member name after a dot.
+func (p *parser) shorthand() (string, bool) {
+	start := p.pos
+	for p.pos < len(p.query) {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r >= 0x80 ||
+			p.pos > start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	return p.query[start:p.pos], p.pos > start
+}
+
+// bracketed parses a comma-separated list of selectors in brackets.
+func (p *parser) bracketed() ([]selector, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var selectors []selector
+	for {
+		p.space()
+		s, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+		p.space()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) selector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.string()
+		return nameSelector{name: name}, err
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '?':
+		p.pos++
+		p.space()
+		expression, err := p.or()
+		return filterSelector{expression: expression}, err
+	case c == '-' || c == ':' || c >= '0' && c <= '9':
+		start, err := p.optionalInteger()
+		if err != nil {
+			return nil, err
+		}
+		p.space()
+		if !p.consume(":") {
+			if start == nil {
+				return nil, p.fail(p.pos, "expected index, found %s", p.found())
+			}
+			return indexSelector{index: *start}, nil
+		}
+		s := sliceSelector{start: start, step: 1}
+		p.space()
+		if s.end, err = p.optionalInteger(); err != nil {
+			return nil, err
+		}
+		p.space()
+		if p.consume(":") {
+			p.space()
+			step, err := p.optionalInteger()
+			if err != nil {
+				return nil, err
+			}
+			if step != nil {
+				s.step = *step
+			}
+		}
+		return s, nil
+	}
+	return nil, p.fail(p.pos, "expected selector, found %s", p.found())
+}
+
+// optionalInteger parses an integer without leading zeros, if there is one.
+func (p *parser) optionalInteger() (*int64, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.query) && p.query[p.pos] >= '0' && p.query[p.pos] <= '9' {
+		p.pos++
+	}
+	switch {
+	case p.pos == digits && digits == start:
+		return nil, nil
+	case p.pos == digits:
+		return nil, p.fail(start, "expected digits after -")
+	case p.query[digits] == '0' && (p.pos-digits > 1 || digits > start):
+		return nil, p.fail(start, "invalid integer %s", p.query[start:p.pos])
+	}
+	n, err := strconv.ParseInt(p.query[start:p.pos], 10, 64)
+	if err != nil || n > maxIndex || n < -maxIndex {
+		return nil, p.fail(start, "integer %s out of range", p.query[start:p.pos])
+	}
+	return &n, nil
+}
+
+// string parses a single- or double-quoted string literal.
+func (p *parser) string() (string, error) {
+	start := p.pos
+	quote := p.query[p.pos]
+	p.pos++
+	var buffer strings.Builder
+	for {
+		if p.pos >= len(p.query) {
+			return "", p.fail(start, "unterminated string")
+		}
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		switch {
+		case r == rune(quote):
+			p.pos++
+			return buffer.String(), nil
+		case r < 0x20:
+			return "", p.fail(p.pos, "control character in string")
+		case r != '\\':
+			buffer.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		escape := p.pos
+		p.pos++
+		switch c := p.peek(); c {
+		case 'b':
+			buffer.WriteByte('\b')
+		case 'f':
+			buffer.WriteByte('\f')
+		case 'n':
+			buffer.WriteByte('\n')
+		case 'r':
+			buffer.WriteByte('\r')
+		case 't':
+			buffer.WriteByte('\t')
+		case '/', '\\', quote:
+			buffer.WriteByte(c)
+		case 'u':
+			r, ok := p.unicode()
+			if !ok {
+				return "", p.fail(escape, "invalid unicode escape")
+			}
+			if utf16.IsSurrogate(r) {
+				if !p.consume("\\") || p.peek() != 'u' {
+					return "", p.fail(escape, "unpaired surrogate")
+				}
+				low, ok := p.unicode()
+				if r = utf16.DecodeRune(r, low); !ok || r == utf8.RuneError {
+					return "", p.fail(escape, "invalid surrogate pair")
+				}
+			}
+			buffer.WriteRune(r)
+			continue
+		default:
+			return "", p.fail(escape, "invalid escape")
+		}
+		p.pos++
+	}
+}
+
+// unicode parses the 'u' and four hexadecimal digits of an escape.
+func (p *parser) unicode() (rune, bool) {
+	if p.pos+5 > len(p.query) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(p.query[p.pos+1:p.pos+5], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += 5
+	return rune(n), true
+}
+
+// or parses a logical expression.
+func (p *parser) or() (logical, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	var operands []logical
+	for {
+		operand, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		start := p.pos
+		p.space()
+		if !p.consume("||") {
+			p.pos = start
+			break
+		}
+		p.space()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return orExpression{operands: operands}, nil
+}
+
+func (p *parser) and() (logical, error) {
+	var operands []logical
+	for {
+		operand, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		start := p.pos
+		p.space()
+		if !p.consume("&&") {
+			p.pos = start
+			break
+		}
+		p.space()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return andExpression{operands: operands}, nil
+}
+
+// basic parses a parenthesised expression, a negation, a comparison or a
+// test.
+func (p *parser) basic() (logical, error) {
+	if p.consume("!") {
+		p.space()
+		if p.peek() == '(' {
+			operand, err := p.parenthesised()
+			return notExpression{operand: operand}, err
+		}
+		start := p.pos
+		operand, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		t, err := p.test(start, operand)
+		return notExpression{operand: t}, err
+	}
+	if p.peek() == '(' {
+		return p.parenthesised()
+	}
+
+	start := p.pos
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos
+	p.space()
+	op := ""
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		p.pos = end
+		return p.test(start, left)
+	}
+	if err := p.comparable(start, left); err != nil {
+		return nil, err
+	}
+	p.space()
+	right := p.pos
+	c := comparison{op: op, left: left}
+	if c.right, err = p.operand(); err != nil {
+		return nil, err
+	}
+	return c, p.comparable(right, c.right)
+}
+
+func (p *parser) parenthesised() (logical, error) {
+	p.pos++
+	p.space()
+	expression, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	return expression, p.expect(")")
+}
+
+// test checks that an operand can be used as a test: a query, or a function
+// returning a logical value or nodes.
+func (p *parser) test(start int, o operand) (logical, error) {
+	switch o := o.(type) {
+	case *filterQuery:
+		return test{operand: o}, nil
+	case functionCall:
+		if o.function.result != logicalType && o.function.result != nodesType {
+			return nil, p.fail(start, "result of %s() must be compared", o.name)
+		}
+		return test{operand: o}, nil
+	}
+	return nil, p.fail(start, "literal must be compared")
+}
+
+// comparable checks that an operand can be compared: a literal, a singular
+// query or a function returning a value.
+func (p *parser) comparable(start int, o operand) error {
+	switch o := o.(type) {
+	case *filterQuery:
+		if !o.singular() {
+			return p.fail(start, "query in comparison must be singular")
+		}
+	case functionCall:
+		if o.function.result != valueType {
+			return p.fail(start, "result of %s() cannot be compared", o.name)
+		}
+	}
+	return nil
+}
+
+// operand parses a literal, a query or a function call.
+func (p *parser) operand() (operand, error) {
+	start := p.pos
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.segments()
+		return &filterQuery{absolute: c == '$', segments: segments}, err
+	case c == '\'' || c == '"':
+		s, err := p.string()
+		return literal{value: reflect.ValueOf(s)}, err
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case c >= 'a' && c <= 'z':
+		for p.pos < len(p.query) && (p.query[p.pos] >= 'a' && p.query[p.pos] <= 'z' ||
+			p.query[p.pos] >= '0' && p.query[p.pos] <= '9' || p.query[p.pos] == '_') {
+			p.pos++
+		}
+		name := p.query[start:p.pos]
+		switch name {
+		case "true", "false":
+			return literal{value: reflect.ValueOf(name == "true")}, nil
+		case "null":
+			return literal{}, nil
+		}
+		if p.peek() != '(' {
+			return nil, p.fail(start, "unexpected %s", name)
+		}
+		return p.call(start, name)
+	}
+	return nil, p.fail(start, "expected operand, found %s", p.found())
+}
+
+// number parses a number literal, as an int64 if it is an integer.
+func (p *parser) number() (operand, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.query) && p.query[p.pos] >= '0' && p.query[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == digits || p.query[digits] == '0' && p.pos-digits > 1 {
+		return nil, p.fail(start, "invalid number")
+	}
+	float := false
+	if p.consume(".") {
+		float = true
+		fraction := p.pos
+		for p.pos < len(p.query) && p.query[p.pos] >= '0' && p.query[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == fraction {
+			return nil, p.fail(start, "invalid number")
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		float = true
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		exponent := p.pos
+		for p.pos < len(p.query) && p.query[p.pos] >= '0' && p.query[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == exponent {
+			return nil, p.fail(start, "invalid number")
+		}
+	}
+	text := p.query[start:p.pos]
+	if !float {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return literal{value: reflect.ValueOf(n)}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, p.fail(start, "invalid number %s", text)
+	}
+	return literal{value: reflect.ValueOf(f)}, nil
+}
+
+// call parses the arguments of a function call, checking their types.
+func (p *parser) call(start int, name string) (operand, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	f, ok := functions[name]
+	if !ok {
+		return nil, p.fail(start, "unknown function %s()", name)
+	}
+	c := functionCall{name: name, function: f}
+	p.pos++
+	p.space()
+	if !p.consume(")") {
+		for {
+			argument := p.pos
+			o, err := p.argument()
+			if err != nil {
+				return nil, err
+			}
+			if len(c.args) < len(f.params) {
+				if err := p.argumentType(argument, name, o, f.params[len(c.args)]); err != nil {
+					return nil, err
+				}
+			}
+			c.args = append(c.args, o)
+			p.space()
+			if p.consume(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			p.space()
+		}
+	}
+	if len(c.args) != len(f.params) {
+		return nil, p.fail(start, "%s() takes %d arguments, not %d", name, len(f.params), len(c.args))
+	}
+	return c, nil
+}
+
+// argument parses an argument of a function call: an operand, or a logical
+// expression.
+func (p *parser) argument() (operand, error) {
+	start := p.pos
+	if c := p.peek(); c != '!' && c != '(' {
+		o, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		end := p.pos
+		p.space()
+		if c := p.peek(); c != '=' && c != '!' && c != '<' && c != '>' && c != '&' && c != '|' {
+			p.pos = end
+			return o, nil
+		}
+	}
+	p.pos = start
+	return p.or()
+}
+
+// argumentType checks that an argument matches the type of a parameter.
+func (p *parser) argumentType(start int, name string, o operand, param resultType) error {
+	switch param {
+	case valueType:
+		switch o := o.(type) {
+		case literal:
+			return nil
+		case *filterQuery:
+			if o.singular() {
+				return nil
+			}
+		case functionCall:
+			if o.function.result == valueType {
+				return nil
+			}
+		}
+	case nodesType:
+		switch o := o.(type) {
+		case *filterQuery:
+			return nil
+		case functionCall:
+			if o.function.result == nodesType {
+				return nil
+			}
+		}
+	case logicalType:
+		switch o := o.(type) {
+		case literal:
+		case functionCall:
+			if o.function.result != valueType {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return p.fail(start, "invalid argument for %s()", name)
+}
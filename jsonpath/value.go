@@ -0,0 +1,377 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonpath
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	"github.com/dihedron/go-reflector/reflector"
+)
+
+// jsonKind is the kind of a value in the JSON data model.
+type jsonKind int8
+
+const (
+	nullKind jsonKind = iota
+	boolKind
+	numberKind
+	stringKind
+	arrayKind
+	objectKind
+	// otherKind is for values that have no JSON form (e.g. channels).
+	otherKind
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// resolve returns the value as seen in the JSON data model: pointers and
+// interfaces are dereferenced, marshalers are replaced by their JSON or text
+// form and byte slices by their base64 encoding; null is the invalid Value.
+func resolve(value reflect.Value) reflect.Value {
+	for value.IsValid() {
+		switch value.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			if value.IsNil() {
+				return reflect.Value{}
+			}
+		}
+		if value.Kind() != reflect.Interface {
+			if resolved, ok := marshal(value); ok {
+				return resolved
+			}
+		}
+		switch value.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			value = exposed(value.Elem())
+			continue
+		case reflect.Slice:
+			if value.Type().Elem().Kind() == reflect.Uint8 {
+				return reflect.ValueOf(base64.StdEncoding.EncodeToString(value.Bytes()))
+			}
+		}
+		return value
+	}
+	return value
+}
+
+// marshal returns the JSON or text form of a value implementing
+// json.Marshaler or encoding.TextMarshaler.
+func marshal(value reflect.Value) (reflect.Value, bool) {
+	if !value.CanInterface() {
+		return reflect.Value{}, false
+	}
+	var receiver reflect.Value
+	switch {
+	case value.Type().Implements(jsonMarshalerType) || value.Type().Implements(textMarshalerType):
+		receiver = value
+	case value.CanAddr() && (reflect.PtrTo(value.Type()).Implements(jsonMarshalerType) ||
+		reflect.PtrTo(value.Type()).Implements(textMarshalerType)):
+		receiver = value.Addr()
+	default:
+		return reflect.Value{}, false
+	}
+	switch marshaler := receiver.Interface().(type) {
+	case json.Marshaler:
+		data, err := marshaler.MarshalJSON()
+		if err != nil {
+			return reflect.Value{}, true
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return reflect.Value{}, true
+		}
+		return reflect.ValueOf(decoded), true
+	case encoding.TextMarshaler:
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return reflect.Value{}, true
+		}
+		return reflect.ValueOf(string(text)), true
+	}
+	return reflect.Value{}, false
+}
+
+// exposed returns a value that can be read through the reflect API even if
+// it was reached through unexported embedded structs.
+func exposed(value reflect.Value) reflect.Value {
+	if !value.CanInterface() && value.CanAddr() {
+		return reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+	}
+	return value
+}
+
+// kindOf returns the JSON kind of a resolved value.
+func kindOf(value reflect.Value) jsonKind {
+	if !value.IsValid() {
+		return nullKind
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return boolKind
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return numberKind
+	case reflect.String:
+		return stringKind
+	case reflect.Slice, reflect.Array:
+		return arrayKind
+	case reflect.Struct:
+		return objectKind
+	case reflect.Map:
+		switch value.Type().Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return objectKind
+		}
+		if value.Type().Key().Implements(textMarshalerType) {
+			return objectKind
+		}
+	}
+	return otherKind
+}
+
+// member is a member of an object.
+type member struct {
+	name  string
+	value reflect.Value
+}
+
+// members returns the members of a resolved object: the fields of a struct
+// in order, or the entries of a map sorted by key.
+func members(object reflect.Value) []member {
+	var result []member
+	switch object.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(object.Type()) {
+			if value, ok := fieldValue(f, object); ok {
+				result = append(result, member{name: f.Name, value: value})
+			}
+		}
+	case reflect.Map:
+		for _, key := range object.MapKeys() {
+			if name, ok := keyName(key); ok {
+				result = append(result, member{name: name, value: object.MapIndex(key)})
+			}
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	}
+	return result
+}
+
+// lookup returns the member of a resolved object with the given name.
+func lookup(object reflect.Value, name string) (reflect.Value, bool) {
+	switch object.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(object.Type()) {
+			if f.Name == name {
+				return fieldValue(f, object)
+			}
+		}
+	case reflect.Map:
+		if object.Type().Key().Kind() == reflect.String {
+			value := object.MapIndex(reflect.ValueOf(name).Convert(object.Type().Key()))
+			return value, value.IsValid()
+		}
+		for _, m := range members(object) {
+			if m.name == name {
+				return m.value, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// keyName returns the name of a map key, as encoding/json does.
+func keyName(key reflect.Value) (string, bool) {
+	if key.Kind() == reflect.String {
+		return key.String(), true
+	}
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err == nil
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), true
+	}
+	return "", false
+}
+
+// fieldValue returns the value of a field in a struct, unless it is omitted
+// or it is within a nil embedded struct.
+func fieldValue(f reflector.JSONField, object reflect.Value) (reflect.Value, bool) {
+	value := object
+	for i, index := range f.Index {
+		if i > 0 {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return reflect.Value{}, false
+				}
+				value = value.Elem()
+			}
+		}
+		value = exposed(value.Field(index))
+	}
+	if f.OmitEmpty && empty(value) || f.OmitZero && value.IsZero() {
+		return reflect.Value{}, false
+	}
+	return value, true
+}
+
+// empty returns whether a value is empty, as for the omitempty option.
+func empty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return value.IsZero()
+	}
+	return false
+}
+
+// fieldCache caches the fields of struct types.
+var fieldCache sync.Map
+
+// fields returns the fields of a struct type as object members, following
+// the rules of encoding/json (see reflector.JSONFields) and leaving out
+// those that have no JSON form.
+func fields(typ reflect.Type) []reflector.JSONField {
+	if cached, ok := fieldCache.Load(typ); ok {
+		return cached.([]reflector.JSONField)
+	}
+	var result []reflector.JSONField
+	for _, f := range reflector.JSONFields(typ) {
+		t := typ.FieldByIndex(f.Index).Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			// these have no JSON form
+			continue
+		}
+		result = append(result, f)
+	}
+	fieldCache.Store(typ, result)
+	return result
+}
+
+// number returns a resolved number as an int64 or a float64.
+func number(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() <= 1<<63-1 {
+			return int64(value.Uint())
+		}
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater
+// than b.
+func compareNumbers(a, b reflect.Value) int {
+	x, y := number(a), number(b)
+	if i, ok := x.(int64); ok {
+		if j, ok := y.(int64); ok {
+			switch {
+			case i < j:
+				return -1
+			case i > j:
+				return 1
+			}
+			return 0
+		}
+	}
+	f, g := toFloat(x), toFloat(y)
+	switch {
+	case f < g:
+		return -1
+	case f > g:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// equal returns whether two values are equal in the JSON data model.
+func equal(a, b reflect.Value) bool {
+	a, b = resolve(a), resolve(b)
+	kind := kindOf(a)
+	if kindOf(b) != kind {
+		return false
+	}
+	switch kind {
+	case nullKind:
+		return true
+	case boolKind:
+		return a.Bool() == b.Bool()
+	case numberKind:
+		return compareNumbers(a, b) == 0
+	case stringKind:
+		return a.String() == b.String()
+	case arrayKind:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case objectKind:
+		am, bm := members(a), members(b)
+		if len(am) != len(bm) {
+			return false
+		}
+		for _, m := range am {
+			value, ok := lookup(b, m.name)
+			if !ok || !equal(m.value, value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// less returns whether a is less than b: only numbers and strings can be
+// ordered.
+func less(a, b reflect.Value) bool {
+	a, b = resolve(a), resolve(b)
+	switch {
+	case kindOf(a) == numberKind && kindOf(b) == numberKind:
+		return compareNumbers(a, b) < 0
+	case kindOf(a) == stringKind && kindOf(b) == stringKind:
+		return a.String() < b.String()
+	}
+	return false
+}
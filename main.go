@@ -91,4 +91,8 @@ func main() {
 	reflector.Visit("", "c", c, nil, observer)
 
 	fmt.Printf("buffer is:\n%s\n", observer)
+
+	tree := reflector.NewTreeObserver(os.Stdout, true)
+	reflector.Visit("", "o", o, nil, tree)
+	tree.Flush()
 }
@@ -0,0 +1,216 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is a node in the abstract syntax tree of an expression; Pos is the
+// byte offset of the node in the expression, String returns its canonical
+// source.
+type Node interface {
+	Pos() int
+	String() string
+}
+
+// Literal is a constant: nil, a bool, an int64, a float64 or a string.
+type Literal struct {
+	Offset int
+	Value  interface{}
+}
+
+// Property is the name of a property of the current object (#this).
+type Property struct {
+	Offset int
+	Name   string
+}
+
+// Variable is a variable in the context, e.g. #name; #root and #this are
+// the root object and the current object.
+type Variable struct {
+	Offset int
+	Name   string
+}
+
+// Member is the access to a property of an object, e.g. a.b.
+type Member struct {
+	Offset int
+	Target Node
+	Name   string
+}
+
+// Index is the access to an element of a list, an entry of a map or a
+// property of an object by index, key or name, e.g. a[1] or a["key"].
+type Index struct {
+	Offset int
+	Target Node
+	Index  Node
+}
+
+// Call is the call of a method of an object, or of the current object (or a
+// function of the context, if the current object has no such method) if
+// Target is nil, e.g. a.f(1, 2) or f(1, 2).
+type Call struct {
+	Offset int
+	Target Node
+	Name   string
+	Args   []Node
+}
+
+// Function is the call of a function of the context, or of a function held
+// in a variable, e.g. #f(1, 2).
+type Function struct {
+	Offset int
+	Name   string
+	Args   []Node
+}
+
+// Unary is the application of a unary operator (!, - or +).
+type Unary struct {
+	Offset  int
+	Op      string
+	Operand Node
+}
+
+// Binary is the application of a binary operator (arithmetic, comparison
+// or boolean).
+type Binary struct {
+	Offset int
+	Op     string
+	Left   Node
+	Right  Node
+}
+
+// Conditional is the ternary operator, e.g. a ? b : c.
+type Conditional struct {
+	Offset    int
+	Condition Node
+	Then      Node
+	Else      Node
+}
+
+// List is a list literal, e.g. {1, 2, 3}.
+type List struct {
+	Offset   int
+	Elements []Node
+}
+
+// Selection is the selection of the elements of a collection that satisfy a
+// condition, evaluated with each element as #this: all of them (Mode "?"),
+// the first (Mode "^") or the last (Mode "$"), e.g. a.{? #this > 1}.
+type Selection struct {
+	Offset    int
+	Target    Node
+	Mode      string
+	Condition Node
+}
+
+// Projection is the evaluation of an expression on each element of a
+// collection, as #this, e.g. a.{name}.
+type Projection struct {
+	Offset     int
+	Target     Node
+	Expression Node
+}
+
+// Assignment is the assignment of a value to a property, an entry or an
+// element, e.g. a.b = 1.
+type Assignment struct {
+	Offset int
+	Target Node
+	Value  Node
+}
+
+func (n *Literal) Pos() int     { return n.Offset }
+func (n *Property) Pos() int    { return n.Offset }
+func (n *Variable) Pos() int    { return n.Offset }
+func (n *Member) Pos() int      { return n.Offset }
+func (n *Index) Pos() int       { return n.Offset }
+func (n *Call) Pos() int        { return n.Offset }
+func (n *Function) Pos() int    { return n.Offset }
+func (n *Unary) Pos() int       { return n.Offset }
+func (n *Binary) Pos() int      { return n.Offset }
+func (n *Conditional) Pos() int { return n.Offset }
+func (n *List) Pos() int        { return n.Offset }
+func (n *Selection) Pos() int   { return n.Offset }
+func (n *Projection) Pos() int  { return n.Offset }
+func (n *Assignment) Pos() int  { return n.Offset }
+
+func (n *Literal) String() string {
+	switch value := n.Value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(value)
+	case float64:
+		s := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprint(n.Value)
+}
+
+func (n *Property) String() string { return n.Name }
+
+func (n *Variable) String() string { return "#" + n.Name }
+
+func (n *Member) String() string { return n.Target.String() + "." + n.Name }
+
+func (n *Index) String() string { return n.Target.String() + "[" + n.Index.String() + "]" }
+
+func (n *Call) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	call := n.Name + "(" + strings.Join(args, ", ") + ")"
+	if n.Target != nil {
+		return n.Target.String() + "." + call
+	}
+	return call
+}
+
+func (n *Function) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return "#" + n.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *Unary) String() string { return n.Op + n.Operand.String() }
+
+func (n *Binary) String() string {
+	return "(" + n.Left.String() + " " + n.Op + " " + n.Right.String() + ")"
+}
+
+func (n *Conditional) String() string {
+	return "(" + n.Condition.String() + " ? " + n.Then.String() + " : " + n.Else.String() + ")"
+}
+
+func (n *List) String() string {
+	elements := make([]string, len(n.Elements))
+	for i, element := range n.Elements {
+		elements[i] = element.String()
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+func (n *Selection) String() string {
+	return n.Target.String() + ".{" + n.Mode + " " + n.Condition.String() + "}"
+}
+
+func (n *Projection) String() string {
+	return n.Target.String() + ".{" + n.Expression.String() + "}"
+}
+
+func (n *Assignment) String() string {
+	return "(" + n.Target.String() + " = " + n.Value.String() + ")"
+}
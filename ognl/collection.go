@@ -0,0 +1,109 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"reflect"
+
+	"github.com/dihedron/go-reflector/reflector"
+)
+
+// Entry is an entry of a map, as seen by selections and projections.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// elements returns the elements of a slice or an array, or the entries of a
+// map in key order (see reflector.SortedKeys), and the type of the slice to collect them into.
+func (ev *evaluator) elements(node Node, target reflect.Value) ([]reflect.Value, reflect.Type, error) {
+	collection := indirect(target)
+	if !collection.IsValid() {
+		return nil, nil, ev.fail(node, "cannot iterate over nil")
+	}
+	if err := ev.checkType(node, collection.Type()); err != nil {
+		return nil, nil, err
+	}
+	switch collection.Kind() {
+	case reflect.Slice, reflect.Array:
+		elements := make([]reflect.Value, collection.Len())
+		for i := range elements {
+			elements[i] = collection.Index(i)
+			if err := ev.checkResult(node, elements[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+		return elements, reflect.SliceOf(collection.Type().Elem()), nil
+	case reflect.Map:
+		if err := ev.allocate(node, collection.Len()); err != nil {
+			return nil, nil, err
+		}
+		keys := reflector.SortedKeys(collection)
+		elements := make([]reflect.Value, len(keys))
+		for i, key := range keys {
+			if err := ev.checkResult(node, key); err != nil {
+				return nil, nil, err
+			}
+			if err := ev.checkResult(node, collection.MapIndex(key)); err != nil {
+				return nil, nil, err
+			}
+			elements[i] = reflect.ValueOf(Entry{Key: result(key), Value: result(collection.MapIndex(key))})
+		}
+		return elements, reflect.TypeOf([]Entry{}), nil
+	}
+	return nil, nil, ev.fail(node, "cannot iterate over %s", collection.Type())
+}
+
+// selection returns the elements of a collection that satisfy a condition,
+// or only the first or last of them.
+func (ev *evaluator) selection(node *Selection, target reflect.Value, condition compiled) (reflect.Value, error) {
+	elements, typ, err := ev.elements(node, target)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if node.Mode == "$" {
+		for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+			elements[i], elements[j] = elements[j], elements[i]
+		}
+	}
+	selected := reflect.MakeSlice(typ, 0, 0)
+	for _, element := range elements {
+		satisfied, err := condition(ev, element)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if truth(satisfied) {
+			if err := ev.allocate(node, 1); err != nil {
+				return reflect.Value{}, err
+			}
+			selected = reflect.Append(selected, element)
+			if node.Mode != "?" {
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+// projection returns the values of an expression on the elements of a
+// collection.
+func (ev *evaluator) projection(node *Projection, target reflect.Value, expression compiled) (reflect.Value, error) {
+	elements, _, err := ev.elements(node, target)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := ev.allocate(node, len(elements)); err != nil {
+		return reflect.Value{}, err
+	}
+	projected := make([]interface{}, len(elements))
+	for i, element := range elements {
+		value, err := expression(ev, element)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		projected[i] = result(value)
+	}
+	return reflect.ValueOf(projected), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"reflect"
+	"testing"
+)
+
+type collectionItem struct {
+	Name  string
+	Price float64
+}
+
+type collectionShop struct {
+	Items  []collectionItem
+	Fixed  [3]int
+	Stock  map[string]int
+	Mixed  map[interface{}]string
+	Nested [][]int
+	None   []int
+}
+
+func TestSelection(t *testing.T) {
+	shop := &collectionShop{
+		Items: []collectionItem{
+			{Name: "pen", Price: 2},
+			{Name: "book", Price: 15},
+			{Name: "lamp", Price: 40},
+		},
+		Fixed: [3]int{3, 1, 2},
+		Stock: map[string]int{"pen": 10, "book": 0, "lamp": 3},
+	}
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		{"Items.{? Price > 10}", []collectionItem{{Name: "book", Price: 15}, {Name: "lamp", Price: 40}}},
+		{"Items.{^ Price > 10}", []collectionItem{{Name: "book", Price: 15}}},
+		{"Items.{$ Price > 10}", []collectionItem{{Name: "lamp", Price: 40}}},
+		{"Items.{? Price > 100}", []collectionItem{}},
+		{"Items.{^ Price > 100}", []collectionItem{}},
+		{"Fixed.{? #this >= 2}", []int{3, 2}},
+		{"Stock.{? Value > 0}", []Entry{{Key: "lamp", Value: 3}, {Key: "pen", Value: 10}}},
+		{"Stock.{^ Key != 'book'}", []Entry{{Key: "lamp", Value: 3}}},
+		{"None.{? true}", []int{}},
+		{"{1, 2, 3}.{? #this % 2 == 1}", []interface{}{int64(1), int64(3)}},
+		{"Items.{? Price > #min}", []collectionItem{{Name: "lamp", Price: 40}}},
+		{"Items.{? #root.Items.{? Price > 1}[0].Name == 'pen'}.{^ true}", []collectionItem{{Name: "pen", Price: 2}}},
+	}
+	variables := NewContext()
+	variables.Set("min", 20)
+	for _, test := range tests {
+		value, err := MustParse(test.source).Eval(shop, variables)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.source, test.expected, value)
+		}
+	}
+}
+
+func TestProjection(t *testing.T) {
+	shop := &collectionShop{
+		Items: []collectionItem{
+			{Name: "pen", Price: 2},
+			{Name: "book", Price: 15},
+			{Name: "lamp", Price: 40},
+		},
+		Fixed:  [3]int{3, 1, 2},
+		Stock:  map[string]int{"pen": 10, "book": 0, "lamp": 3},
+		Mixed:  map[interface{}]string{10: "ten", 9: "nine", int8(9): "int8 nine", "5": "five", "a": "a", true: "true"},
+		Nested: [][]int{{1, 2}, {3}},
+	}
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		{"Items.{Name}", []interface{}{"pen", "book", "lamp"}},
+		{"Items.{Price * 2}", []interface{}{4.0, 30.0, 80.0}},
+		{"Items.{? Price > 10}.{Name}", []interface{}{"book", "lamp"}},
+		{"Items.{Name}[1]", "book"},
+		{"Fixed.{#this + 1}", []interface{}{int64(4), int64(2), int64(3)}},
+		{"Stock.{Key + '=' + Value}", []interface{}{"book=0", "lamp=3", "pen=10"}},
+		{"Nested.{#this.{#this * 10}}", []interface{}{[]interface{}{int64(10), int64(20)}, []interface{}{int64(30)}}},
+		{"None.{#this}", []interface{}{}},
+		{"Mixed.{Value}", []interface{}{"true", "nine", "ten", "int8 nine", "five", "a"}},
+	}
+	for _, test := range tests {
+		value, err := MustParse(test.source).Eval(shop, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.source, test.expected, value)
+		}
+	}
+}
+
+func TestCollectionErrors(t *testing.T) {
+	shop := &collectionShop{Items: []collectionItem{{Name: "pen", Price: 2}}}
+	for _, source := range []string{
+		"Items[0].{Name}",
+		"Missing.{#this}",
+		"Items.{? Missing}",
+		"Items.{Missing}",
+		"Items.{? Name / 2}",
+	} {
+		if _, err := Eval(source, shop); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+}
+
+func TestCollectionDeterministic(t *testing.T) {
+	shop := &collectionShop{Mixed: map[interface{}]string{10: "ten", 9: "nine", int8(9): "int8 nine", "5": "five", "a": "a", true: "true"}}
+	expected, err := Eval("Mixed.{Key}", shop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if actual, _ := Eval("Mixed.{Key}", shop); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+}
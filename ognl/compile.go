@@ -0,0 +1,659 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+)
+
+// compiled is the evaluation of a node, with names already resolved where
+// the types are known.
+type compiled func(ev *evaluator, this reflect.Value) (reflect.Value, error)
+
+// dynamic returns the evaluation of a node that resolves names at run time.
+func dynamic(node Node) compiled {
+	return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+		return ev.value(node, this)
+	}
+}
+
+// Compiled is an expression compiled for a root type: its properties and
+// methods have been checked against the type, and fields are accessed by
+// index rather than by name. It is safe for concurrent use.
+type Compiled struct {
+	expression *Expression
+	typ        reflect.Type
+	eval       compiled
+}
+
+// compileKey identifies a compiled expression in the cache.
+type compileKey struct {
+	source string
+	typ    reflect.Type
+}
+
+// DefaultCacheSize is the number of compiled expressions cached by default.
+const DefaultCacheSize = 1000
+
+var (
+	// cacheSize is the maximum number of compiled expressions in the cache.
+	cacheSize = DefaultCacheSize
+	// cacheOrder holds the compiled expressions in the cache, the most
+	// recently used first.
+	cacheOrder = list.New()
+	// cacheItems indexes the elements of cacheOrder.
+	cacheItems = map[compileKey]*list.Element{}
+	cacheLock  sync.Mutex
+)
+
+// SetCacheSize sets the maximum number of compiled expressions cached by
+// Compile, evicting the least recently used ones beyond it; 0 disables the
+// cache. Applications compiling expressions from unbounded sources can hold
+// their own *Compiled values instead.
+func SetCacheSize(size int) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cacheSize = max(size, 0)
+	evict()
+}
+
+// CacheSize returns the maximum number of compiled expressions cached by
+// Compile.
+func CacheSize() int {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	return cacheSize
+}
+
+// cached returns a compiled expression from the cache, if there.
+func cached(key compileKey) (*Compiled, bool) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	element, ok := cacheItems[key]
+	if !ok {
+		return nil, false
+	}
+	cacheOrder.MoveToFront(element)
+	return element.Value.(*Compiled), true
+}
+
+// store adds a compiled expression to the cache, and returns the one to use:
+// that already in the cache if another goroutine got there first.
+func store(key compileKey, c *Compiled) *Compiled {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if element, ok := cacheItems[key]; ok {
+		cacheOrder.MoveToFront(element)
+		return element.Value.(*Compiled)
+	}
+	if cacheSize > 0 {
+		cacheItems[key] = cacheOrder.PushFront(c)
+		evict()
+	}
+	return c
+}
+
+// evict removes the least recently used expressions from the cache until it
+// fits its size; the cache lock must be held.
+func evict() {
+	for cacheOrder.Len() > cacheSize {
+		element := cacheOrder.Back()
+		c := cacheOrder.Remove(element).(*Compiled)
+		delete(cacheItems, compileKey{source: c.expression.source, typ: c.typ})
+	}
+}
+
+// Compile parses an expression and compiles it for roots of the given type;
+// the most recently compiled expressions are cached (see SetCacheSize), so
+// compiling the same expression for the same type again is cheap. Properties
+// and methods are checked wherever the type of their target is known: values
+// held in interfaces, context variables and functions are resolved at run
+// time, as by Expression.Eval.
+func Compile(source string, typ reflect.Type) (*Compiled, error) {
+	key := compileKey{source: source, typ: typ}
+	if c, ok := cached(key); ok {
+		return c, nil
+	}
+	expression, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{source: source, root: typ}
+	eval, _, err := c.compile(expression.root, typ)
+	if err != nil {
+		return nil, err
+	}
+	return store(key, &Compiled{expression: expression, typ: typ, eval: eval}), nil
+}
+
+// MustCompile compiles an expression, and panics if it is invalid.
+func MustCompile(source string, typ reflect.Type) *Compiled {
+	c, err := Compile(source, typ)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Expression returns the parsed expression.
+func (c *Compiled) Expression() *Expression {
+	return c.expression
+}
+
+// Type returns the root type the expression was compiled for.
+func (c *Compiled) Type() reflect.Type {
+	return c.typ
+}
+
+// Eval evaluates the expression against a root object of the type it was
+// compiled for, with the variables and the sandbox in the context, which can
+// be nil.
+func (c *Compiled) Eval(root interface{}, variables *Context) (interface{}, error) {
+	return c.EvalContext(context.Background(), root, variables)
+}
+
+// EvalContext is like Eval, but fails as soon as ctx is done.
+func (c *Compiled) EvalContext(ctx context.Context, root interface{}, variables *Context) (interface{}, error) {
+	value := reflect.ValueOf(root)
+	if !value.IsValid() || value.Type() != c.typ {
+		return nil, newError(c.expression.source, 0, "expression compiled for %s evaluated on %T", c.typ, root)
+	}
+	ev := newEvaluator(ctx, c.expression.source, root, variables)
+	result, err := c.eval(ev, addressable(value))
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsValid() || !result.CanInterface() {
+		return nil, nil
+	}
+	return result.Interface(), nil
+}
+
+// compiler compiles the nodes of an expression.
+type compiler struct {
+	source string
+	root   reflect.Type
+}
+
+// fail returns an error at the position of a node.
+func (c *compiler) fail(node Node, format string, args ...interface{}) error {
+	return newError(c.source, node.Pos(), format, args...)
+}
+
+// compile compiles a node for the given type of the current object (nil if
+// unknown), and returns the type of its value (nil if unknown); each
+// evaluation of the node counts as a step for the sandbox.
+func (c *compiler) compile(node Node, this reflect.Type) (compiled, reflect.Type, error) {
+	switch node := node.(type) {
+	case *Variable:
+		if node.Name != "root" && node.Name != "this" {
+			return dynamic(node), nil, nil
+		}
+	case *Assignment:
+		return dynamic(node), nil, nil
+	}
+	eval, typ, err := c.compileNode(node, this)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+		if err := ev.step(node); err != nil {
+			return reflect.Value{}, err
+		}
+		return eval(ev, this)
+	}, typ, nil
+}
+
+// compileNode compiles a node, except for the nodes always evaluated
+// dynamically.
+func (c *compiler) compileNode(node Node, this reflect.Type) (compiled, reflect.Type, error) {
+	switch node := node.(type) {
+	case *Literal:
+		value := reflect.ValueOf(node.Value)
+		return func(*evaluator, reflect.Value) (reflect.Value, error) {
+			return value, nil
+		}, reflect.TypeOf(node.Value), nil
+
+	case *Variable:
+		switch node.Name {
+		case "root":
+			return func(ev *evaluator, _ reflect.Value) (reflect.Value, error) {
+				return reflect.ValueOf(ev.root), nil
+			}, c.root, nil
+		}
+		return func(_ *evaluator, this reflect.Value) (reflect.Value, error) {
+			return this, nil
+		}, this, nil
+
+	case *Property:
+		self := func(_ *evaluator, this reflect.Value) (reflect.Value, error) {
+			return this, nil
+		}
+		return c.member(node, self, this, node.Name)
+
+	case *Member:
+		target, typ, err := c.compile(node.Target, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.member(node, target, typ, node.Name)
+
+	case *Index:
+		target, typ, err := c.compile(node.Target, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		index, _, err := c.compile(node.Index, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result reflect.Type
+		if typ = concrete(typ); typ != nil {
+			switch typ.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				result = typ.Elem()
+			case reflect.String:
+				result = typ
+			case reflect.Struct:
+			default:
+				return nil, nil, c.fail(node, "cannot index %s", typ)
+			}
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			i, err := index(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.index(node, t, i)
+		}, result, nil
+
+	case *Call:
+		target, typ := compiled(nil), this
+		if node.Target != nil {
+			var err error
+			if target, typ, err = c.compile(node.Target, this); err != nil {
+				return nil, nil, err
+			}
+		}
+		args := make([]compiled, len(node.Args))
+		for i, arg := range node.Args {
+			var err error
+			if args[i], _, err = c.compile(arg, this); err != nil {
+				return nil, nil, err
+			}
+		}
+		var result reflect.Type
+		if typ != nil && concrete(typ) != nil {
+			// calls without target can resolve to functions of the context
+			// at run time
+			method, ok := methodOf(typ, node.Name)
+			switch {
+			case ok:
+				in := method.NumIn()
+				if method.IsVariadic() && len(args) < in-1 {
+					return nil, nil, c.fail(node, "wrong number of arguments for %s: %d instead of at least %d", node.Name, len(args), in-1)
+				}
+				if !method.IsVariadic() && len(args) != in {
+					return nil, nil, c.fail(node, "wrong number of arguments for %s: %d instead of %d", node.Name, len(args), in)
+				}
+				result = resultOf(method)
+			case node.Target != nil:
+				return nil, nil, c.fail(node, "no method %s in %s", node.Name, typ)
+			}
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t := this
+			if target != nil {
+				var err error
+				if t, err = target(ev, this); err != nil {
+					return reflect.Value{}, err
+				}
+			}
+			values, err := arguments(ev, this, args)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.apply(node, t, values)
+		}, result, nil
+
+	case *Function:
+		args := make([]compiled, len(node.Args))
+		for i, arg := range node.Args {
+			var err error
+			if args[i], _, err = c.compile(arg, this); err != nil {
+				return nil, nil, err
+			}
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			values, err := arguments(ev, this, args)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.function(node, values)
+		}, nil, nil
+
+	case *Unary:
+		operand, _, err := c.compile(node.Operand, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result reflect.Type
+		if node.Op == "!" {
+			result = reflect.TypeOf(false)
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			value, err := operand(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.unary(node, value)
+		}, result, nil
+
+	case *Binary:
+		left, _, err := c.compile(node.Left, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		right, _, err := c.compile(node.Right, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result reflect.Type
+		switch node.Op {
+		case "&&", "||", "==", "!=", "<", "<=", ">", ">=":
+			result = reflect.TypeOf(false)
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			l, err := left(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			switch node.Op {
+			case "&&":
+				if !truth(l) {
+					return reflect.ValueOf(false), nil
+				}
+				r, err := right(ev, this)
+				return reflect.ValueOf(truth(r)), err
+			case "||":
+				if truth(l) {
+					return reflect.ValueOf(true), nil
+				}
+				r, err := right(ev, this)
+				return reflect.ValueOf(truth(r)), err
+			}
+			r, err := right(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.binary(node, l, r)
+		}, result, nil
+
+	case *Conditional:
+		condition, _, err := c.compile(node.Condition, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		then, thenType, err := c.compile(node.Then, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		otherwise, elseType, err := c.compile(node.Else, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result reflect.Type
+		if thenType == elseType {
+			result = thenType
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			value, err := condition(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if truth(value) {
+				return then(ev, this)
+			}
+			return otherwise(ev, this)
+		}, result, nil
+
+	case *List:
+		elements := make([]compiled, len(node.Elements))
+		for i, element := range node.Elements {
+			var err error
+			if elements[i], _, err = c.compile(element, this); err != nil {
+				return nil, nil, err
+			}
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			if err := ev.allocate(node, len(elements)); err != nil {
+				return reflect.Value{}, err
+			}
+			list := make([]interface{}, len(elements))
+			for i, element := range elements {
+				value, err := element(ev, this)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				list[i] = result(value)
+			}
+			return reflect.ValueOf(list), nil
+		}, reflect.TypeOf([]interface{}{}), nil
+
+	case *Selection:
+		target, typ, err := c.compile(node.Target, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		element, collection, err := c.element(node, typ)
+		if err != nil {
+			return nil, nil, err
+		}
+		condition, _, err := c.compile(node.Condition, element)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.selection(node, t, condition)
+		}, collection, nil
+
+	case *Projection:
+		target, typ, err := c.compile(node.Target, this)
+		if err != nil {
+			return nil, nil, err
+		}
+		element, _, err := c.element(node, typ)
+		if err != nil {
+			return nil, nil, err
+		}
+		expression, _, err := c.compile(node.Expression, element)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.projection(node, t, expression)
+		}, reflect.TypeOf([]interface{}{}), nil
+	}
+	return nil, nil, c.fail(node, "unsupported expression %s", node)
+}
+
+// arguments evaluates the compiled arguments of a call.
+func arguments(ev *evaluator, this reflect.Value, args []compiled) ([]reflect.Value, error) {
+	values := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var err error
+		if values[i], err = arg(ev, this); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// concrete returns a type with pointers dereferenced, or nil if it is
+// unknown or an interface.
+func concrete(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() == reflect.Interface {
+		return nil
+	}
+	return typ
+}
+
+// member compiles the access to a property of an object of the given type;
+// fields are resolved to their index.
+func (c *compiler) member(node Node, target compiled, typ reflect.Type, name string) (compiled, reflect.Type, error) {
+	resolved := func(access func(ev *evaluator, object reflect.Value) (reflect.Value, error)) compiled {
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			object := indirect(t)
+			if !object.IsValid() {
+				return reflect.Value{}, ev.fail(node, "cannot get property %s of nil", name)
+			}
+			value, err := access(ev, object)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.reached(node, value)
+		}
+	}
+
+	object := concrete(typ)
+	if object == nil {
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.member(node, t, name)
+		}, nil, nil
+	}
+	switch {
+	case object.Kind() == reflect.Struct:
+		if field, ok := object.FieldByName(name); ok {
+			if field.PkgPath != "" {
+				return nil, nil, c.fail(node, "cannot access unexported field %s of %s", name, object)
+			}
+			index := field.Index
+			if len(index) == 1 {
+				return resolved(func(ev *evaluator, object reflect.Value) (reflect.Value, error) {
+					if err := ev.checkField(node, object.Type(), field); err != nil {
+						return reflect.Value{}, err
+					}
+					return object.Field(index[0]), nil
+				}), field.Type, nil
+			}
+			return resolved(func(ev *evaluator, object reflect.Value) (reflect.Value, error) {
+				if err := ev.checkField(node, object.Type(), field); err != nil {
+					return reflect.Value{}, err
+				}
+				value, err := object.FieldByIndexErr(index)
+				if err != nil {
+					return reflect.Value{}, ev.fail(node, "cannot get property %s through nil embedded struct", name)
+				}
+				return value, nil
+			}), field.Type, nil
+		}
+	case object.Kind() == reflect.Map && object.Key().Kind() == reflect.String:
+		key := reflect.ValueOf(name).Convert(object.Key())
+		return resolved(func(ev *evaluator, object reflect.Value) (reflect.Value, error) {
+			if err := ev.checkType(node, object.Type()); err != nil {
+				return reflect.Value{}, err
+			}
+			return object.MapIndex(key), nil
+		}), object.Elem(), nil
+	}
+	if method, ok := methodOf(typ, name); ok && method.NumIn() == 0 {
+		return func(ev *evaluator, this reflect.Value) (reflect.Value, error) {
+			t, err := target(ev, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return ev.member(node, t, name)
+		}, resultOf(method), nil
+	}
+	return nil, nil, c.fail(node, "no property %s in %s", name, object)
+}
+
+// element returns the type of the elements of a collection of the given type
+// as seen by selections and projections (nil if unknown), and the type of the
+// slice selections return.
+func (c *compiler) element(node Node, typ reflect.Type) (reflect.Type, reflect.Type, error) {
+	collection := concrete(typ)
+	if collection == nil {
+		return nil, nil, nil
+	}
+	switch collection.Kind() {
+	case reflect.Slice, reflect.Array:
+		return collection.Elem(), reflect.SliceOf(collection.Elem()), nil
+	case reflect.Map:
+		return reflect.TypeOf(Entry{}), reflect.TypeOf([]Entry{}), nil
+	}
+	return nil, nil, c.fail(node, "cannot iterate over %s", collection)
+}
+
+// methodOf returns the type of the exported method with the given name of a
+// type, of the type it points to or of a pointer to it, without receiver.
+func methodOf(typ reflect.Type, name string) (reflect.Type, bool) {
+	candidates := []reflect.Type{typ}
+	if base := concrete(typ); base != nil && base != typ {
+		candidates = append(candidates, base)
+	}
+	if base := concrete(typ); base != nil {
+		candidates = append(candidates, reflect.PtrTo(base))
+	}
+	for _, candidate := range candidates {
+		method, ok := candidate.MethodByName(name)
+		if !ok {
+			continue
+		}
+		if candidate.Kind() == reflect.Interface {
+			return method.Type, true
+		}
+		// drop the receiver
+		in := make([]reflect.Type, method.Type.NumIn()-1)
+		for i := range in {
+			in[i] = method.Type.In(i + 1)
+		}
+		out := make([]reflect.Type, method.Type.NumOut())
+		for i := range out {
+			out[i] = method.Type.Out(i)
+		}
+		return reflect.FuncOf(in, out, method.Type.IsVariadic()), true
+	}
+	return nil, false
+}
+
+// resultOf returns the type of the value returned by a method, nil if it
+// returns nothing (or only an error).
+func resultOf(method reflect.Type) reflect.Type {
+	n := method.NumOut()
+	if n > 0 && method.Out(n-1) == reflect.TypeOf((*error)(nil)).Elem() {
+		n--
+	}
+	if n == 1 {
+		return method.Out(0)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type benchItem struct {
+	Name     string
+	Price    float64
+	Quantity int
+}
+
+type benchOrder struct {
+	ID    int
+	Items []benchItem
+}
+
+type benchCustomer struct {
+	Name    string
+	Age     int
+	Address struct {
+		City string
+		Zip  string
+	}
+	Orders []*benchOrder
+	Tags   map[string]string
+	Extra  interface{}
+}
+
+func (c *benchCustomer) Total() float64 {
+	total := 0.0
+	for _, order := range c.Orders {
+		for _, item := range order.Items {
+			total += item.Price * float64(item.Quantity)
+		}
+	}
+	return total
+}
+
+func TestCompile(t *testing.T) {
+	customer := &benchCustomer{
+		Name: "Ann",
+		Age:  42,
+		Orders: []*benchOrder{
+			{ID: 0, Items: []benchItem{{Name: "pen", Price: 2, Quantity: 3}, {Name: "book", Price: 15, Quantity: 1}}},
+			{ID: 1, Items: []benchItem{{Name: "lamp", Price: 40, Quantity: 1}}},
+		},
+		Tags:  map[string]string{"tier": "gold"},
+		Extra: map[string]int{"x": 1},
+	}
+	customer.Address.City = "Rome"
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		{"Name", "Ann"},
+		{"Address.City + ' ' + Orders[1].Items[0].Name", "Rome lamp"},
+		{"Age > 40 ? 'senior' : 'junior'", "senior"},
+		{"Tags.tier", "gold"},
+		{"Tags['missing']", nil},
+		{"Total()", 61.0},
+		{"Orders.{ID}", []interface{}{0, 1}},
+		{"Orders[0].Items.{? Price > 10}.{Name}", []interface{}{"book"}},
+		{"Extra.x", 1},
+		{"#root.Name", "Ann"},
+	}
+	for _, test := range tests {
+		compiled, err := Compile(test.source, reflect.TypeOf(customer))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		value, err := compiled.Eval(customer, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+		} else if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", test.source, test.expected, test.expected, value, value)
+		}
+		if expected, err := Eval(test.source, customer); err != nil || !reflect.DeepEqual(value, expected) {
+			t.Errorf("%s: compiled gives %v, parsed gives %v, %v", test.source, value, expected, err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	typ := reflect.TypeOf(&benchCustomer{})
+	for _, source := range []string{
+		"Missing",
+		"Address.Missing",
+		"Orders[0].Items[0].Missing",
+		"Total(1)",
+		"Name(",
+	} {
+		if _, err := Compile(source, typ); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+
+	compiled := MustCompile("Name", typ)
+	if _, err := compiled.Eval(benchCustomer{}, nil); err == nil {
+		t.Errorf("expected an error evaluating on another type")
+	}
+	if _, err := compiled.Eval(nil, nil); err == nil {
+		t.Errorf("expected an error evaluating on nil")
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	defer SetCacheSize(CacheSize())
+	typ := reflect.TypeOf(&benchCustomer{})
+
+	SetCacheSize(2)
+	a := MustCompile("Name", typ)
+	if MustCompile("Name", typ) != a {
+		t.Errorf("expected the cached expression")
+	}
+	if MustCompile("Name", reflect.TypeOf(benchCustomer{})) == a {
+		t.Errorf("expected an expression for another type")
+	}
+	MustCompile("Age", typ)
+	MustCompile("Tags", typ)
+	if len(cacheItems) != 2 || cacheOrder.Len() != 2 {
+		t.Errorf("expected 2 cached expressions, got %d", len(cacheItems))
+	}
+	if MustCompile("Name", typ) == a {
+		t.Errorf("expected the least recently used expression to be evicted")
+	}
+
+	SetCacheSize(0)
+	if len(cacheItems) != 0 || cacheOrder.Len() != 0 {
+		t.Errorf("expected an empty cache, got %d", len(cacheItems))
+	}
+	if MustCompile("Name", typ) == MustCompile("Name", typ) {
+		t.Errorf("expected no caching")
+	}
+	for i := 0; i < 100; i++ {
+		MustCompile(fmt.Sprintf("Age + %d", i), typ)
+	}
+	if len(cacheItems) != 0 {
+		t.Errorf("expected an empty cache, got %d", len(cacheItems))
+	}
+}
+
+// benchSource is the expression evaluated by the benchmarks.
+const benchSource = "Address.City + ' ' + Orders[1].Items[0].Name"
+
+// benchRoot is the object the benchmarks evaluate benchSource on.
+var benchRoot = &benchCustomer{
+	Name: "Ann",
+	Orders: []*benchOrder{
+		{ID: 0, Items: []benchItem{{Name: "pen", Price: 2, Quantity: 3}}},
+		{ID: 1, Items: []benchItem{{Name: "lamp", Price: 40, Quantity: 1}}},
+	},
+}
+
+func BenchmarkParseEachTime(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Eval(benchSource, benchRoot); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParsedOnce(b *testing.B) {
+	expression := MustParse(benchSource)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := expression.Eval(benchRoot, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	compiled := MustCompile(benchSource, reflect.TypeOf(benchRoot))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := compiled.Eval(benchRoot, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiledParallel(b *testing.B) {
+	compiled := MustCompile(benchSource, reflect.TypeOf(benchRoot))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := compiled.Eval(benchRoot, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
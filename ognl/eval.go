@@ -0,0 +1,805 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dihedron/go-reflector/reflector"
+)
+
+// evaluator evaluates the nodes of an expression; values are handled as
+// reflect.Values, where the invalid Value stands for nil.
+type evaluator struct {
+	source      string
+	context     *Context
+	root        interface{}
+	sandbox     *Sandbox
+	ctx         context.Context
+	done        <-chan struct{}
+	steps       int
+	allocations int
+}
+
+// newEvaluator returns an evaluator for an expression, with the variables
+// and the sandbox of a Context, which can be nil.
+func newEvaluator(ctx context.Context, source string, root interface{}, variables *Context) *evaluator {
+	if variables == nil {
+		variables = NewContext()
+	}
+	return &evaluator{
+		source:  source,
+		context: variables,
+		root:    root,
+		sandbox: variables.sandbox,
+		ctx:     ctx,
+		done:    ctx.Done(),
+	}
+}
+
+// fail returns an error at the position of a node.
+func (ev *evaluator) fail(node Node, format string, args ...interface{}) error {
+	return newError(ev.source, node.Pos(), format, args...)
+}
+
+// failWith returns an error at the position of a node, wrapping an
+// underlying error.
+func (ev *evaluator) failWith(node Node, err error, format string, args ...interface{}) error {
+	e := newError(ev.source, node.Pos(), format, args...)
+	e.Err = err
+	return e
+}
+
+// eval evaluates a node against the current object and returns its value
+// as an interface{}, nil for nil.
+func (ev *evaluator) eval(node Node, this interface{}) (interface{}, error) {
+	value, err := ev.value(node, addressable(reflect.ValueOf(this)))
+	if err != nil {
+		return nil, err
+	}
+	return result(value), nil
+}
+
+// addressable returns an addressable copy of a value that is not a pointer,
+// so that methods with pointer receivers can be called on it.
+func addressable(value reflect.Value) reflect.Value {
+	if !value.IsValid() || value.Kind() == reflect.Ptr {
+		return value
+	}
+	copy := reflect.New(value.Type()).Elem()
+	copy.Set(value)
+	return copy
+}
+
+// result returns a value as an interface{}, nil for nil.
+func result(value reflect.Value) interface{} {
+	if !value.IsValid() || !value.CanInterface() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// value evaluates a node against the current object.
+func (ev *evaluator) value(node Node, this reflect.Value) (reflect.Value, error) {
+	if err := ev.step(node); err != nil {
+		return reflect.Value{}, err
+	}
+	switch node := node.(type) {
+	case *Literal:
+		return reflect.ValueOf(node.Value), nil
+
+	case *Property:
+		return ev.member(node, this, node.Name)
+
+	case *Variable:
+		switch node.Name {
+		case "root":
+			return reflect.ValueOf(ev.root), nil
+		case "this":
+			return this, nil
+		}
+		value, ok := ev.context.Get(node.Name)
+		if !ok {
+			return reflect.Value{}, ev.fail(node, "undefined variable #%s", node.Name)
+		}
+		return reflect.ValueOf(value), nil
+
+	case *Member:
+		target, err := ev.value(node.Target, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.member(node, target, node.Name)
+
+	case *Index:
+		target, err := ev.value(node.Target, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		index, err := ev.value(node.Index, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.index(node, target, index)
+
+	case *Call:
+		target := this
+		if node.Target != nil {
+			var err error
+			if target, err = ev.value(node.Target, this); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		args, err := ev.values(node.Args, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.apply(node, target, args)
+
+	case *Function:
+		args, err := ev.values(node.Args, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.function(node, args)
+
+	case *Unary:
+		operand, err := ev.value(node.Operand, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.unary(node, operand)
+
+	case *Binary:
+		left, err := ev.value(node.Left, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		switch node.Op {
+		case "&&":
+			if !truth(left) {
+				return reflect.ValueOf(false), nil
+			}
+			right, err := ev.value(node.Right, this)
+			return reflect.ValueOf(truth(right)), err
+		case "||":
+			if truth(left) {
+				return reflect.ValueOf(true), nil
+			}
+			right, err := ev.value(node.Right, this)
+			return reflect.ValueOf(truth(right)), err
+		}
+		right, err := ev.value(node.Right, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.binary(node, left, right)
+
+	case *Conditional:
+		condition, err := ev.value(node.Condition, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if truth(condition) {
+			return ev.value(node.Then, this)
+		}
+		return ev.value(node.Else, this)
+
+	case *List:
+		if err := ev.allocate(node, len(node.Elements)); err != nil {
+			return reflect.Value{}, err
+		}
+		list := make([]interface{}, len(node.Elements))
+		for i, element := range node.Elements {
+			value, err := ev.value(element, this)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			list[i] = result(value)
+		}
+		return reflect.ValueOf(list), nil
+
+	case *Selection:
+		target, err := ev.value(node.Target, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.selection(node, target, dynamic(node.Condition))
+
+	case *Projection:
+		target, err := ev.value(node.Target, this)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.projection(node, target, dynamic(node.Expression))
+
+	case *Assignment:
+		return ev.assign(node, this)
+	}
+	return reflect.Value{}, ev.fail(node, "unsupported expression %s", node)
+}
+
+// indirect dereferences pointers and interfaces, down to nil (the invalid
+// Value) or a concrete value.
+func indirect(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// typeName returns the name of the type of a value, "nil" for nil.
+func typeName(value reflect.Value) string {
+	if !value.IsValid() {
+		return "nil"
+	}
+	return value.Type().String()
+}
+
+// member returns the property of an object with the given name: an
+// exported field, an entry of a map with string keys (nil if missing) or
+// the result of an exported method with no arguments.
+func (ev *evaluator) member(node Node, target reflect.Value, name string) (reflect.Value, error) {
+	object := indirect(target)
+	switch {
+	case !object.IsValid():
+		return reflect.Value{}, ev.fail(node, "cannot get property %s of nil", name)
+	case object.Kind() == reflect.Struct:
+		if field, ok := object.Type().FieldByName(name); ok {
+			if field.PkgPath != "" {
+				return reflect.Value{}, ev.fail(node, "cannot access unexported field %s of %s", name, object.Type())
+			}
+			if err := ev.checkField(node, object.Type(), field); err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := object.FieldByIndexErr(field.Index)
+			if err != nil {
+				return reflect.Value{}, ev.fail(node, "cannot get property %s through nil embedded struct", name)
+			}
+			return ev.reached(node, value)
+		}
+	case object.Kind() == reflect.Map && object.Type().Key().Kind() == reflect.String:
+		if err := ev.checkType(node, object.Type()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.reached(node, object.MapIndex(reflect.ValueOf(name).Convert(object.Type().Key())))
+	}
+	if method := ev.method(target, name); method.IsValid() && method.Type().NumIn() == 0 {
+		if err := ev.checkMethod(node, receiverType(target), name); err != nil {
+			return reflect.Value{}, err
+		}
+		value, err := ev.invoke(node, name, method, nil)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.reached(node, value)
+	}
+	return reflect.Value{}, ev.fail(node, "no property %s in %s", name, typeName(object))
+}
+
+// method returns the exported method of an object with the given name,
+// looking through pointers and interfaces, or the invalid Value.
+func (ev *evaluator) method(target reflect.Value, name string) reflect.Value {
+	for target.IsValid() {
+		if method := target.MethodByName(name); method.IsValid() {
+			return method
+		}
+		if target.Kind() != reflect.Ptr && target.CanAddr() {
+			if method := target.Addr().MethodByName(name); method.IsValid() {
+				return method
+			}
+		}
+		if (target.Kind() != reflect.Ptr && target.Kind() != reflect.Interface) || target.IsNil() {
+			break
+		}
+		target = target.Elem()
+	}
+	return reflect.Value{}
+}
+
+// values evaluates a list of nodes, e.g. the arguments of a call.
+func (ev *evaluator) values(nodes []Node, this reflect.Value) ([]reflect.Value, error) {
+	values := make([]reflect.Value, len(nodes))
+	for i, node := range nodes {
+		var err error
+		if values[i], err = ev.value(node, this); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// apply evaluates a call: it calls the method of its target or, if it has
+// no target and the current object has no such method, the function of the
+// context with that name.
+func (ev *evaluator) apply(node *Call, target reflect.Value, args []reflect.Value) (reflect.Value, error) {
+	if node.Target == nil && !ev.method(target, node.Name).IsValid() {
+		function, ok := ev.context.functions[node.Name]
+		if !ok {
+			return reflect.Value{}, ev.fail(node, "no method or function %s in %s", node.Name, typeName(indirect(target)))
+		}
+		if err := ev.checkFunction(node, node.Name); err != nil {
+			return reflect.Value{}, err
+		}
+		return ev.invoke(node, node.Name, function, args)
+	}
+	return ev.call(node, target, node.Name, args)
+}
+
+// function calls the function of the context with the name of a node, or
+// the function held in the variable with that name.
+func (ev *evaluator) function(node *Function, args []reflect.Value) (reflect.Value, error) {
+	function, ok := ev.context.functions[node.Name]
+	if !ok {
+		variable, _ := ev.context.Get(node.Name)
+		if function = reflect.ValueOf(variable); function.Kind() != reflect.Func || function.IsNil() {
+			return reflect.Value{}, ev.fail(node, "undefined function #%s", node.Name)
+		}
+	}
+	if err := ev.checkFunction(node, node.Name); err != nil {
+		return reflect.Value{}, err
+	}
+	return ev.invoke(node, "#"+node.Name, function, args)
+}
+
+// call calls an exported method of an object.
+func (ev *evaluator) call(node Node, target reflect.Value, name string, args []reflect.Value) (reflect.Value, error) {
+	method := ev.method(target, name)
+	if !method.IsValid() {
+		return reflect.Value{}, ev.fail(node, "no method %s in %s", name, typeName(indirect(target)))
+	}
+	if err := ev.checkMethod(node, receiverType(target), name); err != nil {
+		return reflect.Value{}, err
+	}
+	value, err := ev.invoke(node, name, method, args)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return ev.reached(node, value)
+}
+
+// reached returns a value reached by an expression, failing if the sandbox
+// denies access to it (see checkResult).
+func (ev *evaluator) reached(node Node, value reflect.Value) (reflect.Value, error) {
+	if err := ev.checkResult(node, value); err != nil {
+		return reflect.Value{}, err
+	}
+	return value, nil
+}
+
+// invoke calls a function, converting the arguments to the types of its
+// parameters; a function returning a value and an error fails if the error
+// is not nil.
+func (ev *evaluator) invoke(node Node, name string, function reflect.Value, args []reflect.Value) (value reflect.Value, err error) {
+	typ := function.Type()
+	if typ.IsVariadic() && len(args) < typ.NumIn()-1 {
+		return reflect.Value{}, ev.fail(node, "wrong number of arguments for %s: %d instead of at least %d", name, len(args), typ.NumIn()-1)
+	}
+	if !typ.IsVariadic() && len(args) != typ.NumIn() {
+		return reflect.Value{}, ev.fail(node, "wrong number of arguments for %s: %d instead of %d", name, len(args), typ.NumIn())
+	}
+	converted := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var param reflect.Type
+		if typ.IsVariadic() && i >= typ.NumIn()-1 {
+			param = typ.In(typ.NumIn() - 1).Elem()
+		} else {
+			param = typ.In(i)
+		}
+		var err error
+		if converted[i], err = reflector.Convert(result(arg), param); err != nil {
+			return reflect.Value{}, ev.fail(node, "invalid argument %d for %s: %v", i+1, name, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = reflect.Value{}, ev.fail(node, "%s panicked: %v", name, r)
+		}
+	}()
+	results := function.Call(converted)
+
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if n := len(results); n > 0 && typ.Out(n-1) == errorType {
+		if !results[n-1].IsNil() {
+			return reflect.Value{}, ev.fail(node, "%s failed: %v", name, results[n-1].Interface())
+		}
+		results = results[:n-1]
+	}
+	switch len(results) {
+	case 0:
+		return reflect.Value{}, nil
+	case 1:
+		return results[0], nil
+	}
+	return reflect.Value{}, ev.fail(node, "%s returns %d values", name, len(results))
+}
+
+// index returns the element of a list or string, the entry of a map (nil if
+// missing) or the property of an object addressed by an index.
+func (ev *evaluator) index(node Node, target reflect.Value, index reflect.Value) (reflect.Value, error) {
+	object := indirect(target)
+	if !object.IsValid() {
+		return reflect.Value{}, ev.fail(node, "cannot index nil")
+	}
+	if object.Kind() != reflect.Struct {
+		if err := ev.checkType(node, object.Type()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	switch object.Kind() {
+	case reflect.Slice, reflect.Array, reflect.String:
+		n, ok := number(index)
+		i, isInt := n.(int64)
+		if !ok || !isInt {
+			return reflect.Value{}, ev.fail(node, "invalid index %v (%s) for %s", result(index), typeName(index), object.Type())
+		}
+		length := object.Len()
+		if object.Kind() == reflect.String {
+			length = utf8.RuneCountInString(object.String())
+		}
+		if i < 0 || i >= int64(length) {
+			return reflect.Value{}, ev.fail(node, "index %d out of range [0:%d]", i, length)
+		}
+		if object.Kind() == reflect.String {
+			// strings are indexed by character
+			return reflect.ValueOf(string([]rune(object.String())[i])), nil
+		}
+		return ev.reached(node, object.Index(int(i)))
+	case reflect.Map:
+		key, err := reflector.Convert(result(index), object.Type().Key())
+		if err != nil {
+			return reflect.Value{}, ev.fail(node, "invalid key for %s: %v", object.Type(), err)
+		}
+		return ev.reached(node, object.MapIndex(key))
+	case reflect.Struct:
+		if name, ok := result(index).(string); ok {
+			return ev.member(node, object, name)
+		}
+	}
+	return reflect.Value{}, ev.fail(node, "cannot index %s with %s", object.Type(), typeName(index))
+}
+
+// assign evaluates an assignment: it sets the field of a struct, the entry
+// of a map or the element of a slice or array addressed by its target to
+// its value, converted to the type of the destination, and returns it.
+func (ev *evaluator) assign(node *Assignment, this reflect.Value) (reflect.Value, error) {
+	if err := ev.checkAssignment(node); err != nil {
+		return reflect.Value{}, err
+	}
+	var target, key reflect.Value
+	var err error
+	switch destination := node.Target.(type) {
+	case *Property:
+		target, key = this, reflect.ValueOf(destination.Name)
+	case *Member:
+		target, err = ev.value(destination.Target, this)
+		key = reflect.ValueOf(destination.Name)
+	case *Index:
+		if target, err = ev.value(destination.Target, this); err == nil {
+			key, err = ev.value(destination.Index, this)
+		}
+	default:
+		return reflect.Value{}, ev.fail(node, "cannot assign to %s", node.Target)
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	value, err := ev.value(node.Value, this)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	object := indirect(target)
+	if !object.IsValid() {
+		return reflect.Value{}, ev.fail(node, "cannot assign to %v of nil", result(key))
+	}
+	switch object.Kind() {
+	case reflect.Struct:
+		name, ok := result(key).(string)
+		if !ok {
+			break
+		}
+		field, ok := object.Type().FieldByName(name)
+		if !ok {
+			return reflect.Value{}, ev.fail(node, "no field %s in %s", name, object.Type())
+		}
+		if field.PkgPath != "" {
+			return reflect.Value{}, ev.fail(node, "cannot access unexported field %s of %s", name, object.Type())
+		}
+		if err := ev.checkField(node, object.Type(), field); err != nil {
+			return reflect.Value{}, err
+		}
+		destination, err := object.FieldByIndexErr(field.Index)
+		if err != nil {
+			return reflect.Value{}, ev.fail(node, "cannot set field %s through nil embedded struct", name)
+		}
+		if !destination.CanSet() {
+			return reflect.Value{}, ev.fail(node, "cannot set field %s of unaddressable %s", name, object.Type())
+		}
+		return ev.set(node, destination, value)
+	case reflect.Map:
+		if err := ev.checkType(node, object.Type()); err != nil {
+			return reflect.Value{}, err
+		}
+		if object.IsNil() {
+			return reflect.Value{}, ev.fail(node, "cannot set entry of nil %s", object.Type())
+		}
+		k, err := reflector.Convert(result(key), object.Type().Key())
+		if err != nil {
+			return reflect.Value{}, ev.fail(node, "invalid key for %s: %v", object.Type(), err)
+		}
+		v, err := reflector.Convert(result(value), object.Type().Elem())
+		if err != nil {
+			return reflect.Value{}, ev.fail(node, "cannot assign %s to %s: %v", typeName(value), object.Type().Elem(), err)
+		}
+		object.SetMapIndex(k, v)
+		return v, nil
+	case reflect.Slice, reflect.Array:
+		if err := ev.checkType(node, object.Type()); err != nil {
+			return reflect.Value{}, err
+		}
+		n, ok := number(key)
+		i, isInt := n.(int64)
+		if !ok || !isInt {
+			return reflect.Value{}, ev.fail(node, "invalid index %v (%s) for %s", result(key), typeName(key), object.Type())
+		}
+		if i < 0 || i >= int64(object.Len()) {
+			return reflect.Value{}, ev.fail(node, "index %d out of range [0:%d]", i, object.Len())
+		}
+		destination := object.Index(int(i))
+		if !destination.CanSet() {
+			return reflect.Value{}, ev.fail(node, "cannot set element of unaddressable %s", object.Type())
+		}
+		return ev.set(node, destination, value)
+	}
+	return reflect.Value{}, ev.fail(node, "cannot assign to %v of %s", result(key), object.Type())
+}
+
+// set sets a settable value, converting the new value to its type.
+func (ev *evaluator) set(node Node, destination reflect.Value, value reflect.Value) (reflect.Value, error) {
+	converted, err := reflector.Convert(result(value), destination.Type())
+	if err != nil {
+		return reflect.Value{}, ev.fail(node, "cannot assign %s to %s: %v", typeName(value), destination.Type(), err)
+	}
+	destination.Set(converted)
+	return destination, nil
+}
+
+// number returns a numeric value as an int64 or a float64; unsigned
+// integers that do not fit an int64 are returned as float64.
+func number(value reflect.Value) (interface{}, bool) {
+	value = indirect(value)
+	if !value.IsValid() {
+		return nil, false
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return float64(value.Uint()), true
+		}
+		return int64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return nil, false
+}
+
+// float returns a number as a float64.
+func float(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// truth returns the boolean value of a value.
+func truth(value reflect.Value) bool {
+	value = indirect(value)
+	if !value.IsValid() {
+		return false
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len() > 0
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return !value.IsNil()
+	}
+	if n, ok := number(value); ok {
+		return float(n) != 0
+	}
+	return true
+}
+
+// text returns the string form of a value, for concatenation; in a sandbox,
+// methods like String are never called, so only values of basic kinds can
+// be converted, and they are formatted by kind.
+func (ev *evaluator) text(node Node, value reflect.Value) (string, error) {
+	value = indirect(value)
+	switch {
+	case !value.IsValid():
+		return "null", nil
+	case value.Kind() == reflect.String:
+		return value.String(), nil
+	case ev.sandbox == nil:
+		return fmt.Sprint(result(value)), nil
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits()), nil
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(value.Complex(), 'g', -1, value.Type().Bits()), nil
+	}
+	return "", ev.failWith(node, ErrDenied, "conversion of %s to string denied", value.Type())
+}
+
+// unary applies a unary operator.
+func (ev *evaluator) unary(node *Unary, operand reflect.Value) (reflect.Value, error) {
+	if node.Op == "!" {
+		return reflect.ValueOf(!truth(operand)), nil
+	}
+	n, ok := number(operand)
+	if !ok {
+		return reflect.Value{}, ev.fail(node, "invalid operand %s for unary %s", typeName(operand), node.Op)
+	}
+	if node.Op == "+" {
+		return reflect.ValueOf(n), nil
+	}
+	if i, ok := n.(int64); ok {
+		return reflect.ValueOf(-i), nil
+	}
+	return reflect.ValueOf(-n.(float64)), nil
+}
+
+// binary applies an arithmetic or comparison operator.
+func (ev *evaluator) binary(node *Binary, left reflect.Value, right reflect.Value) (reflect.Value, error) {
+	switch node.Op {
+	case "==":
+		return reflect.ValueOf(equal(left, right)), nil
+	case "!=":
+		return reflect.ValueOf(!equal(left, right)), nil
+	}
+
+	if node.Op == "+" && (indirect(left).Kind() == reflect.String || indirect(right).Kind() == reflect.String) {
+		l, err := ev.text(node, left)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		r, err := ev.text(node, right)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if err := ev.allocate(node, len(l)+len(r)); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(l + r), nil
+	}
+
+	l, lok := number(left)
+	r, rok := number(right)
+	if !lok || !rok {
+		switch node.Op {
+		case "<", "<=", ">", ">=":
+			if ls, rs := indirect(left), indirect(right); ls.Kind() == reflect.String && rs.Kind() == reflect.String {
+				return reflect.ValueOf(compare(node.Op, strings.Compare(ls.String(), rs.String()))), nil
+			}
+		}
+		return reflect.Value{}, ev.fail(node, "invalid operands %s and %s for %s", typeName(left), typeName(right), node.Op)
+	}
+
+	li, lint := l.(int64)
+	ri, rint := r.(int64)
+	if lint && rint {
+		switch node.Op {
+		case "+":
+			return reflect.ValueOf(li + ri), nil
+		case "-":
+			return reflect.ValueOf(li - ri), nil
+		case "*":
+			return reflect.ValueOf(li * ri), nil
+		case "/", "%":
+			if ri == 0 {
+				return reflect.Value{}, ev.fail(node, "division by zero")
+			}
+			if node.Op == "/" {
+				return reflect.ValueOf(li / ri), nil
+			}
+			return reflect.ValueOf(li % ri), nil
+		}
+		switch {
+		case li < ri:
+			return reflect.ValueOf(compare(node.Op, -1)), nil
+		case li > ri:
+			return reflect.ValueOf(compare(node.Op, 1)), nil
+		}
+		return reflect.ValueOf(compare(node.Op, 0)), nil
+	}
+
+	lf, rf := float(l), float(r)
+	switch node.Op {
+	case "+":
+		return reflect.ValueOf(lf + rf), nil
+	case "-":
+		return reflect.ValueOf(lf - rf), nil
+	case "*":
+		return reflect.ValueOf(lf * rf), nil
+	case "/":
+		return reflect.ValueOf(lf / rf), nil
+	case "%":
+		return reflect.ValueOf(math.Mod(lf, rf)), nil
+	}
+	switch {
+	case lf < rf:
+		return reflect.ValueOf(compare(node.Op, -1)), nil
+	case lf > rf:
+		return reflect.ValueOf(compare(node.Op, 1)), nil
+	}
+	return reflect.ValueOf(compare(node.Op, 0)), nil
+}
+
+// compare returns the result of a comparison operator, given the sign of
+// the difference between the operands.
+func compare(op string, sign int) bool {
+	switch op {
+	case "<":
+		return sign < 0
+	case "<=":
+		return sign <= 0
+	case ">":
+		return sign > 0
+	}
+	return sign >= 0
+}
+
+// equal returns whether two values are equal: numbers are compared by
+// value, nil equals nil pointers, maps, slices and interfaces.
+func equal(left reflect.Value, right reflect.Value) bool {
+	l, r := indirect(left), indirect(right)
+	switch {
+	case !l.IsValid() || !r.IsValid():
+		return !l.IsValid() && !r.IsValid() || isNil(l) || isNil(r)
+	}
+	if ln, ok := number(l); ok {
+		if rn, ok := number(r); ok {
+			if li, ok := ln.(int64); ok {
+				if ri, ok := rn.(int64); ok {
+					return li == ri
+				}
+			}
+			return float(ln) == float(rn)
+		}
+		return false
+	}
+	return reflect.DeepEqual(result(l), result(r))
+}
+
+// isNil returns whether a valid value is a nil map, slice, channel or
+// function.
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return value.IsNil()
+	}
+	return false
+}
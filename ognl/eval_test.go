@@ -0,0 +1,224 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type evalAddress struct {
+	City string
+}
+
+type evalPerson struct {
+	Name    string
+	Age     int
+	Score   float64
+	Ratio   float32
+	Address *evalAddress
+	Friends []*evalPerson
+	Tags    map[string]string
+	Counts  map[int]int
+	Grid    [2][2]int
+	Any     interface{}
+	secret  string
+}
+
+func (p *evalPerson) Greet(greeting string) string {
+	return greeting + " " + p.Name
+}
+
+func (p evalPerson) Initial() string {
+	return p.Name[:1]
+}
+
+func (p *evalPerson) Fail() (string, error) {
+	return "", errors.New("boom")
+}
+
+func TestEval(t *testing.T) {
+	person := &evalPerson{
+		Name:    "Ann",
+		Age:     42,
+		Score:   7.5,
+		Address: &evalAddress{City: "Rome"},
+		Friends: []*evalPerson{{Name: "Bob", Age: 30}, {Name: "Cy", Age: 50}},
+		Tags:    map[string]string{"tier": "gold"},
+		Counts:  map[int]int{1: 10},
+		Grid:    [2][2]int{{1, 2}, {3, 4}},
+		Any:     map[string]interface{}{"k": []int{5, 6}},
+		secret:  "s",
+	}
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		// literals
+		{"42", int64(42)},
+		{"1.5", 1.5},
+		{"'x'", "x"},
+		{"true", true},
+		{"null", nil},
+		{"{1, 'a'}", []interface{}{int64(1), "a"}},
+		// navigation
+		{"Name", "Ann"},
+		{"Address.City", "Rome"},
+		{"Friends[1].Name", "Cy"},
+		{"Friends[0]['Name']", "Bob"},
+		{"Tags.tier", "gold"},
+		{"Tags['tier']", "gold"},
+		{"Tags.missing", nil},
+		{"Counts[1]", 10},
+		{"Grid[1][0]", 3},
+		{"Name[0]", "A"},
+		{"Any.k[1]", 6},
+		{"#root.Age", 42},
+		{"#this.Age", 42},
+		{"#x * 2", int64(6)},
+		// calls
+		{"Greet('hi')", "hi Ann"},
+		{"Friends[0].Greet('yo')", "yo Bob"},
+		{"Initial()", "A"},
+		// arithmetic
+		{"1 + 2 * 3", int64(7)},
+		{"7 / 2", int64(3)},
+		{"7 % 4", int64(3)},
+		{"7 / 2.0", 3.5},
+		{"Age + Score", 49.5},
+		{"-Age", int64(-42)},
+		{"+Score", 7.5},
+		{"'a' + 1", "a1"},
+		{"Name + ' ' + Age", "Ann 42"},
+		// comparison and logic
+		{"Age == 42", true},
+		{"Age eq 42.0", true},
+		{"Name != 'Bob'", true},
+		{"Age > 40 && Score lt 8", true},
+		{"'a' < 'b'", true},
+		{"Age gte 43 or not Name", false},
+		{"!''", true},
+		{"!{}", true},
+		{"null == null", true},
+		{"Address == null", false},
+		{"false && Missing", false},
+		{"Age > 40 ? 'old' : 'young'", "old"},
+		{"Friends[0].Age > 40 ? 'old' : 'young'", "young"},
+	}
+	variables := NewContext()
+	variables.Set("x", 3)
+	for _, test := range tests {
+		value, err := MustParse(test.source).Eval(person, variables)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", test.source, test.expected, test.expected, value, value)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	person := &evalPerson{
+		Name:    "Ann",
+		Address: &evalAddress{},
+		Friends: []*evalPerson{{Name: "Bob"}},
+		secret:  "s",
+	}
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"Missing", "no property Missing"},
+		{"secret", "unexported field secret"},
+		{"Address.Missing.City", "no property Missing"},
+		{"Friends[5]", "out of range"},
+		{"Friends['x']", "invalid index"},
+		{"Name / 2", "invalid operands"},
+		{"Age / 0", "division by zero"},
+		{"#undefined", "undefined variable #undefined"},
+		{"nope(1)", "no method or function nope"},
+		{"Greet()", "wrong number of arguments"},
+		{"Greet(1)", "invalid argument 1"},
+		{"Fail()", "boom"},
+		{"-Name", "invalid operand"},
+	}
+	for _, test := range tests {
+		_, err := Eval(test.source, person)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected an *Error, got %v", test.source, err)
+			continue
+		}
+		if !strings.Contains(e.Message, test.message) {
+			t.Errorf("%s: expected %q in %q", test.source, test.message, e.Message)
+		}
+	}
+}
+
+func TestEvalAssignment(t *testing.T) {
+	tests := []struct {
+		source string
+		check  func(p *evalPerson) interface{}
+		value  interface{}
+	}{
+		{"Name = 'Bob'", func(p *evalPerson) interface{} { return p.Name }, "Bob"},
+		{"Age = 43", func(p *evalPerson) interface{} { return p.Age }, 43},
+		{"Score = Age", func(p *evalPerson) interface{} { return p.Score }, 42.0},
+		{"Ratio = 0.5", func(p *evalPerson) interface{} { return p.Ratio }, float32(0.5)},
+		{"Address.City = 'Oslo'", func(p *evalPerson) interface{} { return p.Address.City }, "Oslo"},
+		{"Friends[1].Age = 51", func(p *evalPerson) interface{} { return p.Friends[1].Age }, 51},
+		{"Tags['new'] = 'x'", func(p *evalPerson) interface{} { return p.Tags["new"] }, "x"},
+		{"Counts[2] = 20", func(p *evalPerson) interface{} { return p.Counts[2] }, 20},
+		{"Grid[0][1] = 9", func(p *evalPerson) interface{} { return p.Grid[0][1] }, 9},
+		{"Name = Tags.tier = 'silver'", func(p *evalPerson) interface{} { return p.Name + p.Tags["tier"] }, "silversilver"},
+	}
+	for _, test := range tests {
+		person := &evalPerson{
+			Name:    "Ann",
+			Age:     42,
+			Address: &evalAddress{},
+			Friends: []*evalPerson{{Name: "Bob"}, {Name: "Cy"}},
+			Tags:    map[string]string{"tier": "gold"},
+			Counts:  map[int]int{},
+		}
+		if _, err := Eval(test.source, person); err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		if actual := test.check(person); !reflect.DeepEqual(actual, test.value) {
+			t.Errorf("%s: expected %v, got %v", test.source, test.value, actual)
+		}
+	}
+
+	for _, source := range []string{"Age = 'x'", "Age = 1.5", "Ratio = 0.1", "secret = 'x'", "Missing = 1", "Initial = 1"} {
+		if _, err := Eval(source, &evalPerson{}); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+	// a root that is not a pointer is copied, and is not modified
+	person := evalPerson{Name: "Ann"}
+	if value, err := Eval("Name = 'x'", person); err != nil || value != "x" || person.Name != "Ann" {
+		t.Errorf("expected x on a copy of the root, got %v, %v, %s", value, err, person.Name)
+	}
+}
+
+func TestContext(t *testing.T) {
+	variables := NewContext()
+	variables.Set("a", 1)
+	if value, ok := variables.Get("a"); !ok || value != 1 {
+		t.Errorf("expected 1, got %v", value)
+	}
+	if _, ok := variables.Get("b"); ok {
+		t.Errorf("expected no variable b")
+	}
+	value, err := MustParse("#a + 1").Eval(nil, variables)
+	if err != nil || value != int64(2) {
+		t.Errorf("expected 2, got %v, %v", value, err)
+	}
+}
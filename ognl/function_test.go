@@ -0,0 +1,171 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type functionUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u *functionUser) Greet(name string) string {
+	return "hi " + name
+}
+
+func (u *functionUser) Upper() string {
+	return "method"
+}
+
+func TestFunctions(t *testing.T) {
+	variables := NewContext()
+	for name, function := range map[string]interface{}{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"contains": func(list []string, s string) bool {
+			for _, element := range list {
+				if element == s {
+					return true
+				}
+			}
+			return false
+		},
+		"join": func(separator string, parts ...string) string { return strings.Join(parts, separator) },
+		"sum": func(numbers ...int) int {
+			total := 0
+			for _, n := range numbers {
+				total += n
+			}
+			return total
+		},
+		"fail":   func() (string, error) { return "", errors.New("boom") },
+		"pair":   func() (int, int) { return 1, 2 },
+		"none":   func() {},
+		"sprint": fmt.Sprint,
+	} {
+		if err := variables.SetFunction(name, function); err != nil {
+			t.Fatal(err)
+		}
+	}
+	variables.Set("env", map[string]string{"stage": "prod"})
+	variables.Set("double", func(n int) int { return 2 * n })
+
+	tests := []struct {
+		source   string
+		expected interface{}
+		message  string
+	}{
+		{"upper(Name)", "ANN", ""},
+		{"#upper(Name) + #env.stage", "ANNprod", ""},
+		{"#upper('a') + #lower('B')", "Ab", ""},
+		{"Upper()", "method", ""},
+		{"contains(Roles, 'admin')", true, ""},
+		{"join('-', 'a', 'b')", "a-b", ""},
+		{"join('-')", "", ""},
+		{"sum(1, 2, 3)", 6, ""},
+		{"sum()", 0, ""},
+		{"Greet('x')", "hi x", ""},
+		{"#double(21)", 42, ""},
+		{"Roles.{upper(#this)}", []interface{}{"ADMIN", "DEV"}, ""},
+		{"Roles.{? contains(#root.Roles, #this) && #this != 'dev'}", []string{"admin"}, ""},
+		{"sprint(1, null, 'a', Name)", "1 <nil>aAnn", ""},
+		{"none()", nil, ""},
+		// errors
+		{"upper()", nil, "wrong number of arguments for upper: 0 instead of 1"},
+		{"upper(1, 2)", nil, "wrong number of arguments for upper: 2 instead of 1"},
+		{"join()", nil, "wrong number of arguments for join: 0 instead of at least 1"},
+		{"join('-', 'a', 1)", nil, "invalid argument 3 for join"},
+		{"upper({1})", nil, "invalid argument 1 for upper"},
+		{"nope(1)", nil, "no method or function nope"},
+		{"#nope(1)", nil, "undefined function #nope"},
+		{"#env(1)", nil, "undefined function #env"},
+		{"fail()", nil, "fail failed: boom"},
+		{"pair()", nil, "pair"},
+	}
+	for _, test := range tests {
+		value, err := evalBoth(t, test.source, &functionUser{Name: "Ann", Roles: []string{"admin", "dev"}}, variables)
+		if test.message != "" {
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Errorf("%s: expected an *Error, got %v", test.source, err)
+			} else if !strings.Contains(e.Message, test.message) {
+				t.Errorf("%s: expected %q in %q", test.source, test.message, e.Message)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+		} else if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", test.source, test.expected, test.expected, value, value)
+		}
+	}
+	if _, ok := variables.Function("sprint"); !ok {
+		t.Errorf("expected function sprint")
+	}
+	if _, ok := variables.Function("env"); ok {
+		t.Errorf("expected no function env")
+	}
+}
+
+func TestSetFunctionErrors(t *testing.T) {
+	for _, function := range []interface{}{1, "upper", (func())(nil), nil} {
+		if err := NewContext().SetFunction("f", function); err == nil {
+			t.Errorf("%#v: expected an error", function)
+		}
+	}
+}
+
+func TestContextSet(t *testing.T) {
+	variables := NewContext()
+	for _, name := range []string{"root", "this"} {
+		if err := variables.Set(name, 1); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, ok := variables.Get(name); ok {
+			t.Errorf("%s: expected no variable", name)
+		}
+	}
+	if err := variables.Set("x", 1); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if value, ok := variables.Get("x"); !ok || value != 1 {
+		t.Errorf("expected 1, got %v", value)
+	}
+}
+
+func TestFunctionSandbox(t *testing.T) {
+	tests := []struct {
+		sandbox Sandbox
+		source  string
+		denied  bool
+	}{
+		{Sandbox{DenyFunctions: []string{"upper"}}, "#upper(Name)", true},
+		{Sandbox{DenyFunctions: []string{"upper"}}, "upper(Name)", true},
+		{Sandbox{AllowFunctions: []string{"lower"}}, "upper(Name)", true},
+		{Sandbox{AllowFunctions: []string{"lower"}}, "lower(Name)", false},
+		{Sandbox{DenyFunctions: []string{"lower"}}, "upper(Name)", false},
+	}
+	for _, test := range tests {
+		variables := NewContext()
+		if err := variables.SetFunction("upper", strings.ToUpper); err != nil {
+			t.Fatal(err)
+		}
+		if err := variables.SetFunction("lower", strings.ToLower); err != nil {
+			t.Fatal(err)
+		}
+		sandbox := test.sandbox
+		variables.SetSandbox(&sandbox)
+		_, err := evalBoth(t, test.source, &functionUser{Name: "Ann"}, variables)
+		if denied := errors.Is(err, ErrDenied); denied != test.denied || !denied && err != nil {
+			t.Errorf("%s with %+v: expected denied %v, got %v", test.source, test.sandbox, test.denied, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a lexical token.
+type tokenKind int8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenVariable
+	tokenInteger
+	tokenFloat
+	tokenString
+	tokenOperator
+)
+
+// token is a lexical token; pos is its byte offset in the expression.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// String returns a description of the token for error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenVariable:
+		return "#" + t.text
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are the operators and punctuation, longest first so that the
+// lexer matches greedily.
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "=", "?", "^", "$", ":", ".", ",", "(", ")", "[", "]", "{", "}",
+}
+
+// lex splits an expression into tokens.
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(source); {
+		r, size := utf8.DecodeRuneInString(source[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case r == '_' || unicode.IsLetter(r):
+			end := scanIdentifier(source, pos)
+			tokens = append(tokens, token{kind: tokenIdentifier, text: source[pos:end], pos: pos})
+			pos = end
+
+		case r == '#':
+			end := scanIdentifier(source, pos+1)
+			if end == pos+1 {
+				return nil, newError(source, pos, "expected variable name after #")
+			}
+			tokens = append(tokens, token{kind: tokenVariable, text: source[pos+1 : end], pos: pos})
+			pos = end
+
+		case r >= '0' && r <= '9' || r == '.' && pos+1 < len(source) && isDigit(source[pos+1]) && !followsOperand(tokens):
+			t, err := scanNumber(source, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			pos += len(t.text)
+
+		case r == '"' || r == '\'' || r == '`':
+			t, end, err := scanString(source, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			pos = end
+
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[pos:], operator) {
+					tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+					pos += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, newError(source, pos, "unexpected character %q", r)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// followsOperand returns whether the last token ends an operand, in which
+// case a dot is a member access rather than the start of a number.
+func followsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind != tokenOperator || last.text == ")" || last.text == "]" || last.text == "}"
+}
+
+// isDigit returns whether a byte is a decimal digit.
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// scanIdentifier returns the end of the identifier starting at pos.
+func scanIdentifier(source string, pos int) int {
+	for pos < len(source) {
+		r, size := utf8.DecodeRuneInString(source[pos:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// scanNumber scans an integer (decimal, hexadecimal, octal or binary, with
+// optional underscores) or a floating point number.
+func scanNumber(source string, pos int) (token, error) {
+	end := pos
+	float := false
+	if strings.HasPrefix(source[pos:], "0x") || strings.HasPrefix(source[pos:], "0X") ||
+		strings.HasPrefix(source[pos:], "0b") || strings.HasPrefix(source[pos:], "0B") ||
+		strings.HasPrefix(source[pos:], "0o") || strings.HasPrefix(source[pos:], "0O") {
+		end += 2
+		for end < len(source) && (isHex(source[end]) || source[end] == '_') {
+			end++
+		}
+	} else {
+		for end < len(source) && (isDigit(source[end]) || source[end] == '_') {
+			end++
+		}
+		if end < len(source) && source[end] == '.' && end+1 < len(source) && isDigit(source[end+1]) {
+			float = true
+			end++
+			for end < len(source) && (isDigit(source[end]) || source[end] == '_') {
+				end++
+			}
+		}
+		if end < len(source) && (source[end] == 'e' || source[end] == 'E') {
+			exponent := end + 1
+			if exponent < len(source) && (source[exponent] == '+' || source[exponent] == '-') {
+				exponent++
+			}
+			if exponent < len(source) && isDigit(source[exponent]) {
+				float = true
+				end = exponent
+				for end < len(source) && isDigit(source[end]) {
+					end++
+				}
+			}
+		}
+	}
+	text := source[pos:end]
+	if float {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return token{}, newError(source, pos, "invalid number %s", text)
+		}
+		return token{kind: tokenFloat, text: text, pos: pos}, nil
+	}
+	if _, err := strconv.ParseInt(text, 0, 64); err != nil {
+		return token{}, newError(source, pos, "invalid integer %s", text)
+	}
+	return token{kind: tokenInteger, text: text, pos: pos}, nil
+}
+
+// isHex returns whether a byte is a hexadecimal digit.
+func isHex(b byte) bool {
+	return isDigit(b) || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F'
+}
+
+// scanString scans a string literal: double-quoted and single-quoted ones
+// support Go escape sequences, back-quoted ones are raw.
+func scanString(source string, pos int) (token, int, error) {
+	quote := source[pos]
+	end := pos + 1
+	for end < len(source) && source[end] != quote {
+		if source[end] == '\\' && quote != '`' {
+			end++
+		}
+		end++
+	}
+	if end >= len(source) {
+		return token{}, 0, newError(source, pos, "unterminated string")
+	}
+	literal := source[pos : end+1]
+	if quote == '\'' {
+		literal = requote(literal)
+	}
+	text, err := strconv.Unquote(literal)
+	if err != nil {
+		return token{}, 0, newError(source, pos, "invalid string %s", source[pos:end+1])
+	}
+	return token{kind: tokenString, text: text, pos: pos}, end + 1, nil
+}
+
+// requote turns a single-quoted literal into a double-quoted one.
+func requote(literal string) string {
+	var buffer strings.Builder
+	buffer.WriteByte('"')
+	for i := 1; i < len(literal)-1; i++ {
+		switch {
+		case literal[i] == '\\' && literal[i+1] == '\'':
+			buffer.WriteByte('\'')
+			i++
+		case literal[i] == '\\':
+			buffer.WriteString(literal[i : i+2])
+			i++
+		case literal[i] == '"':
+			buffer.WriteString(`\"`)
+		default:
+			buffer.WriteByte(literal[i])
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
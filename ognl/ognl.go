@@ -0,0 +1,232 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package ognl implements an expression language in the spirit of OGNL
+// (Object Graph Navigation Language) on top of reflector: expressions are
+// parsed into an abstract syntax tree, then evaluated against a root object
+// and a context of variables.
+//
+// Expressions support literals (integers, floats, strings in single, double
+// or back quotes, true, false and null), lists ({1, 2, 3}), property
+// navigation (a.b.c, where the first name is a property of the root
+// object), indexing of lists, strings, maps and objects (a[1], m["key"],
+// o["Name"]), calls of exported methods (a.f(1, "x") or f() on the root
+// object), variables (#name, with #root and #this), arithmetic (+, -, *, /,
+// %), string concatenation (+ with a string operand), comparison (==, !=, <,
+// <=, >, >=, or eq, neq, lt, lte, gt, gte), boolean logic (&&, ||, !, or
+// and, or, not) and the ternary operator (a ? b : c).
+//
+// Slices, arrays and maps support selection (items.{? price > 10}), which
+// returns the elements satisfying a condition, the first (items.{^ ...}) or
+// last (items.{$ ...}) of them in a slice of at most one element, and
+// projection (items.{name}), which returns the value of an expression on
+// each element; the condition and the expression are evaluated with the
+// element as #this, and the entries of maps are visited as Entry values in
+// key order.
+//
+// Expressions evaluated repeatedly against objects of the same type can be
+// compiled for that type with Compile, which checks their properties and
+// methods in advance and caches the result.
+//
+// Assignments (a.b = value) set exported fields, entries of maps and
+// elements of slices and arrays; the root object must be a pointer for its
+// own fields to be modified. Expressions supplied by users can be confined by
+// a Sandbox set on the Context, which restricts the types, fields and methods
+// they can reach, forbids side effects in read-only mode and limits the steps
+// and allocations of the evaluation; EvalContext also stops the evaluation
+// when a context.Context is done.
+//
+// Functions registered in the Context with SetFunction can be called as
+// #name(args), or as name(args) where the current object has no method with
+// that name; #name(args) also calls a function held in a variable. Arguments
+// are converted to the types of the parameters, and a function returning a
+// value and an error fails if the error is not nil.
+//
+// Integers are evaluated as int64 and floats as float64; in boolean
+// contexts nil, false, zero numbers and empty strings, lists and maps are
+// false, everything else is true. Only exported fields and methods can be
+// accessed.
+package ognl
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expression is a parsed expression.
+type Expression struct {
+	source string
+	root   Node
+}
+
+// Parse parses an expression; expressions nested more than 1000 levels deep
+// are rejected, so that untrusted input cannot exhaust the stack.
+func Parse(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{source: source, tokens: tokens}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// MustParse parses an expression, and panics if it is invalid.
+func MustParse(source string) *Expression {
+	expression, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return expression
+}
+
+// Source returns the source of the expression.
+func (e *Expression) Source() string {
+	return e.source
+}
+
+// AST returns the root of the abstract syntax tree of the expression.
+func (e *Expression) AST() Node {
+	return e.root
+}
+
+// String returns the canonical source of the expression, with explicit
+// parentheses.
+func (e *Expression) String() string {
+	return e.root.String()
+}
+
+// Eval evaluates the expression against a root object, with the variables
+// and the sandbox in the context, which can be nil.
+func (e *Expression) Eval(root interface{}, variables *Context) (interface{}, error) {
+	return e.EvalContext(context.Background(), root, variables)
+}
+
+// EvalContext is like Eval, but fails as soon as ctx is done.
+func (e *Expression) EvalContext(ctx context.Context, root interface{}, variables *Context) (interface{}, error) {
+	ev := newEvaluator(ctx, e.source, root, variables)
+	value, err := ev.eval(e.root, root)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Eval parses an expression and evaluates it against a root object.
+func Eval(source string, root interface{}) (interface{}, error) {
+	expression, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return expression.Eval(root, nil)
+}
+
+// Context holds the variables and functions available to expressions, and
+// the sandbox that confines them.
+type Context struct {
+	variables map[string]interface{}
+	functions map[string]reflect.Value
+	sandbox   *Sandbox
+}
+
+// NewContext returns a new, empty Context.
+func NewContext() *Context {
+	return &Context{
+		variables: map[string]interface{}{},
+		functions: map[string]reflect.Value{},
+	}
+}
+
+// Set sets a variable; it fails if name is root or this, which are reserved.
+func (c *Context) Set(name string, value interface{}) error {
+	if name == "root" || name == "this" {
+		return fmt.Errorf("ognl: variable name %s is reserved", name)
+	}
+	c.variables[name] = value
+	return nil
+}
+
+// Get returns the value of a variable, and whether it is set.
+func (c *Context) Get(name string) (interface{}, bool) {
+	value, ok := c.variables[name]
+	return value, ok
+}
+
+// SetFunction registers a function, which can be any non-nil Go function
+// (e.g. strings.ToUpper); it fails if function is not a function.
+func (c *Context) SetFunction(name string, function interface{}) error {
+	value := reflect.ValueOf(function)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return fmt.Errorf("ognl: function %s is %T, not a function", name, function)
+	}
+	c.functions[name] = value
+	return nil
+}
+
+// Function returns a registered function, and whether it is registered.
+func (c *Context) Function(name string) (interface{}, bool) {
+	function, ok := c.functions[name]
+	if !ok {
+		return nil, false
+	}
+	return function.Interface(), true
+}
+
+// SetSandbox sets the sandbox of the evaluations, nil for none.
+func (c *Context) SetSandbox(sandbox *Sandbox) {
+	c.sandbox = sandbox
+}
+
+// Sandbox returns the sandbox of the evaluations, nil if none.
+func (c *Context) Sandbox() *Sandbox {
+	return c.sandbox
+}
+
+// Error is an error in the parsing or evaluation of an expression.
+type Error struct {
+	// Expression is the source of the expression.
+	Expression string
+	// Column is the (1-based) column of the problem in the expression,
+	// counted in characters.
+	Column int
+	// Message is the description of the problem.
+	Message string
+	// Err is the underlying error, if any (e.g. ErrDenied, ErrLimit or the
+	// error of the context of the evaluation).
+	Err error
+}
+
+// newError returns a new Error at a byte offset in the expression.
+func newError(source string, pos int, format string, args ...interface{}) *Error {
+	if pos > len(source) {
+		pos = len(source)
+	}
+	return &Error{
+		Expression: source,
+		Column:     utf8.RuneCountInString(source[:pos]) + 1,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("ognl: %s at column %d of %q", e.Message, e.Column, e.Expression)
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Caret returns the expression with a caret under the column of the
+// problem, on the following line.
+func (e *Error) Caret() string {
+	return e.Expression + "\n" + strings.Repeat(" ", e.Column-1) + "^"
+}
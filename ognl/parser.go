@@ -0,0 +1,411 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"strconv"
+)
+
+// parser is a recursive descent parser; the grammar, from the lowest
+// precedence to the highest, is:
+//
+//	expression  = conditional [ "=" expression ]
+//	conditional = or [ "?" expression ":" expression ]
+//	or          = and { ( "||" | "or" ) and }
+//	and         = equality { ( "&&" | "and" ) equality }
+//	equality    = comparison { ( "==" | "!=" | "eq" | "neq" ) comparison }
+//	comparison  = additive { ( "<" | "<=" | ">" | ">=" | "lt" | "lte" | "gt" | "gte" ) additive }
+//	additive    = product { ( "+" | "-" ) product }
+//	product     = unary { ( "*" | "/" | "%" ) unary }
+//	unary       = ( "!" | "not" | "-" | "+" ) unary | postfix
+//	postfix     = primary { "." name [ arguments ] | "." collection | "[" expression "]" }
+//	collection  = "{" [ "?" | "^" | "$" ] expression "}"
+//	primary     = literal | name [ arguments ] | "#" name [ arguments ] | "(" expression ")"
+//	            | "{" [ expression { "," expression } ] "}"
+//	arguments   = "(" [ expression { "," expression } ] ")"
+type parser struct {
+	source string
+	tokens []token
+	next   int
+	depth  int
+}
+
+// maxDepth is the maximum nesting depth of the syntax tree of an
+// expression, which bounds the recursion of the parser and of the
+// evaluation.
+const maxDepth = 1000
+
+// keywords are the operators that can be written as words, and their
+// symbolic equivalents.
+var keywords = map[string]string{
+	"or":  "||",
+	"and": "&&",
+	"not": "!",
+	"eq":  "==",
+	"neq": "!=",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// peek returns the next token.
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// advance consumes the next token and returns it.
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// is returns whether the next token is the given operator (or its keyword
+// equivalent).
+func (p *parser) is(operators ...string) (string, bool) {
+	t := p.peek()
+	text := t.text
+	switch t.kind {
+	case tokenOperator:
+	case tokenIdentifier:
+		if text = keywords[t.text]; text == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	for _, operator := range operators {
+		if text == operator {
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+// expect consumes the given operator, or fails.
+func (p *parser) expect(operator string) (token, error) {
+	if _, ok := p.is(operator); !ok {
+		return token{}, newError(p.source, p.peek().pos, "expected %q, found %s", operator, p.peek())
+	}
+	return p.advance(), nil
+}
+
+// nest counts a level of nesting of the syntax tree at a token, and fails
+// if the expression is nested too deeply; the caller restores the depth when
+// done with the level.
+func (p *parser) nest(t token) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return newError(p.source, t.pos, "expression nested more than %d levels deep", maxDepth)
+	}
+	return nil
+}
+
+// parse parses a whole expression.
+func (p *parser) parse() (Node, error) {
+	node, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, newError(p.source, t.pos, "unexpected %s", t)
+	}
+	return node, nil
+}
+
+func (p *parser) expression() (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.nest(p.peek()); err != nil {
+		return nil, err
+	}
+	target, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.is("="); !ok {
+		return target, nil
+	}
+	t := p.advance()
+	switch target.(type) {
+	case *Property, *Member, *Index:
+	default:
+		return nil, newError(p.source, t.pos, "cannot assign to %s", target)
+	}
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &Assignment{Offset: t.pos, Target: target, Value: value}, nil
+}
+
+func (p *parser) conditional() (Node, error) {
+	condition, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.is("?"); !ok {
+		return condition, nil
+	}
+	t := p.advance()
+	then, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &Conditional{Offset: t.pos, Condition: condition, Then: then, Else: otherwise}, nil
+}
+
+// binary parses a left-associative sequence of operands and operators.
+func (p *parser) binary(operand func() (Node, error), operators ...string) (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.is(operators...)
+		if !ok {
+			return left, nil
+		}
+		t := p.advance()
+		if err := p.nest(t); err != nil {
+			return nil, err
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Offset: t.pos, Op: operator, Left: left, Right: right}
+	}
+}
+
+func (p *parser) or() (Node, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *parser) and() (Node, error) {
+	return p.binary(p.equality, "&&")
+}
+
+func (p *parser) equality() (Node, error) {
+	return p.binary(p.comparison, "==", "!=")
+}
+
+func (p *parser) comparison() (Node, error) {
+	return p.binary(p.additive, "<", "<=", ">", ">=")
+}
+
+func (p *parser) additive() (Node, error) {
+	return p.binary(p.product, "+", "-")
+}
+
+func (p *parser) product() (Node, error) {
+	return p.binary(p.unary, "*", "/", "%")
+}
+
+func (p *parser) unary() (Node, error) {
+	if operator, ok := p.is("!", "-", "+"); ok {
+		defer func(depth int) { p.depth = depth }(p.depth)
+		t := p.advance()
+		if err := p.nest(t); err != nil {
+			return nil, err
+		}
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Offset: t.pos, Op: operator, Operand: operand}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (Node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	node, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.is(".", "["); ok {
+			if err := p.nest(p.peek()); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := p.is("."); ok {
+			p.advance()
+			if _, ok := p.is("{"); ok {
+				if node, err = p.collection(node); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			name := p.peek()
+			if name.kind != tokenIdentifier {
+				return nil, newError(p.source, name.pos, "expected property name, found %s", name)
+			}
+			p.advance()
+			if _, ok := p.is("("); ok {
+				args, err := p.arguments()
+				if err != nil {
+					return nil, err
+				}
+				node = &Call{Offset: name.pos, Target: node, Name: name.text, Args: args}
+			} else {
+				node = &Member{Offset: name.pos, Target: node, Name: name.text}
+			}
+		} else if _, ok := p.is("["); ok {
+			t := p.advance()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &Index{Offset: t.pos, Target: node, Index: index}
+		} else {
+			return node, nil
+		}
+	}
+}
+
+// collection parses a selection (with "?", "^" or "$") or a projection on
+// a collection, after the dot.
+func (p *parser) collection(target Node) (Node, error) {
+	t := p.advance()
+	mode, selection := p.is("?", "^", "$")
+	if selection {
+		p.advance()
+	}
+	expression, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	if selection {
+		return &Selection{Offset: t.pos, Target: target, Mode: mode, Condition: expression}, nil
+	}
+	return &Projection{Offset: t.pos, Target: target, Expression: expression}, nil
+}
+
+func (p *parser) primary() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenInteger:
+		p.advance()
+		value, _ := strconv.ParseInt(t.text, 0, 64)
+		return &Literal{Offset: t.pos, Value: value}, nil
+
+	case tokenFloat:
+		p.advance()
+		value, _ := strconv.ParseFloat(t.text, 64)
+		return &Literal{Offset: t.pos, Value: value}, nil
+
+	case tokenString:
+		p.advance()
+		return &Literal{Offset: t.pos, Value: t.text}, nil
+
+	case tokenVariable:
+		p.advance()
+		if _, ok := p.is("("); ok {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			return &Function{Offset: t.pos, Name: t.text, Args: args}, nil
+		}
+		return &Variable{Offset: t.pos, Name: t.text}, nil
+
+	case tokenIdentifier:
+		switch t.text {
+		case "true", "false":
+			p.advance()
+			return &Literal{Offset: t.pos, Value: t.text == "true"}, nil
+		case "null", "nil":
+			p.advance()
+			return &Literal{Offset: t.pos}, nil
+		}
+		if _, ok := keywords[t.text]; ok {
+			return nil, newError(p.source, t.pos, "unexpected %s", t)
+		}
+		p.advance()
+		if _, ok := p.is("("); ok {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			return &Call{Offset: t.pos, Name: t.text, Args: args}, nil
+		}
+		return &Property{Offset: t.pos, Name: t.text}, nil
+
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			p.advance()
+			node, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		case "{":
+			p.advance()
+			elements, err := p.list("}")
+			if err != nil {
+				return nil, err
+			}
+			return &List{Offset: t.pos, Elements: elements}, nil
+		}
+	}
+	if t.kind == tokenEOF {
+		return nil, newError(p.source, t.pos, "unexpected end of expression")
+	}
+	return nil, newError(p.source, t.pos, "unexpected %s", t)
+}
+
+// arguments parses the arguments of a call, in parentheses.
+func (p *parser) arguments() ([]Node, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	return p.list(")")
+}
+
+// list parses a comma-separated list of expressions, up to the closing
+// operator.
+func (p *parser) list(closing string) ([]Node, error) {
+	var nodes []Node
+	if _, ok := p.is(closing); ok {
+		p.advance()
+		return nodes, nil
+	}
+	for {
+		node, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if _, ok := p.is(closing); ok {
+			p.advance()
+			return nodes, nil
+		}
+		if _, err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"42", "42"},
+		{"0x1f", "31"},
+		{"1.5", "1.5"},
+		{"2.0", "2.0"},
+		{`'a' + "b" + ` + "`c`", `(("a" + "b") + "c")`},
+		{"true && null", "(true && null)"},
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"1 - 2 - 3", "((1 - 2) - 3)"},
+		{"a or b and c", "(a || (b && c))"},
+		{"not a eq b", "(!a == b)"},
+		{"a lt 1 || b gte 2", "((a < 1) || (b >= 2))"},
+		{"a == 1 != b < 2", "((a == 1) != (b < 2))"},
+		{"-a.b[1]", "-a.b[1]"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a.b.c", "a.b.c"},
+		{"a['k'].f(1, x)", `a["k"].f(1, x)`},
+		{"f()", "f()"},
+		{"#root.Name", "#root.Name"},
+		{"#upper(Name)", "#upper(Name)"},
+		{"{1, 'a', {}}", `{1, "a", {}}`},
+		{"items.{? price > 10}", "items.{? (price > 10)}"},
+		{"items.{^ #this}", "items.{^ #this}"},
+		{"items.{$ #this}", "items.{$ #this}"},
+		{"items.{name}", "items.{name}"},
+		{"a.b = c = 1", "(a.b = (c = 1))"},
+		{"  a  ", "a"},
+	}
+	for _, test := range tests {
+		expression, err := Parse(test.source)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+			continue
+		}
+		if actual := expression.String(); actual != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.source, test.expected, actual)
+		}
+		if expression.Source() != test.source {
+			t.Errorf("%s: wrong source %s", test.source, expression.Source())
+		}
+		// the canonical form parses to itself
+		if again, err := Parse(test.expected); err != nil || again.String() != test.expected {
+			t.Errorf("%s: canonical form does not round trip: %v", test.expected, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		column int
+	}{
+		{"", 1},
+		{"1 +", 4},
+		{"(1", 3},
+		{"a.", 3},
+		{"a.1", 3},
+		{"a[1", 4},
+		{"f(1,", 5},
+		{"1 2", 3},
+		{"'abc", 1},
+		{"a ? b", 6},
+		{"1 = 2", 3},
+		{"a + b = 1", 7},
+		{"and", 1},
+		{"é @", 3},
+		{"items.{? }", 10},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.source)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%q: expected an *Error, got %v", test.source, err)
+			continue
+		}
+		if e.Column != test.column {
+			t.Errorf("%q: expected column %d, got %d (%v)", test.source, test.column, e.Column, e)
+		}
+		if e.Expression != test.source {
+			t.Errorf("%q: wrong expression %q", test.source, e.Expression)
+		}
+	}
+}
+
+func TestErrorCaret(t *testing.T) {
+	_, err := Parse("1 + * 2")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if expected := "1 + * 2\n    ^"; e.Caret() != expected {
+		t.Errorf("expected %q, got %q", expected, e.Caret())
+	}
+}
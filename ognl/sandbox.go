@@ -0,0 +1,254 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"errors"
+	"path"
+	"reflect"
+)
+
+var (
+	// ErrDenied is the underlying error when an expression reaches a type,
+	// field or method that the sandbox does not allow, or tries to modify
+	// something in read-only mode.
+	ErrDenied = errors.New("ognl: access denied")
+	// ErrLimit is the underlying error when an expression exceeds the limits
+	// of the sandbox.
+	ErrLimit = errors.New("ognl: limit exceeded")
+)
+
+// Sandbox restricts what expressions can reach and do, and how much work
+// they can do; it is set on the Context of the evaluation.
+//
+// Types, fields and methods are matched against lists of patterns (see
+// path.Match, where brackets must be escaped): types by their name (e.g.
+// "main.User" or `map\[string\]*`), fields and methods by their name
+// qualified by the name of their type (e.g. "main.User.Password" or
+// "*.Password"). Pointers are dereferenced before matching. If an allow list
+// is empty, everything it would check is allowed; deny lists take precedence
+// over allow lists.
+type Sandbox struct {
+	// AllowTypes lists the types whose fields, elements and methods can be
+	// accessed.
+	AllowTypes []string
+	// DenyTypes lists the types whose values, fields, elements and methods
+	// cannot be accessed; values holding them (e.g. slices or maps) cannot
+	// be accessed either.
+	DenyTypes []string
+	// AllowFields lists the fields that can be accessed.
+	AllowFields []string
+	// DenyFields lists the fields that cannot be accessed.
+	DenyFields []string
+	// AllowMethods lists the methods that can be called.
+	AllowMethods []string
+	// DenyMethods lists the methods that cannot be called.
+	DenyMethods []string
+	// AllowFunctions lists the functions of the context that can be called,
+	// by name.
+	AllowFunctions []string
+	// DenyFunctions lists the functions of the context that cannot be
+	// called, by name.
+	DenyFunctions []string
+	// ReadOnly forbids assignments, and calls of methods that are not
+	// explicitly listed in AllowMethods, as they might have side effects;
+	// functions are registered by the application, and are not affected.
+	ReadOnly bool
+	// MaxSteps is the maximum number of nodes of the expression that can be
+	// evaluated, counting each evaluation; 0 means no limit.
+	MaxSteps int
+	// MaxAllocations is the maximum number of values the evaluation can
+	// create (elements of lists, selections and projections and entries of
+	// maps count one each), plus the bytes of the strings it concatenates;
+	// 0 means no limit.
+	MaxAllocations int
+}
+
+// matches returns whether a name matches any of the patterns.
+func matches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns whether a name passes an allow and a deny list.
+func allowed(allow []string, deny []string, name string) bool {
+	return (len(allow) == 0 || matches(allow, name)) && !matches(deny, name)
+}
+
+// sandboxName returns the name of a type as matched by the sandbox.
+func sandboxName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.String()
+}
+
+// receiverType returns the type a method is looked up on, that of the
+// concrete value held in an interface.
+func receiverType(target reflect.Value) reflect.Type {
+	if target.Kind() == reflect.Interface && !target.IsNil() {
+		return target.Elem().Type()
+	}
+	return target.Type()
+}
+
+// step counts the evaluation of a node, and fails if the evaluation exceeds
+// the step limit or its context is done.
+func (ev *evaluator) step(node Node) error {
+	if ev.sandbox == nil && ev.done == nil {
+		return nil
+	}
+	ev.steps++
+	if ev.sandbox != nil && ev.sandbox.MaxSteps > 0 && ev.steps > ev.sandbox.MaxSteps {
+		return ev.failWith(node, ErrLimit, "step limit of %d exceeded", ev.sandbox.MaxSteps)
+	}
+	if ev.done != nil {
+		select {
+		case <-ev.done:
+			err := ev.ctx.Err()
+			return ev.failWith(node, err, "evaluation interrupted: %v", err)
+		default:
+		}
+	}
+	return nil
+}
+
+// allocate counts the creation of values, and fails if the evaluation
+// exceeds the allocation limit.
+func (ev *evaluator) allocate(node Node, n int) error {
+	if ev.sandbox == nil || ev.sandbox.MaxAllocations <= 0 {
+		return nil
+	}
+	ev.allocations += n
+	if ev.allocations > ev.sandbox.MaxAllocations {
+		return ev.failWith(node, ErrLimit, "allocation limit of %d exceeded", ev.sandbox.MaxAllocations)
+	}
+	return nil
+}
+
+// checkType fails if the sandbox does not allow access to values of a type.
+func (ev *evaluator) checkType(node Node, typ reflect.Type) error {
+	if ev.sandbox == nil {
+		return nil
+	}
+	if name := sandboxName(typ); !allowed(ev.sandbox.AllowTypes, ev.sandbox.DenyTypes, name) {
+		return ev.failWith(node, ErrDenied, "access to type %s denied", name)
+	}
+	return nil
+}
+
+// checkResult fails if the sandbox denies access to the type of a value an
+// expression reaches (that of the value held, for interfaces), or to the
+// types of the elements, keys or values it can hold.
+func (ev *evaluator) checkResult(node Node, value reflect.Value) error {
+	if ev.sandbox == nil || len(ev.sandbox.DenyTypes) == 0 || !value.IsValid() {
+		return nil
+	}
+	typ := value.Type()
+	if held := indirect(value); held.IsValid() {
+		typ = held.Type()
+	}
+	return ev.checkDenied(node, typ, map[reflect.Type]bool{})
+}
+
+// checkDenied fails if a type, or the type of its elements, keys or values,
+// is denied by the sandbox.
+func (ev *evaluator) checkDenied(node Node, typ reflect.Type, visited map[reflect.Type]bool) error {
+	for !visited[typ] {
+		visited[typ] = true
+		if name := sandboxName(typ); matches(ev.sandbox.DenyTypes, name) {
+			return ev.failWith(node, ErrDenied, "access to type %s denied", name)
+		}
+		switch typ.Kind() {
+		case reflect.Map:
+			if err := ev.checkDenied(node, typ.Key(), visited); err != nil {
+				return err
+			}
+			typ = typ.Elem()
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			typ = typ.Elem()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkField fails if the sandbox does not allow access to a field of a
+// struct type; a promoted field is also checked along the chain of embedded
+// structs it is reached through, up to the struct that declares it.
+func (ev *evaluator) checkField(node Node, typ reflect.Type, field reflect.StructField) error {
+	if ev.sandbox == nil {
+		return nil
+	}
+	if err := ev.checkFieldName(node, typ, field.Name); err != nil {
+		return err
+	}
+	if len(field.Index) == 1 {
+		return nil
+	}
+	for _, index := range field.Index {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		embedded := typ.Field(index)
+		if err := ev.checkFieldName(node, typ, embedded.Name); err != nil {
+			return err
+		}
+		typ = embedded.Type
+	}
+	return nil
+}
+
+// checkFieldName fails if the sandbox does not allow access to a type, or
+// to a field of it by name.
+func (ev *evaluator) checkFieldName(node Node, typ reflect.Type, field string) error {
+	if err := ev.checkType(node, typ); err != nil {
+		return err
+	}
+	if name := sandboxName(typ) + "." + field; !allowed(ev.sandbox.AllowFields, ev.sandbox.DenyFields, name) {
+		return ev.failWith(node, ErrDenied, "access to field %s denied", name)
+	}
+	return nil
+}
+
+// checkMethod fails if the sandbox does not allow calling a method.
+func (ev *evaluator) checkMethod(node Node, typ reflect.Type, method string) error {
+	if ev.sandbox == nil {
+		return nil
+	}
+	if err := ev.checkType(node, typ); err != nil {
+		return err
+	}
+	name := sandboxName(typ) + "." + method
+	if ev.sandbox.ReadOnly && !matches(ev.sandbox.AllowMethods, name) {
+		return ev.failWith(node, ErrDenied, "call of method %s denied in read-only mode", name)
+	}
+	if !allowed(ev.sandbox.AllowMethods, ev.sandbox.DenyMethods, name) {
+		return ev.failWith(node, ErrDenied, "call of method %s denied", name)
+	}
+	return nil
+}
+
+// checkFunction fails if the sandbox does not allow calling a function of
+// the context.
+func (ev *evaluator) checkFunction(node Node, name string) error {
+	if ev.sandbox != nil && !allowed(ev.sandbox.AllowFunctions, ev.sandbox.DenyFunctions, name) {
+		return ev.failWith(node, ErrDenied, "call of function %s denied", name)
+	}
+	return nil
+}
+
+// checkAssignment fails if the sandbox does not allow assignments.
+func (ev *evaluator) checkAssignment(node Node) error {
+	if ev.sandbox != nil && ev.sandbox.ReadOnly {
+		return ev.failWith(node, ErrDenied, "assignment denied in read-only mode")
+	}
+	return nil
+}
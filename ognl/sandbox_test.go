@@ -0,0 +1,209 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ognl
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type sandboxCredentials struct {
+	Login    string
+	Password string
+}
+
+type sandboxAccount struct {
+	*sandboxCredentials
+	Balance int
+}
+
+type sandboxUser struct {
+	Name string
+	sandboxAccount
+	Roles   []string
+	Meta    map[string]string
+	Big     string
+	Level   zzLevel
+	Counter *zzCounter
+	S       zzSecret
+	Others  []zzSecret
+	Any     interface{}
+}
+
+// zzCounter counts the calls to its String method.
+type zzCounter struct {
+	N int
+}
+
+func (c *zzCounter) String() string {
+	c.N++
+	return "counted"
+}
+
+// zzLevel is a basic type with a String method.
+type zzLevel int
+
+func (l zzLevel) String() string {
+	return "level " + strconv.Itoa(int(l))
+}
+
+type zzSecret struct {
+	Value string
+}
+
+func (u *sandboxUser) Rename(name string) string {
+	u.Name = name
+	return name
+}
+
+func (u *sandboxUser) Greeting() string {
+	return "hello " + u.Name
+}
+
+// evalBoth evaluates an expression both parsed and compiled for the type of
+// the root, and fails the test if the results differ.
+func evalBoth(t *testing.T, source string, root interface{}, variables *Context) (interface{}, error) {
+	t.Helper()
+	expression, err := Parse(source)
+	if err != nil {
+		t.Fatalf("%s: unexpected error %v", source, err)
+	}
+	value, err := expression.Eval(root, variables)
+	compiled, cerr := Compile(source, reflect.TypeOf(root))
+	if cerr != nil {
+		t.Fatalf("%s: unexpected error compiling %v", source, cerr)
+	}
+	cvalue, cerr := compiled.Eval(root, variables)
+	if !reflect.DeepEqual(value, cvalue) || (err == nil) != (cerr == nil) {
+		t.Errorf("%s: parsed gives %v, %v, compiled gives %v, %v", source, value, err, cvalue, cerr)
+	}
+	return value, err
+}
+
+func TestSandbox(t *testing.T) {
+	tests := []struct {
+		sandbox  Sandbox
+		source   string
+		expected interface{}
+		err      error
+	}{
+		// denied
+		{Sandbox{DenyFields: []string{"*.Password"}}, "Password", nil, ErrDenied},
+		{Sandbox{DenyFields: []string{"*.Password"}}, "#root.Password", nil, ErrDenied},
+		{Sandbox{DenyFields: []string{"ognl.sandboxCredentials.Password"}}, "Password", nil, ErrDenied},
+		{Sandbox{DenyFields: []string{"ognl.sandboxUser.Password"}}, "Password", nil, ErrDenied},
+		{Sandbox{DenyFields: []string{"ognl.sandboxAccount.sandboxCredentials"}}, "Login", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.sandboxCredentials"}}, "Password", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.sandboxAccount"}}, "Login", nil, ErrDenied},
+		{Sandbox{AllowFields: []string{"ognl.sandboxUser.Name"}}, "Balance", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{`map\[string\]string`}}, "Meta.team", nil, ErrDenied},
+		{Sandbox{DenyMethods: []string{"*.Greeting"}}, "Greeting()", nil, ErrDenied},
+		{Sandbox{ReadOnly: true}, "Rename('bob')", nil, ErrDenied},
+		{Sandbox{ReadOnly: true}, "Name = 'bob'", nil, ErrDenied},
+		{Sandbox{ReadOnly: true}, "Meta.team = 'x'", nil, ErrDenied},
+		{Sandbox{ReadOnly: true, DenyTypes: []string{"ognl.zzCounter"}, DenyMethods: []string{"*.String"}}, "'' + Counter", nil, ErrDenied},
+		{Sandbox{}, "'' + Counter", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "S", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "Others", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "S.Value", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "Others[0]", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "Any", nil, ErrDenied},
+		{Sandbox{DenyTypes: []string{"ognl.zzSecret"}}, "Others.{#this}", nil, ErrDenied},
+		// allowed
+		{Sandbox{DenyFields: []string{"*.Password"}}, "Login", "ann", nil},
+		{Sandbox{DenyFields: []string{"*.Password"}}, "Balance + 1", int64(11), nil},
+		{Sandbox{AllowFields: []string{"ognl.sandboxUser.Name"}}, "Name", "ann", nil},
+		{Sandbox{ReadOnly: true, AllowMethods: []string{"*.Greeting"}}, "Greeting()", "hello ann", nil},
+		{Sandbox{ReadOnly: true}, "Roles.{? #this != 'dev'}", []string{"admin"}, nil},
+		{Sandbox{MaxSteps: 100, MaxAllocations: 20}, "Roles.{#this + '!'}", []interface{}{"admin!", "dev!"}, nil},
+		{Sandbox{MaxAllocations: 6}, "Name + Name", "annann", nil},
+		{Sandbox{}, "'' + Counter.N + Level + 1.5 + true", "021.5true", nil},
+		{Sandbox{DenyTypes: []string{"ognl.zzCounter"}}, "Level", zzLevel(2), nil},
+		// limits
+		{Sandbox{MaxSteps: 5}, "1 + 2 + 3 + 4 + 5 + 6", nil, ErrLimit},
+		{Sandbox{MaxSteps: 10}, "Roles.{#this}.{#this}.{#this}.{#this}.{#this}", nil, ErrLimit},
+		{Sandbox{MaxAllocations: 3}, "{1, 2, 3, 4}", nil, ErrLimit},
+		{Sandbox{MaxAllocations: 1}, "Roles.{#this}", nil, ErrLimit},
+		{Sandbox{MaxAllocations: 2}, "Name + Name + Name + Name", nil, ErrLimit},
+		{Sandbox{MaxAllocations: 100}, "Big + Big + Big + Big", nil, ErrLimit},
+	}
+	for _, test := range tests {
+		user := &sandboxUser{
+			Name: "ann",
+			sandboxAccount: sandboxAccount{
+				sandboxCredentials: &sandboxCredentials{Login: "ann", Password: "secret"},
+				Balance:            10,
+			},
+			Roles:   []string{"admin", "dev"},
+			Meta:    map[string]string{"team": "core"},
+			Big:     strings.Repeat("x", 40),
+			Level:   2,
+			Counter: &zzCounter{},
+			S:       zzSecret{Value: "s"},
+			Others:  []zzSecret{{Value: "o"}},
+			Any:     zzSecret{Value: "a"},
+		}
+		variables := NewContext()
+		sandbox := test.sandbox
+		variables.SetSandbox(&sandbox)
+		value, err := evalBoth(t, test.source, user, variables)
+		switch {
+		case test.err != nil && !errors.Is(err, test.err):
+			t.Errorf("%s with %+v: expected %v, got %v", test.source, test.sandbox, test.err, err)
+		case test.err == nil && err != nil:
+			t.Errorf("%s with %+v: unexpected error %v", test.source, test.sandbox, err)
+		case !reflect.DeepEqual(value, test.expected):
+			t.Errorf("%s with %+v: expected %v, got %v", test.source, test.sandbox, test.expected, value)
+		}
+		if user.Name != "ann" || user.Meta["team"] != "core" {
+			t.Errorf("%s with %+v: the root was modified", test.source, test.sandbox)
+		}
+		if user.Counter.N != 0 {
+			t.Errorf("%s with %+v: String was called %d times", test.source, test.sandbox, user.Counter.N)
+		}
+	}
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := MustParse("Name").EvalContext(ctx, &sandboxUser{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		source string
+		ok     bool
+	}{
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), true},
+		{strings.Repeat("1 + ", 200) + "1", true},
+		{strings.Repeat("(", 500000) + "1" + strings.Repeat(")", 500000), false},
+		{strings.Repeat("-", 5000) + "1", false},
+		{strings.Repeat("1 + ", 5000) + "1", false},
+		{"a" + strings.Repeat(".a", 5000), false},
+		{"a" + strings.Repeat("[0]", 5000), false},
+		{strings.Repeat("a = ", 5000) + "1", false},
+		{strings.Repeat("{", 5000) + strings.Repeat("}", 5000), false},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.source)
+		if test.ok && err != nil {
+			t.Errorf("%.20s...: unexpected error %v", test.source, err)
+		}
+		if !test.ok {
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Errorf("%.20s...: expected an *Error, got %v", test.source, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The canonical encoding of a value is a type tag byte followed by the
+// value's payload; lengths and counts are unsigned varints (always in their
+// shortest form), numbers are big-endian:
+//
+//	'n'                          nil pointer, interface, slice or map
+//	'F', 'T'                     false, true
+//	'i' int64                    integers representable as int64
+//	'u' uint64                   larger unsigned integers
+//	'f' float64                  floats, with zeros and NaNs normalised
+//	'c' float64 float64          complex numbers
+//	's' length bytes             strings
+//	'x' length bytes             byte slices and arrays
+//	't' int64 uint32             times, as Unix seconds and nanoseconds
+//	'l' count value...           slices and arrays
+//	'm' count (key value)...     maps, sorted by the encoding of their keys
+//	'o' count (name value)...    structs, sorted by field name (a string)
+//
+// Pointers and interfaces are transparent, i.e. they are encoded as the
+// value they refer to; integers of different widths and signedness and
+// floats of different precisions are encoded alike when they hold the same
+// value. Channels, functions, unsafe pointers and cyclic values (through
+// pointers, maps or slices) cannot be encoded, and make Encode fail.
+const (
+	canonicalNil     = 'n'
+	canonicalFalse   = 'F'
+	canonicalTrue    = 'T'
+	canonicalInt     = 'i'
+	canonicalUint    = 'u'
+	canonicalFloat   = 'f'
+	canonicalComplex = 'c'
+	canonicalString  = 's'
+	canonicalBytes   = 'x'
+	canonicalTime    = 't'
+	canonicalList    = 'l'
+	canonicalMap     = 'm'
+	canonicalStruct  = 'o'
+)
+
+// CanonicalEncoder writes the canonical encoding of values to a stream, so
+// that two equal values always encode to identical bytes, e.g. for signing
+// or content addressing. Unexported fields are encoded too; fields can be
+// renamed or excluded with struct tags (by default `canonical:"name"` and
+// `canonical:"-"`).
+type CanonicalEncoder struct {
+	writer io.Writer
+	tag    string
+}
+
+// NewCanonicalEncoder returns a new CanonicalEncoder writing to the stream.
+func NewCanonicalEncoder(writer io.Writer) *CanonicalEncoder {
+	return &CanonicalEncoder{
+		writer: writer,
+		tag:    "canonical",
+	}
+}
+
+// SetTag sets the struct tag key used to rename and exclude fields.
+func (e *CanonicalEncoder) SetTag(tag string) {
+	e.tag = tag
+}
+
+// Canonical returns the canonical encoding of an object.
+func Canonical(object interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := NewCanonicalEncoder(&buffer).Encode(object); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Encode writes the canonical encoding of an object to the stream.
+func (e *CanonicalEncoder) Encode(object interface{}) error {
+	state := &canonicalState{
+		tag:      e.tag,
+		pointers: map[hashPointer]bool{},
+	}
+	value := reflect.ValueOf(object)
+	if value.IsValid() {
+		// work on an addressable copy, so that unexported times can be read
+		root := reflect.New(value.Type()).Elem()
+		root.Set(value)
+		value = root
+	}
+	var buffer bytes.Buffer
+	if err := state.encode(&buffer, "", value); err != nil {
+		return err
+	}
+	_, err := e.writer.Write(buffer.Bytes())
+	return err
+}
+
+// canonicalState holds the state of an encoding; pointers holds the
+// pointers, maps and slices being followed, to detect cycles.
+type canonicalState struct {
+	tag      string
+	pointers map[hashPointer]bool
+}
+
+// canonicalField is the encoding of a struct field.
+type canonicalField struct {
+	name  string
+	value []byte
+}
+
+// encode appends the canonical encoding of a value to the buffer.
+func (s *canonicalState) encode(buffer *bytes.Buffer, path string, value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Invalid:
+		buffer.WriteByte(canonicalNil)
+
+	case reflect.Bool:
+		if value.Bool() {
+			buffer.WriteByte(canonicalTrue)
+		} else {
+			buffer.WriteByte(canonicalFalse)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buffer.WriteByte(canonicalInt)
+		canonicalUint64(buffer, uint64(value.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			buffer.WriteByte(canonicalUint)
+		} else {
+			buffer.WriteByte(canonicalInt)
+		}
+		canonicalUint64(buffer, value.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		buffer.WriteByte(canonicalFloat)
+		canonicalFloat64(buffer, value.Float())
+
+	case reflect.Complex64, reflect.Complex128:
+		buffer.WriteByte(canonicalComplex)
+		canonicalFloat64(buffer, real(value.Complex()))
+		canonicalFloat64(buffer, imag(value.Complex()))
+
+	case reflect.String:
+		buffer.WriteByte(canonicalString)
+		canonicalLength(buffer, value.Len())
+		buffer.WriteString(value.String())
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Errorf("reflector: cannot encode %s at %s", value.Type(), displayPath(path))
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			buffer.WriteByte(canonicalNil)
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			buffer.WriteByte(canonicalBytes)
+			canonicalLength(buffer, value.Len())
+			for i := 0; i < value.Len(); i++ {
+				buffer.WriteByte(byte(value.Index(i).Uint()))
+			}
+			return nil
+		}
+		leave, err := s.enter(path, value)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		buffer.WriteByte(canonicalList)
+		canonicalLength(buffer, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			if err := s.encode(buffer, chain(path, fmt.Sprintf("[%d]", i)), value.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		if value.Type() == timeType {
+			if t, ok := timeOf(value); ok {
+				buffer.WriteByte(canonicalTime)
+				canonicalUint64(buffer, uint64(t.Unix()))
+				var nanoseconds [4]byte
+				binary.BigEndian.PutUint32(nanoseconds[:], uint32(t.Nanosecond()))
+				buffer.Write(nanoseconds[:])
+				return nil
+			}
+		}
+		var fields []canonicalField
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := field.Name
+			if tag, ok := field.Tag.Lookup(s.tag); ok {
+				tag = strings.Split(tag, ",")[0]
+				if tag == "-" {
+					continue
+				} else if tag != "" {
+					name = tag
+				}
+			}
+			var encoded bytes.Buffer
+			if err := s.encode(&encoded, chain(path, field.Name), value.Field(i)); err != nil {
+				return err
+			}
+			fields = append(fields, canonicalField{name: name, value: encoded.Bytes()})
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].name < fields[j].name
+		})
+		buffer.WriteByte(canonicalStruct)
+		canonicalLength(buffer, len(fields))
+		for i, field := range fields {
+			if i > 0 && field.name == fields[i-1].name {
+				return fmt.Errorf("reflector: duplicate field name %q in %s at %s", field.name, value.Type(), displayPath(path))
+			}
+			buffer.WriteByte(canonicalString)
+			canonicalLength(buffer, len(field.name))
+			buffer.WriteString(field.name)
+			buffer.Write(field.value)
+		}
+
+	case reflect.Map:
+		if value.IsNil() {
+			buffer.WriteByte(canonicalNil)
+			return nil
+		}
+		leave, err := s.enter(path, value)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		type entry struct {
+			key   []byte
+			value []byte
+		}
+		entries := make([]entry, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			name := chain(path, "["+DefaultFormatter.FormatValue(key)+"]")
+			var k, v bytes.Buffer
+			if err := s.encode(&k, name, key); err != nil {
+				return err
+			}
+			if err := s.encode(&v, name, value.MapIndex(key)); err != nil {
+				return err
+			}
+			entries = append(entries, entry{key: k.Bytes(), value: v.Bytes()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+		buffer.WriteByte(canonicalMap)
+		canonicalLength(buffer, len(entries))
+		for i, entry := range entries {
+			if i > 0 && bytes.Equal(entry.key, entries[i-1].key) {
+				// e.g. keys of different types holding the same value
+				return fmt.Errorf("reflector: duplicate map key in %s at %s", value.Type(), displayPath(path))
+			}
+			buffer.Write(entry.key)
+			buffer.Write(entry.value)
+		}
+
+	case reflect.Ptr:
+		if value.IsNil() {
+			buffer.WriteByte(canonicalNil)
+			return nil
+		}
+		leave, err := s.enter(path, value)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return s.encode(buffer, path, value.Elem())
+
+	case reflect.Interface:
+		if value.IsNil() {
+			buffer.WriteByte(canonicalNil)
+			return nil
+		}
+		return s.encode(buffer, path, value.Elem())
+	}
+	return nil
+}
+
+// enter marks a pointer, map or slice as being followed, and fails if it
+// already is, i.e. if the value is cyclic; leave unmarks it.
+func (s *canonicalState) enter(path string, value reflect.Value) (leave func(), err error) {
+	id, ok := identity(value)
+	if !ok {
+		return func() {}, nil
+	}
+	if s.pointers[id] {
+		return nil, fmt.Errorf("reflector: cannot encode cyclic value at %s", displayPath(path))
+	}
+	s.pointers[id] = true
+	return func() { delete(s.pointers, id) }, nil
+}
+
+// canonicalLength appends a length or count.
+func canonicalLength(buffer *bytes.Buffer, n int) {
+	var varint [binary.MaxVarintLen64]byte
+	buffer.Write(varint[:binary.PutUvarint(varint[:], uint64(n))])
+}
+
+// canonicalUint64 appends a fixed-length integer.
+func canonicalUint64(buffer *bytes.Buffer, n uint64) {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], n)
+	buffer.Write(number[:])
+}
+
+// canonicalFloat64 appends a floating point number, with all zeros and all
+// NaNs normalised to a single representation.
+func canonicalFloat64(buffer *bytes.Buffer, f float64) {
+	canonicalUint64(buffer, floatBits(f))
+}
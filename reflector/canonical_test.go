@@ -0,0 +1,72 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalEqualValues(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b interface{}
+	}{
+		{"widths", int8(1), uint64(1)},
+		{"precisions", float32(0.5), 0.5},
+		{"zeros", 0.0, math.Copysign(0, -1)},
+		{"NaNs", math.NaN(), -math.NaN()},
+		{"map order", map[string]int{"a": 1, "b": 2, "c": 3}, map[string]int{"c": 3, "b": 2, "a": 1}},
+		{"pointers", 1, func() *int { i := 1; return &i }()},
+	}
+	for _, test := range tests {
+		a, err := Canonical(test.a)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		b, err := Canonical(test.b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s: different encodings %x and %x", test.name, a, b)
+		}
+	}
+}
+
+func TestCanonicalEncoding(t *testing.T) {
+	actual, err := Canonical([]interface{}{true, "ab", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte("l\x03Ts\x02abn"); !bytes.Equal(actual, expected) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestCanonicalCycles(t *testing.T) {
+	a := []interface{}{nil}
+	a[0] = a
+	m := map[string]interface{}{}
+	m["self"] = m
+	type node struct {
+		Next *node
+	}
+	p := &node{}
+	p.Next = p
+	for _, object := range []interface{}{a, m, p} {
+		if _, err := Canonical(object); err == nil || !strings.Contains(err.Error(), "cyclic") {
+			t.Errorf("expected a cyclic value error for %T, got %v", object, err)
+		}
+	}
+
+	// shared, acyclic values are fine
+	shared := []int{1, 2}
+	if _, err := Canonical([][]int{shared, shared}); err != nil {
+		t.Errorf("unexpected error for shared slices: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// docKind is the kind of a node in a document tree.
+type docKind int8
+
+const (
+	docNil docKind = iota
+	docScalar
+	docStruct
+	docList
+	docMap
+	docPointer
+)
+
+// docNode is a node in the document tree built by a docBuilder; renderers
+// that cannot stream their output as the Observer callbacks come in (e.g.
+// because attributes or simple values must be written before nested ones)
+// work on this tree instead.
+type docNode struct {
+	name     string
+	tags     string
+	kind     docKind
+	value    reflect.Value
+	children []*docNode
+}
+
+// docBuilder is an Observer that builds a document tree; pointers and
+// interfaces are transparent, i.e. they are replaced by the value they
+// point to (or by a nil node). Values of type time.Time are leaves.
+type docBuilder struct {
+	root  *docNode
+	stack []*docNode
+	skip  int
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// document visits the object and returns the root of its document tree.
+func document(name string, object interface{}) *docNode {
+	builder := &docBuilder{}
+	Visit("", name, object, nil, builder)
+	return collapse(builder.root)
+}
+
+func (b *docBuilder) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	b.leaf(&docNode{name: name, tags: tags, kind: docNil})
+	return true
+}
+
+func (b *docBuilder) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	if object.Kind() == reflect.Invalid {
+		b.leaf(&docNode{name: name, tags: tags, kind: docNil})
+	} else {
+		b.leaf(&docNode{name: name, tags: tags, kind: docScalar, value: object})
+	}
+	return true
+}
+
+func (b *docBuilder) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	b.composite(start, &docNode{name: name, tags: tags, kind: docPointer, value: object})
+	return true
+}
+
+func (b *docBuilder) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	b.composite(start, &docNode{name: name, tags: tags, kind: docList, value: object})
+	return true
+}
+
+func (b *docBuilder) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if object.Type() == timeType {
+		// times are rendered as scalars, their internals are not relevant
+		if start && b.skip == 0 {
+			b.leaf(&docNode{name: name, tags: tags, kind: docScalar, value: object})
+		}
+		b.composite(start, nil)
+	} else {
+		b.composite(start, &docNode{name: name, tags: tags, kind: docStruct, value: object})
+	}
+	return true
+}
+
+func (b *docBuilder) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	b.composite(start, &docNode{name: name, tags: tags, kind: docMap, value: object})
+	return true
+}
+
+func (b *docBuilder) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	b.composite(start, &docNode{name: name, tags: tags, kind: docPointer, value: object})
+	return true
+}
+
+func (b *docBuilder) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (b *docBuilder) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (b *docBuilder) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+// leaf adds a node to the innermost composite node.
+func (b *docBuilder) leaf(node *docNode) {
+	if b.skip > 0 {
+		return
+	}
+	if len(b.stack) == 0 {
+		b.root = node
+		return
+	}
+	parent := b.stack[len(b.stack)-1]
+	parent.children = append(parent.children, node)
+}
+
+// composite opens or closes a composite node; a nil node starts (or ends)
+// a subtree whose nodes are discarded.
+func (b *docBuilder) composite(start bool, node *docNode) {
+	switch {
+	case start && (node == nil || b.skip > 0):
+		b.skip++
+	case start:
+		b.leaf(node)
+		b.stack = append(b.stack, node)
+	case b.skip > 0:
+		b.skip--
+	case len(b.stack) > 0:
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+}
+
+// collapse replaces pointer and interface nodes with the node they wrap.
+func collapse(node *docNode) *docNode {
+	if node == nil {
+		return nil
+	}
+	if node.kind == docPointer {
+		if len(node.children) == 0 {
+			return &docNode{name: node.name, tags: node.tags, kind: docNil}
+		}
+		child := collapse(node.children[0])
+		child.name = node.name
+		child.tags = node.tags
+		return child
+	}
+	for i, child := range node.children {
+		node.children[i] = collapse(child)
+	}
+	return node
+}
+
+// docField is a struct field or map entry as seen by a renderer, after its
+// struct tag has been applied.
+type docField struct {
+	key     string
+	options string
+	node    *docNode
+}
+
+// fields returns the exported, non-skipped fields of a struct node or the
+// entries of a map node, sorted by key, named after the given struct tag
+// where present.
+func (node *docNode) fields(tag string) []docField {
+	fields := []docField{}
+	for _, child := range node.children {
+		key, options := child.name, ""
+		if node.kind == docMap {
+			key = mapKey(child.name)
+		} else {
+			if !token.IsExported(child.name) {
+				continue
+			}
+			if value, ok := reflect.StructTag(child.tags).Lookup(tag); ok {
+				if value == "-" {
+					continue
+				}
+				parts := strings.SplitN(value, ",", 2)
+				if parts[0] != "" {
+					key = parts[0]
+				}
+				if len(parts) > 1 {
+					options = "," + parts[1]
+				}
+			}
+		}
+		if strings.Contains(options, ",omitempty") && child.empty() {
+			continue
+		}
+		fields = append(fields, docField{key: key, options: options, node: child})
+	}
+	if node.kind == docMap {
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	}
+	return fields
+}
+
+// empty returns whether the node holds a nil, zero or empty value.
+func (node *docNode) empty() bool {
+	switch node.kind {
+	case docNil:
+		return true
+	case docList, docMap:
+		return node.value.Len() == 0
+	case docScalar:
+		return node.value.IsZero()
+	}
+	return false
+}
+
+// mapKey returns the key of a map entry from its bracketed name (as in
+// ["key"] or [42]), unquoting string keys.
+func mapKey(name string) string {
+	key := strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		return unquoted
+	}
+	return key
+}
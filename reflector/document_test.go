@@ -0,0 +1,130 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type documentServer struct {
+	Host string `toml:"host" xml:"host,attr"`
+	Port int    `toml:"port"`
+}
+
+type documentConfig struct {
+	Title   string            `toml:"title" xml:"title"`
+	When    time.Time         `toml:"when"`
+	Ports   []int             `toml:"ports"`
+	Owner   *documentServer   `toml:"owner"`
+	Servers []documentServer  `toml:"servers"`
+	Labels  map[string]string `toml:"labels"`
+	Skip    string            `toml:"-" xml:"-"`
+	Nil     *documentServer
+	Text    string `xml:"note"`
+	Ratio   float64
+	On      bool
+}
+
+var documentSample = documentConfig{
+	Title:   `a <b> & "c"`,
+	When:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Ports:   []int{1, 2},
+	Owner:   &documentServer{Host: "h", Port: 1},
+	Servers: []documentServer{{Host: "a", Port: 1}, {Host: "b", Port: 2}},
+	Labels:  map[string]string{"z": "1", "a.b": "2"},
+	Skip:    "x",
+	Text:    "t\n",
+	Ratio:   1.5,
+	On:      true,
+}
+
+func TestXML(t *testing.T) {
+	expected := `<config>
+  <title>a &lt;b&gt; &amp; &#34;c&#34;</title>
+  <When>2020-01-02T03:04:05Z</When>
+  <Ports>1</Ports>
+  <Ports>2</Ports>
+  <Owner host="h">
+    <Port>1</Port>
+  </Owner>
+  <Servers host="a">
+    <Port>1</Port>
+  </Servers>
+  <Servers host="b">
+    <Port>2</Port>
+  </Servers>
+  <Labels>
+    <a.b>2</a.b>
+    <z>1</z>
+  </Labels>
+  <note>t&#xA;</note>
+  <Ratio>1.5</Ratio>
+  <On>true</On>
+</config>
+`
+	var buffer strings.Builder
+	observer := NewXMLObserver(&buffer)
+	Visit("", "config", documentSample, nil, observer)
+	if err := observer.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if actual := buffer.String(); actual != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, actual)
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(buffer.String()))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid XML: %v", err)
+		}
+	}
+}
+
+func TestTOML(t *testing.T) {
+	expected := `title = "a <b> & \"c\""
+when = 2020-01-02T03:04:05Z
+ports = [1, 2]
+Text = "t\n"
+Ratio = 1.5
+On = true
+
+[owner]
+host = "h"
+port = 1
+
+[[servers]]
+host = "a"
+port = 1
+
+[[servers]]
+host = "b"
+port = 2
+
+[labels]
+"a.b" = "2"
+z = "1"
+`
+	var buffer strings.Builder
+	observer := NewTOMLObserver(&buffer)
+	Visit("", "", documentSample, nil, observer)
+	if err := observer.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if actual := buffer.String(); actual != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, actual)
+	}
+
+	buffer.Reset()
+	Visit("", "", 42, nil, observer)
+	if err := observer.Flush(); err == nil {
+		t.Errorf("expected an error rendering a number as a TOML document")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Match is a location within an object that matches a pattern.
+type Match struct {
+	// Path is the path of the location, as the Observer names it; it can be
+	// passed to Get and Set.
+	Path string
+	// Value is the value at the location.
+	Value reflect.Value
+}
+
+// Find returns all the locations within an object that match a pattern, in
+// a deterministic order: struct fields in declaration order, elements by
+// index and map entries by key.
+//
+// Patterns are paths (see Get) that can contain wildcards: * (or [*]) matches
+// any field, element or map entry at one level, e.g. servers.*.address or
+// items[*].id, while ** matches any number of levels, including none, e.g.
+// **.password. Locations that do not exist are simply not matched; cycles
+// through pointers, maps and slices (including those reached through
+// interfaces) are not followed back into themselves, so that the search
+// always terminates. Find fails only if the pattern is invalid.
+func Find(object interface{}, pattern string) ([]Match, error) {
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.ValueOf(object)
+	if value.IsValid() && value.Kind() != reflect.Ptr {
+		// work on an addressable copy, so that unexported fields can be read
+		root := reflect.New(value.Type()).Elem()
+		root.Set(value)
+		value = root
+	}
+	f := &finder{
+		pattern:  pattern,
+		pointers: map[hashPointer]bool{},
+		seen:     map[string]bool{},
+		matches:  []Match{},
+	}
+	f.find(segments, "", value)
+	return f.matches, nil
+}
+
+// finder holds the state of a search.
+type finder struct {
+	pattern  string
+	pointers map[hashPointer]bool
+	seen     map[string]bool
+	matches  []Match
+}
+
+// find matches the remaining segments of the pattern against a value at a
+// given path.
+func (f *finder) find(segments []pathSegment, path string, value reflect.Value) {
+	if len(segments) == 0 {
+		// ** can reach the same location in more than one way
+		if !f.seen[path] {
+			f.seen[path] = true
+			f.matches = append(f.matches, Match{Path: path, Value: exposed(value)})
+		}
+		return
+	}
+
+	// dereference pointers and interfaces, keeping track of the pointers,
+	// maps and slices being followed to stop at cycles
+	current := value
+	for current.Kind() == reflect.Ptr || current.Kind() == reflect.Interface {
+		if current.IsNil() {
+			return
+		}
+		if !f.enter(current) {
+			return
+		}
+		defer f.leave(current)
+		current = current.Elem()
+	}
+	if !current.IsValid() {
+		return
+	}
+	current = exposed(current)
+
+	segment := segments[0]
+	if segment.recursive {
+		// ** matches no levels first, before current is marked as followed
+		f.find(segments[1:], path, current)
+	}
+	if current.Kind() == reflect.Map || current.Kind() == reflect.Slice {
+		if !f.enter(current) {
+			return
+		}
+		defer f.leave(current)
+	}
+	switch {
+	case segment.recursive:
+		for _, child := range children(current) {
+			f.find(segments, chain(path, child.name), child.value)
+		}
+	case segment.wildcard:
+		for _, child := range children(current) {
+			f.find(segments[1:], chain(path, child.name), child.value)
+		}
+	default:
+		child, err := segment.child(f.pattern, current)
+		if err != nil {
+			return
+		}
+		var name string
+		switch current.Kind() {
+		case reflect.Struct:
+			name = segment.name
+		case reflect.Map:
+			key, _ := segment.key(current)
+			name = "[" + DefaultFormatter.FormatValue(key) + "]"
+		default:
+			index, _ := strconv.Atoi(segment.name)
+			name = fmt.Sprintf("[%d]", index)
+		}
+		f.find(segments[1:], chain(path, name), child)
+	}
+}
+
+// enter records that a pointer, map or slice is being followed, and returns
+// false if it already is, i.e. if it is part of a cycle.
+func (f *finder) enter(value reflect.Value) bool {
+	id, ok := identity(value)
+	if !ok {
+		return true
+	}
+	if f.pointers[id] {
+		return false
+	}
+	f.pointers[id] = true
+	return true
+}
+
+// leave records that a pointer, map or slice is no longer being followed.
+func (f *finder) leave(value reflect.Value) {
+	if id, ok := identity(value); ok {
+		delete(f.pointers, id)
+	}
+}
+
+// namedValue is a child of a value, with its name in paths.
+type namedValue struct {
+	name  string
+	value reflect.Value
+}
+
+// children returns the fields of a struct, the elements of a slice or array
+// or the entries of a map, sorted by key, with their names in paths.
+func children(value reflect.Value) []namedValue {
+	var result []namedValue
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			result = append(result, namedValue{name: value.Type().Field(i).Name, value: value.Field(i)})
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			result = append(result, namedValue{name: fmt.Sprintf("[%d]", i), value: value.Index(i)})
+		}
+	case reflect.Map:
+		for _, key := range SortedKeys(value) {
+			result = append(result, namedValue{name: "[" + DefaultFormatter.FormatValue(key) + "]", value: value.MapIndex(key)})
+		}
+	}
+	return result
+}
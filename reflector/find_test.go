@@ -0,0 +1,107 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"testing"
+)
+
+type findServer struct {
+	Address  string
+	Password string
+}
+
+type findConfig struct {
+	Name     string
+	Servers  []findServer
+	Settings map[string]interface{}
+	Next     *findConfig
+}
+
+func findPaths(t *testing.T, object interface{}, pattern string) []string {
+	t.Helper()
+	matches, err := Find(object, pattern)
+	if err != nil {
+		t.Fatalf("%s: unexpected error %v", pattern, err)
+	}
+	paths := []string{}
+	for _, match := range matches {
+		paths = append(paths, match.Path)
+	}
+	return paths
+}
+
+func TestFind(t *testing.T) {
+	config := &findConfig{
+		Name: "main",
+		Servers: []findServer{
+			{Address: "a", Password: "x"},
+			{Address: "b", Password: "y"},
+		},
+		Settings: map[string]interface{}{"b": 2, "a": 1, "c": map[string]int{"Password": 3}},
+	}
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"Name", []string{"Name"}},
+		{"Servers[*].Address", []string{"Servers[0].Address", "Servers[1].Address"}},
+		{"Servers.*.Password", []string{"Servers[0].Password", "Servers[1].Password"}},
+		{"Settings.*", []string{`Settings["a"]`, `Settings["b"]`, `Settings["c"]`}},
+		{"**.Password", []string{"Servers[0].Password", "Servers[1].Password", `Settings["c"]["Password"]`}},
+		{"Missing", []string{}},
+		{"Next.Name", []string{}},
+	}
+	for _, test := range tests {
+		if actual := findPaths(t, config, test.pattern); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.pattern, test.expected, actual)
+		}
+	}
+	if _, err := Find(config, "Servers["); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	config := &findConfig{Name: "loop"}
+	config.Next = config
+
+	self := map[string]interface{}{"x": 1}
+	self["self"] = self
+
+	list := []interface{}{"x", nil}
+	list[1] = list
+
+	tests := []struct {
+		object   interface{}
+		pattern  string
+		expected []string
+	}{
+		{config, "**.Name", []string{"Name"}},
+		{self, "**.x", []string{`["x"]`}},
+		{map[string]interface{}{"m": self}, "**.x", []string{`["m"]["x"]`}},
+		{list, "**[*]", []string{"[0]", "[1]"}},
+	}
+	for _, test := range tests {
+		if actual := findPaths(t, test.object, test.pattern); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.pattern, test.expected, actual)
+		}
+	}
+}
+
+func TestFindDeterministic(t *testing.T) {
+	object := map[interface{}]interface{}{}
+	for i := 0; i < 20; i++ {
+		object[i] = map[string]int{"x": i}
+		object[int8(i)] = i
+	}
+	expected := findPaths(t, object, "**")
+	for i := 0; i < 10; i++ {
+		if actual := findPaths(t, object, "**"); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// FlatStyle is the output style of a FlatObserver.
+type FlatStyle int8
+
+const (
+	// FlatPaths writes lines like `a.b[2].c = "value"`, with strings quoted
+	// and map keys escaped as Go literals (e.g. `a.m["key"] = 1`).
+	FlatPaths FlatStyle = iota
+	// FlatEnv writes environment-variable style lines like A_B_2_C=value,
+	// quoting values for the shell where needed.
+	FlatEnv
+	// FlatProperties writes Java properties style lines like a.b[2].c=value,
+	// with keys and values escaped as per java.util.Properties.
+	FlatProperties
+)
+
+// FlatObserver is an Observer that writes one line per leaf value, made of
+// the full path of the leaf and its value; the output is meant for grep and
+// line-oriented diffs. The name of the root object is the first element of
+// all paths; pass an empty name to Visit to leave it out.
+type FlatObserver struct {
+	writer    io.Writer
+	style     FlatStyle
+	prefix    string
+	formatter *Formatter
+	colorizer *Colorizer
+	err       error
+}
+
+// NewFlatObserver returns a FlatObserver writing to the given stream in the
+// given style.
+func NewFlatObserver(writer io.Writer, style FlatStyle) *FlatObserver {
+	return &FlatObserver{
+		writer:    writer,
+		style:     style,
+		formatter: DefaultFormatter,
+		colorizer: &Colorizer{},
+	}
+}
+
+// SetTheme sets the colour theme for the FlatPaths style, if the stream
+// supports colours; a nil theme disables colours. The other styles, which
+// are meant to be read by programs, are never coloured.
+func (o *FlatObserver) SetTheme(theme *Theme) {
+	o.colorizer = NewColorizer(o.writer, theme)
+}
+
+// SetFormatter sets the Formatter used for leaf values; in FlatEnv and
+// FlatProperties styles strings are written unquoted regardless.
+func (o *FlatObserver) SetFormatter(formatter *Formatter) {
+	o.formatter = formatter
+}
+
+// SetPrefix sets a path prepended to all lines (e.g. "APP" for environment
+// variables like APP_A_B_2_C).
+func (o *FlatObserver) SetPrefix(prefix string) {
+	o.prefix = prefix
+}
+
+// Err returns the first error encountered writing to the stream, if any.
+func (o *FlatObserver) Err() error {
+	return o.err
+}
+
+// IsLeaf returns whether the Formatter treats a composite value as a leaf,
+// which is then rendered as a single value.
+func (o *FlatObserver) IsLeaf(object reflect.Value) bool {
+	return o.formatter.IsLeaf(object)
+}
+
+func (o *FlatObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	o.line(chain(path, name), reflect.Invalid, "<nil>", "")
+	return true
+}
+
+func (o *FlatObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	switch {
+	case object.Kind() == reflect.Invalid:
+		o.line(chain(path, name), reflect.Invalid, "<invalid>", "")
+	case !object.CanInterface():
+		o.line(chain(path, name), reflect.Invalid, "<unexported>", "")
+	case object.Kind() == reflect.String:
+		o.line(chain(path, name), reflect.String, o.formatter.FormatValue(object), object.String())
+	default:
+		value := o.formatter.FormatValue(object)
+		o.line(chain(path, name), object.Kind(), value, value)
+	}
+	return true
+}
+
+func (o *FlatObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *FlatObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && object.Len() == 0 {
+		o.line(chain(path, name), reflect.Slice, "[]", "")
+	}
+	return true
+}
+
+func (o *FlatObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && object.NumField() == 0 {
+		o.line(chain(path, name), reflect.Struct, "{}", "")
+	}
+	return true
+}
+
+func (o *FlatObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && object.Len() == 0 {
+		o.line(chain(path, name), reflect.Map, "{}", "")
+	}
+	return true
+}
+
+func (o *FlatObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *FlatObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	o.line(chain(path, name), object.Kind(), object.Type().String(), object.Type().String())
+	return true
+}
+
+func (o *FlatObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	o.line(chain(path, name), object.Kind(), object.Type().String(), object.Type().String())
+	return true
+}
+
+func (o *FlatObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	value := "0x" + strconv.FormatUint(uint64(object.Pointer()), 16)
+	o.line(chain(path, name), object.Kind(), value, value)
+	return true
+}
+
+// line writes a single path-value pair; kind is the kind of the value,
+// quoted is the value as it appears in FlatPaths style, raw is the value
+// before quoting, as used by the other styles.
+func (o *FlatObserver) line(path string, kind reflect.Kind, quoted string, raw string) {
+	if o.err != nil {
+		return
+	}
+	switch o.style {
+	case FlatEnv:
+		_, o.err = fmt.Fprintf(o.writer, "%s=%s\n", envKey(chain(o.prefix, path)), envValue(raw))
+	case FlatProperties:
+		_, o.err = fmt.Fprintf(o.writer, "%s=%s\n", propertiesEscape(chain(o.prefix, path), true), propertiesEscape(raw, false))
+	default:
+		c := o.colorizer
+		_, o.err = fmt.Fprintf(o.writer, "%s%s%s\n", c.Name(chain(o.prefix, path)), c.Punctuation(" = "), c.Leaf(kind, quoted))
+	}
+}
+
+// envKey turns a path into an environment variable name, by upper-casing it
+// and replacing all runs of non alphanumeric characters with an underscore;
+// names cannot start with a digit, so those get a leading underscore.
+func envKey(path string) string {
+	var key strings.Builder
+	separator := false
+	for _, r := range path {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if separator && key.Len() > 0 {
+				key.WriteByte('_')
+			}
+			key.WriteRune(unicode.ToUpper(r))
+			separator = false
+		} else {
+			separator = true
+		}
+	}
+	if key.Len() > 0 && unicode.IsDigit(rune(key.String()[0])) {
+		return "_" + key.String()
+	}
+	return key.String()
+}
+
+// envValue quotes a value for the shell, unless it is only made of
+// characters that need no quoting.
+func envValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	for _, r := range value {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) && !strings.ContainsRune("_-.,:/+@%", r) {
+			return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+		}
+	}
+	return value
+}
+
+// propertiesEscape escapes a key or a value as per the java.util.Properties
+// format: non-ASCII characters are written as \uXXXX escapes, and keys have
+// their separators escaped as well.
+func propertiesEscape(s string, key bool) string {
+	var escaped strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			escaped.WriteString(`\\`)
+		case r == '\n':
+			escaped.WriteString(`\n`)
+		case r == '\r':
+			escaped.WriteString(`\r`)
+		case r == '\t':
+			escaped.WriteString(`\t`)
+		case r == '\f':
+			escaped.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			escaped.WriteString(`\ `)
+		case key && strings.ContainsRune("=:#!", r):
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&escaped, `\u%04X`, unit)
+			}
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
@@ -0,0 +1,154 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func flat(object interface{}, style FlatStyle) string {
+	var buffer strings.Builder
+	Visit("", "", object, nil, NewFlatObserver(&buffer, style))
+	return buffer.String()
+}
+
+func TestFlatPaths(t *testing.T) {
+	type inner struct {
+		C string
+	}
+	type outer struct {
+		A int
+		B []inner
+		M map[string]int
+		P *inner
+	}
+	object := outer{A: 1, B: []inner{{C: "x"}}, M: map[string]int{"b": 2, "a": 1}}
+	expected := "" +
+		"A = 1\n" +
+		"B[0].C = \"x\"\n" +
+		"M[\"a\"] = 1\n" +
+		"M[\"b\"] = 2\n" +
+		"P = <nil>\n"
+	if actual := flat(object, FlatPaths); actual != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestFlatMapOrder(t *testing.T) {
+	m := map[string]int{}
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprintf("k%02d", i)] = i
+	}
+	first := flat(m, FlatPaths)
+	if !strings.HasPrefix(first, "[\"k00\"] = 0\n[\"k01\"] = 1\n") {
+		t.Fatalf("entries not in key order:\n%s", first[:40])
+	}
+	for i := 0; i < 20; i++ {
+		if actual := flat(m, FlatPaths); actual != first {
+			t.Fatalf("output differs between runs")
+		}
+	}
+}
+
+func TestFlatMixedKeys(t *testing.T) {
+	m := map[interface{}]string{int8(1): "int8", 1: "int", "a": "string", 0.5: "float"}
+	first := flat(m, FlatPaths)
+	for i := 0; i < 20; i++ {
+		if actual := flat(m, FlatPaths); actual != first {
+			t.Fatalf("output differs between runs:\n%s\n%s", first, actual)
+		}
+	}
+}
+
+func TestFlatLeaves(t *testing.T) {
+	formatter := NewFormatter()
+	formatter.Bytes = BytesBase64
+	var buffer strings.Builder
+	observer := NewFlatObserver(&buffer, FlatPaths)
+	observer.SetFormatter(formatter)
+	Visit("", "data", []byte("hi"), nil, observer)
+	if actual, expected := buffer.String(), "data = aGk=\n"; actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestFlatEnv(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"a.b[2].c", "A_B_2_C"},
+		{`m["x y"]`, "M_X_Y"},
+		{"[0].name", "_0_NAME"},
+		{"héllo", "H_LLO"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if actual := envKey(test.path); actual != test.expected {
+			t.Errorf("key %q: expected %q, got %q", test.path, test.expected, actual)
+		}
+	}
+
+	values := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"plain-1.0", "plain-1.0"},
+		{"user@host:/tmp,+50%", "user@host:/tmp,+50%"},
+		{"a b", "'a b'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'\''s'`},
+	}
+	for _, test := range values {
+		if actual := envValue(test.value); actual != test.expected {
+			t.Errorf("value %q: expected %q, got %q", test.value, test.expected, actual)
+		}
+	}
+
+	type config struct {
+		Name  string
+		Ports []int
+	}
+	var buffer strings.Builder
+	observer := NewFlatObserver(&buffer, FlatEnv)
+	observer.SetPrefix("app")
+	Visit("", "", config{Name: "my app", Ports: []int{80}}, nil, observer)
+	if actual, expected := buffer.String(), "APP_NAME='my app'\nAPP_PORTS_0=80\n"; actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestFlatProperties(t *testing.T) {
+	tests := []struct {
+		s        string
+		key      bool
+		expected string
+	}{
+		{"a.b[2].c", true, "a.b[2].c"},
+		{"a=b:c", true, `a\=b\:c`},
+		{"#x!", true, `\#x\!`},
+		{"x y", true, `x\ y`},
+		{"a=b x", false, "a=b x"},
+		{" lead", false, `\ lead`},
+		{"line\nnext\ttab", false, `line\nnext\ttab`},
+		{`c:\dir`, false, `c:\\dir`},
+		{"é", false, `\u00E9`},
+		{"😀", false, `\uD83D\uDE00`},
+	}
+	for _, test := range tests {
+		if actual := propertiesEscape(test.s, test.key); actual != test.expected {
+			t.Errorf("%q (key %t): expected %q, got %q", test.s, test.key, test.expected, actual)
+		}
+	}
+
+	var buffer strings.Builder
+	Visit("", "", map[string]string{"a b": "x=y"}, nil, NewFlatObserver(&buffer, FlatProperties))
+	if actual, expected := buffer.String(), "[\"a\\ b\"]=x=y\n"; actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ComplexStyle is the notation used to format complex numbers.
+type ComplexStyle int8
+
+const (
+	// ComplexGo formats complex numbers as Go does, e.g. (1+2i).
+	ComplexGo ComplexStyle = iota
+	// ComplexMath formats complex numbers as in mathematics, e.g. 1+2i.
+	ComplexMath
+	// ComplexEngineering formats complex numbers as in engineering, e.g. 1+2j.
+	ComplexEngineering
+	// ComplexPair formats complex numbers as (real, imaginary) pairs, e.g. (1, 2).
+	ComplexPair
+)
+
+// StringStyle is the way strings are quoted and escaped.
+type StringStyle int8
+
+const (
+	// StringQuoted formats strings as double-quoted Go literals.
+	StringQuoted StringStyle = iota
+	// StringASCII formats strings as double-quoted Go literals, escaping all
+	// non-ASCII characters.
+	StringASCII
+	// StringBackquoted formats strings as raw, back-quoted Go literals where
+	// possible, as double-quoted ones otherwise.
+	StringBackquoted
+	// StringRaw formats strings as they are, with no quotes or escapes.
+	StringRaw
+)
+
+// BytesStyle is the way byte slices and arrays are formatted.
+type BytesStyle int8
+
+const (
+	// BytesList formats byte slices like any other slice.
+	BytesList BytesStyle = iota
+	// BytesHex formats byte slices as hexadecimal strings.
+	BytesHex
+	// BytesBase64 formats byte slices in standard base64 encoding.
+	BytesBase64
+	// BytesHexDump formats byte slices as hexdump -C does.
+	BytesHexDump
+)
+
+// DurationStyle is the way time.Duration values are formatted.
+type DurationStyle int8
+
+const (
+	// DurationString formats durations as time.Duration.String does, e.g. 1h2m3s.
+	DurationString DurationStyle = iota
+	// DurationSeconds formats durations as a (fractional) number of seconds.
+	DurationSeconds
+	// DurationNanoseconds formats durations as an integer number of nanoseconds.
+	DurationNanoseconds
+)
+
+// PointerStyle is the way pointers are formatted.
+type PointerStyle int8
+
+const (
+	// PointerAddress formats pointers as their type and address.
+	PointerAddress PointerStyle = iota
+	// PointerValue formats pointers as the value they point to, prefixed by
+	// an ampersand, or as nil.
+	PointerValue
+)
+
+// Formatter formats leaf values (numbers, strings, booleans, times and
+// durations, and optionally pointers and byte slices) without inspecting
+// their internal structure; it is used by Visit to format map keys and can
+// be used by observers for leaf values. The zero value is not usable, use
+// NewFormatter to get one with the default settings.
+type Formatter struct {
+	// FloatFormat is the format passed to strconv.FormatFloat ('g', 'e', 'f'...).
+	FloatFormat byte
+	// FloatPrecision is the precision passed to strconv.FormatFloat; -1 means
+	// the smallest number of digits needed to represent the value exactly.
+	FloatPrecision int
+	// IntegerBase is the base integers are formatted in; bases other than 10
+	// get a prefix (0b, 0o, 0x) where one exists.
+	IntegerBase int
+	// Complex is the notation for complex numbers.
+	Complex ComplexStyle
+	// Strings is the quoting style for strings.
+	Strings StringStyle
+	// Bytes is the format of byte slices and arrays.
+	Bytes BytesStyle
+	// TimeLayout is the layout passed to time.Time.Format.
+	TimeLayout string
+	// Durations is the format of time.Duration values.
+	Durations DurationStyle
+	// Pointers is the format of pointers.
+	Pointers PointerStyle
+}
+
+// DefaultFormatter is the Formatter used by Visit to format map keys; since
+// map keys are part of paths, strings should stay quoted.
+var DefaultFormatter = NewFormatter()
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// NewFormatter returns a Formatter with the default settings: shortest float
+// representation, Go notation for complex numbers, decimal integers, quoted
+// strings, RFC 3339 times, durations as strings and pointers as addresses.
+func NewFormatter() *Formatter {
+	return &Formatter{
+		FloatFormat:    'g',
+		FloatPrecision: -1,
+		IntegerBase:    10,
+		Complex:        ComplexGo,
+		Strings:        StringQuoted,
+		Bytes:          BytesList,
+		TimeLayout:     time.RFC3339Nano,
+		Durations:      DurationString,
+		Pointers:       PointerAddress,
+	}
+}
+
+// IsLeaf returns whether the formatter treats a composite value as a leaf,
+// as is the case for times and, depending on the settings, byte slices.
+func (f *Formatter) IsLeaf(v reflect.Value) bool {
+	switch {
+	case v.Kind() == reflect.Struct:
+		return v.Type() == timeType
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		return f.Bytes != BytesList && v.Type().Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// FormatValue formats a value without inspecting its internal structure;
+// values of unexported fields are formatted too.
+func (f *Formatter) FormatValue(v reflect.Value) string {
+	if v.IsValid() && v.Type() == durationType {
+		return f.duration(time.Duration(v.Int()))
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "invalid"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return "-" + f.integer(uint64(-v.Int()))
+		}
+		return f.integer(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return f.integer(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), f.FloatFormat, f.FloatPrecision, v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return f.complex(v.Complex(), v.Type().Bits()/2)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.String:
+		return f.string(v.String())
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			return v.Interface().(time.Time).Format(f.TimeLayout)
+		}
+	case reflect.Slice, reflect.Array:
+		if f.IsLeaf(v) {
+			return f.bytes(v)
+		}
+	case reflect.Ptr:
+		if f.Pointers == PointerValue {
+			if v.IsNil() {
+				return "nil"
+			}
+			return "&" + f.FormatValue(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return f.FormatValue(v.Elem())
+	}
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Ptr, reflect.Slice, reflect.Map, reflect.UnsafePointer:
+		return v.Type().String() + " 0x" + strconv.FormatUint(uint64(v.Pointer()), 16)
+	}
+	// arrays and structs
+	return fmt.Sprintf("%v", v)
+}
+
+// integer formats the absolute value of an integer in the configured base.
+func (f *Formatter) integer(n uint64) string {
+	base := f.IntegerBase
+	if base < 2 || base > 36 {
+		base = 10
+	}
+	prefix := ""
+	switch base {
+	case 2:
+		prefix = "0b"
+	case 8:
+		prefix = "0o"
+	case 16:
+		prefix = "0x"
+	}
+	return prefix + strconv.FormatUint(n, base)
+}
+
+// complex formats a complex number in the configured notation.
+func (f *Formatter) complex(c complex128, bits int) string {
+	re := strconv.FormatFloat(real(c), f.FloatFormat, f.FloatPrecision, bits)
+	im := strconv.FormatFloat(imag(c), f.FloatFormat, f.FloatPrecision, bits)
+	if f.Complex == ComplexPair {
+		return "(" + re + ", " + im + ")"
+	}
+	if !strings.HasPrefix(im, "-") && !strings.HasPrefix(im, "+") {
+		im = "+" + im
+	}
+	switch f.Complex {
+	case ComplexMath:
+		return re + im + "i"
+	case ComplexEngineering:
+		return re + im + "j"
+	}
+	return "(" + re + im + "i)"
+}
+
+// string quotes a string in the configured style.
+func (f *Formatter) string(s string) string {
+	switch f.Strings {
+	case StringASCII:
+		return strconv.QuoteToASCII(s)
+	case StringBackquoted:
+		if strconv.CanBackquote(s) {
+			return "`" + s + "`"
+		}
+		return strconv.Quote(s)
+	case StringRaw:
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// bytes formats a byte slice or array in the configured style.
+func (f *Formatter) bytes(v reflect.Value) string {
+	data := make([]byte, v.Len())
+	for i := range data {
+		// reflect.Copy would not work on unexported fields
+		data[i] = byte(v.Index(i).Uint())
+	}
+	switch f.Bytes {
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(data)
+	case BytesHexDump:
+		return hex.Dump(data)
+	}
+	return hex.EncodeToString(data)
+}
+
+// duration formats a duration in the configured style.
+func (f *Formatter) duration(d time.Duration) string {
+	switch f.Durations {
+	case DurationSeconds:
+		return strconv.FormatFloat(d.Seconds(), f.FloatFormat, f.FloatPrecision, 64)
+	case DurationNanoseconds:
+		return strconv.FormatInt(int64(d), 10)
+	}
+	return d.String()
+}
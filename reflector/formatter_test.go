@@ -0,0 +1,101 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatter(t *testing.T) {
+	one := 1
+	tests := []struct {
+		setup    func(f *Formatter)
+		value    interface{}
+		expected string
+	}{
+		// floats
+		{nil, 0.1, "0.1"},
+		{nil, float32(0.1), "0.1"},
+		{nil, 1e21, "1e+21"},
+		{func(f *Formatter) { f.FloatFormat = 'f'; f.FloatPrecision = 2 }, 3.14159, "3.14"},
+		{func(f *Formatter) { f.FloatFormat = 'e'; f.FloatPrecision = 3 }, 1234.5, "1.234e+03"},
+		{func(f *Formatter) { f.FloatFormat = 'f'; f.FloatPrecision = 0 }, 2.5, "2"},
+		// integers
+		{nil, -42, "-42"},
+		{nil, int64(math.MinInt64), "-9223372036854775808"},
+		{func(f *Formatter) { f.IntegerBase = 2 }, 5, "0b101"},
+		{func(f *Formatter) { f.IntegerBase = 8 }, uint8(8), "0o10"},
+		{func(f *Formatter) { f.IntegerBase = 16 }, -255, "-0xff"},
+		{func(f *Formatter) { f.IntegerBase = 36 }, 35, "z"},
+		{func(f *Formatter) { f.IntegerBase = 1 }, 10, "10"},
+		// complex numbers
+		{nil, complex(1, 2), "(1+2i)"},
+		{func(f *Formatter) { f.Complex = ComplexMath }, complex(1, -2), "1-2i"},
+		{func(f *Formatter) { f.Complex = ComplexEngineering }, complex64(complex(0.5, 2)), "0.5+2j"},
+		{func(f *Formatter) { f.Complex = ComplexPair }, complex(1, -2), "(1, -2)"},
+		{func(f *Formatter) { f.Complex = ComplexMath }, complex(1, math.Inf(1)), "1+Infi"},
+		// strings
+		{nil, "a\"é\n", `"a\"é\n"`},
+		{func(f *Formatter) { f.Strings = StringASCII }, "é", `"\u00e9"`},
+		{func(f *Formatter) { f.Strings = StringBackquoted }, `a\b"c`, "`a\\b\"c`"},
+		{func(f *Formatter) { f.Strings = StringBackquoted }, "a`b", "\"a`b\""},
+		{func(f *Formatter) { f.Strings = StringRaw }, "a \"b\"", `a "b"`},
+		// byte slices
+		{func(f *Formatter) { f.Bytes = BytesHex }, []byte("hi"), "6869"},
+		{func(f *Formatter) { f.Bytes = BytesBase64 }, []byte("hi"), "aGk="},
+		{func(f *Formatter) { f.Bytes = BytesHex }, [2]byte{1, 255}, "01ff"},
+		{
+			func(f *Formatter) { f.Bytes = BytesHexDump },
+			[]byte("hello, world!\x00\x01\x02\x03"),
+			"00000000  68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 00 01 02  |hello, world!...|\n" +
+				"00000010  03                                                |.|\n",
+		},
+		// durations and times
+		{nil, 90 * time.Second, "1m30s"},
+		{func(f *Formatter) { f.Durations = DurationSeconds }, 1500 * time.Millisecond, "1.5"},
+		{func(f *Formatter) { f.Durations = DurationNanoseconds }, time.Microsecond, "1000"},
+		{nil, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), "2020-01-02T03:04:05.000000006Z"},
+		{func(f *Formatter) { f.TimeLayout = "2006-01-02" }, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), "2020-01-02"},
+		// pointers and others
+		{func(f *Formatter) { f.Pointers = PointerValue }, &one, "&1"},
+		{func(f *Formatter) { f.Pointers = PointerValue }, (*int)(nil), "nil"},
+		{nil, true, "true"},
+		{nil, struct{ A, B int }{1, 2}, "{1 2}"},
+	}
+	for _, test := range tests {
+		formatter := NewFormatter()
+		if test.setup != nil {
+			test.setup(formatter)
+		}
+		if actual := formatter.FormatValue(reflect.ValueOf(test.value)); actual != test.expected {
+			t.Errorf("%T %v: expected %q, got %q", test.value, test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestFormatterIsLeaf(t *testing.T) {
+	formatter := NewFormatter()
+	tests := []struct {
+		value    interface{}
+		expected bool
+	}{
+		{time.Now(), true},
+		{struct{}{}, false},
+		{[]byte("hi"), false},
+		{[]int{1}, false},
+	}
+	for _, test := range tests {
+		if actual := formatter.IsLeaf(reflect.ValueOf(test.value)); actual != test.expected {
+			t.Errorf("%T: expected %t, got %t", test.value, test.expected, actual)
+		}
+	}
+	formatter.Bytes = BytesHex
+	if !formatter.IsLeaf(reflect.ValueOf([4]byte{})) || formatter.IsLeaf(reflect.ValueOf([]int8{})) {
+		t.Errorf("expected only byte slices and arrays to be leaves")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Get returns the value at the given path within an object; an empty path
+// returns the object itself. Values reached through unexported fields can be
+// read with the reflect API too.
+//
+// Paths have the same syntax as those passed to the Observer: dotted field
+// names, indexes and map keys in brackets, e.g. a.b[3].c or m["key"].x. Map
+// keys are written as Go literals (e.g. [1], [true] or ["key"], where strings
+// can be double-quoted or back-quoted); a key is also matched against the
+// formatted keys of the map, so that keys of any type can be addressed as
+// the Observer names them. Maps with string keys can also be navigated with
+// dotted names (m.key). Pointers and interfaces are dereferenced
+// transparently. Errors are of type *PathError, naming the failing segment.
+func Get(object interface{}, path string) (reflect.Value, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	value := reflect.ValueOf(object)
+	if !value.IsValid() {
+		return reflect.Value{}, &PathError{Path: path, Err: errors.New("nil object")}
+	}
+	if value.Kind() != reflect.Ptr {
+		// work on an addressable copy, so that unexported fields can be read
+		root := reflect.New(value.Type()).Elem()
+		root.Set(value)
+		value = root
+	}
+	for _, segment := range segments {
+		current, ok := indirect(value)
+		if !ok {
+			return reflect.Value{}, segment.fail(path, "nil %s", current.Type())
+		}
+		if !current.IsValid() {
+			return reflect.Value{}, segment.fail(path, "nil value")
+		}
+		if value, err = segment.child(path, exposed(current)); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return exposed(value), nil
+}
+
+// GetAs returns the value at the given path within an object (see Get) as a
+// value of type T, converting numbers (only if they fit exactly) and
+// dereferencing pointers and interfaces if needed.
+func GetAs[T interface{}](object interface{}, path string) (T, error) {
+	var result T
+	value, err := Get(object, path)
+	if err != nil {
+		return result, err
+	}
+	target := reflect.TypeOf(&result).Elem()
+	for {
+		switch {
+		case !value.IsValid():
+			return result, fmt.Errorf("reflector: nil value at %s, not %s", displayPath(path), target)
+		case value.Type().AssignableTo(target):
+			reflect.ValueOf(&result).Elem().Set(exposed(value))
+			return result, nil
+		case isNumber(value.Kind()) && isNumber(target.Kind()):
+			converted, err := convertNumber(exposed(value), target)
+			if err != nil {
+				return result, fmt.Errorf("reflector: cannot get %s at %s as %s: %v", value.Type(), displayPath(path), target, err)
+			}
+			reflect.ValueOf(&result).Elem().Set(converted)
+			return result, nil
+		case (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && !value.IsNil():
+			value = exposed(value.Elem())
+		default:
+			return result, fmt.Errorf("reflector: cannot get %s at %s as %s", value.Type(), displayPath(path), target)
+		}
+	}
+}
+
+// isNumber returns whether a kind is numeric.
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertNumber converts a number to another numeric type, failing if it
+// does not fit exactly.
+func convertNumber(v reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	value := reflect.New(typ).Elem()
+	fail := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("value %v does not fit %s", v, typ)
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v.Uint() > math.MaxInt64 {
+				return fail()
+			}
+			i = int64(v.Uint())
+		default:
+			if f := v.Float(); f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return fail()
+			}
+			i = int64(v.Float())
+		}
+		if value.OverflowInt(i) {
+			return fail()
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 {
+				return fail()
+			}
+			u = uint64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u = v.Uint()
+		default:
+			if f := v.Float(); f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+				return fail()
+			}
+			u = uint64(v.Float())
+		}
+		if value.OverflowUint(u) {
+			return fail()
+		}
+		value.SetUint(u)
+	default:
+		var f float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f = float64(v.Int()); int64(f) != v.Int() {
+				return fail()
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if f = float64(v.Uint()); uint64(f) != v.Uint() {
+				return fail()
+			}
+		default:
+			f = v.Float()
+		}
+		if value.OverflowFloat(f) {
+			return fail()
+		}
+		if typ.Kind() == reflect.Float32 && float64(float32(f)) != f && !math.IsNaN(f) {
+			// the value would be rounded
+			return fail()
+		}
+		value.SetFloat(f)
+	}
+	return value, nil
+}
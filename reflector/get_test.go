@@ -0,0 +1,127 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"errors"
+	"testing"
+)
+
+type getItem struct {
+	Name  string
+	Price float64
+}
+
+type getOrder struct {
+	ID     int
+	Items  []getItem
+	Tags   map[string]string
+	Codes  map[int]string
+	Any    interface{}
+	Parent *getOrder
+	secret string
+}
+
+func TestGet(t *testing.T) {
+	object := &getOrder{
+		ID:     7,
+		Items:  []getItem{{Name: "a", Price: 1.5}, {Name: "b", Price: 2}},
+		Tags:   map[string]string{"k": "v"},
+		Codes:  map[int]string{1: "one"},
+		Any:    map[string]interface{}{"x": []int{1, 2}},
+		secret: "s",
+	}
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"ID", 7},
+		{"Items[1].Name", "b"},
+		{"Items[0].Price", 1.5},
+		{`Tags["k"]`, "v"},
+		{"Tags.k", "v"},
+		{"Codes[1]", "one"},
+		{`Any["x"][1]`, 2},
+		{"secret", "s"},
+	}
+	for _, test := range tests {
+		value, err := Get(object, test.path)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.path, err)
+			continue
+		}
+		if actual := value.Interface(); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, actual)
+		}
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	object := &getOrder{Items: []getItem{{Name: "a"}, {Name: "b"}}, Tags: map[string]string{"k": "v"}}
+	for _, path := range []string{"Missing", "Items[2]", "Items[x]", "Parent.ID", "ID.X", "Items[", `Tags["missing"]`} {
+		if _, err := Get(object, path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+	for _, path := range []string{"", "ID"} {
+		var e *PathError
+		if _, err := Get(nil, path); !errors.As(err, &e) {
+			t.Errorf("%q on nil: expected a *PathError, got %v", path, err)
+		}
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	object := struct {
+		Small  int
+		Large  int
+		Float  float64
+		Whole  float64
+		Signed int
+		Ptr    *int
+		Exact  int
+		Odd    int
+		Tenth  float64
+		Half   float64
+	}{Small: 100, Large: 300, Float: 1.9, Whole: 2, Signed: -1, Ptr: new(int), Exact: 1 << 24, Odd: 1<<24 + 1, Tenth: 0.1, Half: 0.5}
+	*object.Ptr = 5
+
+	if v, err := GetAs[int8](object, "Small"); err != nil || v != 100 {
+		t.Errorf("Small as int8: %v, %v", v, err)
+	}
+	if v, err := GetAs[int](object, "Whole"); err != nil || v != 2 {
+		t.Errorf("Whole as int: %v, %v", v, err)
+	}
+	if v, err := GetAs[float32](object, "Small"); err != nil || v != 100 {
+		t.Errorf("Small as float32: %v, %v", v, err)
+	}
+	if v, err := GetAs[int64](object, "Ptr"); err != nil || v != 5 {
+		t.Errorf("Ptr as int64: %v, %v", v, err)
+	}
+	if v, err := GetAs[int8](object, "Large"); err == nil {
+		t.Errorf("Large as int8: expected an error, got %v", v)
+	}
+	if v, err := GetAs[int](object, "Float"); err == nil {
+		t.Errorf("Float as int: expected an error, got %v", v)
+	}
+	if v, err := GetAs[uint](object, "Signed"); err == nil {
+		t.Errorf("Signed as uint: expected an error, got %v", v)
+	}
+	if v, err := GetAs[float32](object, "Exact"); err != nil || v != 16777216 {
+		t.Errorf("Exact as float32: %v, %v", v, err)
+	}
+	if v, err := GetAs[float32](object, "Odd"); err == nil {
+		t.Errorf("Odd as float32: expected an error, got %v", v)
+	}
+	if v, err := GetAs[float32](object, "Tenth"); err == nil {
+		t.Errorf("Tenth as float32: expected an error, got %v", v)
+	}
+	if v, err := GetAs[float32](object, "Half"); err != nil || v != 0.5 {
+		t.Errorf("Half as float32: %v, %v", v, err)
+	}
+	if v, err := GetAs[string](object, "Small"); err == nil {
+		t.Errorf("Small as string: expected an error, got %v", v)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// Hasher computes stable fingerprints of the contents of arbitrary values,
+// unexported fields included: two values with the same contents have the
+// same fingerprint, across processes and regardless of the iteration order
+// of maps. Values of different types (e.g. int32(1) and int64(1)) have
+// different fingerprints; times are hashed as instants, so that times in
+// different locations compare as time.Time.Equal does. Channels, functions
+// and unsafe pointers only contribute whether they are nil, since their
+// identity is not stable across processes. Cycles through pointers, maps
+// and slices (e.g. a map holding itself in an interface{}) are supported: a
+// value met again while its own contents are being hashed contributes a
+// reference back to it, so hashing always terminates.
+type Hasher struct {
+	// New creates the hash function; the default is SHA-256.
+	New func() hash.Hash
+	// Tag is the struct tag key used to exclude fields from the fingerprint,
+	// e.g. `hash:"-"`; the default is "hash".
+	Tag string
+	// IgnorePaths is a list of paths (as in "a.b[2].c" or "m[\"key\"]",
+	// relative to the hashed value) of the fields, elements and map entries
+	// to exclude from the fingerprint.
+	IgnorePaths []string
+	// NilAsEmpty makes nil slices and maps hash as empty ones.
+	NilAsEmpty bool
+}
+
+// NewHasher returns a Hasher with the default settings.
+func NewHasher() *Hasher {
+	return &Hasher{
+		New: sha256.New,
+		Tag: "hash",
+	}
+}
+
+// DefaultHasher is the Hasher used by Hash.
+var DefaultHasher = NewHasher()
+
+// Hash returns the fingerprint of an object computed by DefaultHasher.
+func Hash(object interface{}) []byte {
+	return DefaultHasher.Hash(object)
+}
+
+// Hash returns the fingerprint of an object.
+func (h *Hasher) Hash(object interface{}) []byte {
+	state := &hashState{
+		hasher:   h,
+		pointers: map[hashPointer]int{},
+	}
+	if len(h.IgnorePaths) > 0 {
+		state.ignored = map[string]bool{}
+		for _, path := range h.IgnorePaths {
+			state.ignored[path] = true
+		}
+	}
+	digest := h.hash()
+	value := reflect.ValueOf(object)
+	if value.IsValid() {
+		// work on an addressable copy, so that unexported times can be read
+		root := reflect.New(value.Type()).Elem()
+		root.Set(value)
+		writeString(digest, root.Type().String())
+		value = root
+	}
+	state.write(digest, "", value)
+	return digest.Sum(nil)
+}
+
+// hash returns a new instance of the hash function.
+func (h *Hasher) hash() hash.Hash {
+	if h.New == nil {
+		return sha256.New()
+	}
+	return h.New()
+}
+
+// hashPointer identifies a pointer, map or slice being followed, to detect
+// cycles; length tells apart slices of different lengths sharing their
+// first element.
+type hashPointer struct {
+	address uintptr
+	typ     reflect.Type
+	length  int
+}
+
+// identity returns the identity of a non-nil pointer, or of a non-empty map
+// or slice, i.e. of the values cycles can go through.
+func identity(value reflect.Value) (hashPointer, bool) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			return hashPointer{address: value.Pointer(), typ: value.Type()}, true
+		}
+	case reflect.Map:
+		if value.Len() > 0 {
+			return hashPointer{address: value.Pointer(), typ: value.Type()}, true
+		}
+	case reflect.Slice:
+		if value.Len() > 0 {
+			return hashPointer{address: value.Pointer(), typ: value.Type(), length: value.Len()}, true
+		}
+	}
+	return hashPointer{}, false
+}
+
+// hashState holds the state of the computation of a fingerprint; pointers
+// maps the pointers, maps and slices being followed to their nesting depth.
+type hashState struct {
+	hasher   *Hasher
+	ignored  map[string]bool
+	pointers map[hashPointer]int
+	depth    int
+}
+
+// path returns the path of a child node, if paths are needed at all.
+func (s *hashState) path(path string, name func() string) (string, bool) {
+	if s.ignored == nil {
+		return "", false
+	}
+	path = chain(path, name())
+	return path, s.ignored[path]
+}
+
+// write adds a value to the hash.
+func (s *hashState) write(digest hash.Hash, path string, value reflect.Value) {
+	digest.Write([]byte{byte(value.Kind())})
+
+	switch value.Kind() {
+	case reflect.Invalid:
+
+	case reflect.Bool:
+		if value.Bool() {
+			digest.Write([]byte{1})
+		} else {
+			digest.Write([]byte{0})
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(digest, uint64(value.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(digest, value.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		writeFloat(digest, value.Float())
+
+	case reflect.Complex64, reflect.Complex128:
+		writeFloat(digest, real(value.Complex()))
+		writeFloat(digest, imag(value.Complex()))
+
+	case reflect.String:
+		writeString(digest, value.String())
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		writeNil(digest, value.IsNil())
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() && !s.hasher.NilAsEmpty {
+			writeNil(digest, true)
+			return
+		}
+		writeNil(digest, false)
+		writeUint(digest, uint64(value.Len()))
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 && s.ignored == nil {
+			digest.Write(value.Bytes())
+			return
+		}
+		if id, ok := identity(value); ok {
+			if !s.enter(digest, id) {
+				return
+			}
+			defer s.leave(id)
+		}
+		for i := 0; i < value.Len(); i++ {
+			path, ignored := s.path(path, func() string { return fmt.Sprintf("[%d]", i) })
+			if ignored {
+				writeNil(digest, true)
+				continue
+			}
+			writeNil(digest, false)
+			s.write(digest, path, value.Index(i))
+		}
+
+	case reflect.Struct:
+		if value.Type() == timeType {
+			if t, ok := timeOf(value); ok {
+				writeUint(digest, uint64(t.Unix()))
+				writeUint(digest, uint64(t.Nanosecond()))
+				return
+			}
+		}
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.Tag.Get(s.hasher.Tag) == "-" {
+				continue
+			}
+			path, ignored := s.path(path, func() string { return field.Name })
+			if ignored {
+				continue
+			}
+			writeString(digest, field.Name)
+			s.write(digest, path, value.Field(i))
+		}
+		// fields are framed by their names, and a name is never empty
+		writeString(digest, "")
+
+	case reflect.Map:
+		if value.IsNil() && !s.hasher.NilAsEmpty {
+			writeNil(digest, true)
+			return
+		}
+		writeNil(digest, false)
+		if id, ok := identity(value); ok {
+			if !s.enter(digest, id) {
+				return
+			}
+			defer s.leave(id)
+		}
+		// each entry is hashed on its own, then the sorted entry hashes are
+		// added, so that the result does not depend on the iteration order
+		entries := make([][]byte, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			path, ignored := s.path(path, func() string { return "[" + DefaultFormatter.FormatValue(key) + "]" })
+			if ignored {
+				continue
+			}
+			entry := s.hasher.hash()
+			s.write(entry, "", key)
+			s.write(entry, path, value.MapIndex(key))
+			entries = append(entries, entry.Sum(nil))
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i], entries[j]) < 0
+		})
+		writeUint(digest, uint64(len(entries)))
+		for _, entry := range entries {
+			digest.Write(entry)
+		}
+
+	case reflect.Ptr:
+		if value.IsNil() {
+			writeNil(digest, true)
+			return
+		}
+		writeNil(digest, false)
+		id, _ := identity(value)
+		if !s.enter(digest, id) {
+			return
+		}
+		s.write(digest, path, value.Elem())
+		s.leave(id)
+
+	case reflect.Interface:
+		if value.IsNil() {
+			writeNil(digest, true)
+			return
+		}
+		writeNil(digest, false)
+		writeString(digest, value.Elem().Type().String())
+		s.write(digest, path, value.Elem())
+	}
+}
+
+// enter marks a pointer, map or slice as being followed and returns true
+// or, if it is already being followed (i.e. in a cycle), refers back to it
+// by its relative depth and returns false.
+func (s *hashState) enter(digest hash.Hash, id hashPointer) bool {
+	if depth, ok := s.pointers[id]; ok {
+		digest.Write([]byte{1})
+		writeUint(digest, uint64(s.depth-depth))
+		return false
+	}
+	digest.Write([]byte{0})
+	s.pointers[id] = s.depth
+	s.depth++
+	return true
+}
+
+// leave marks a pointer, map or slice as no longer being followed.
+func (s *hashState) leave(id hashPointer) {
+	s.depth--
+	delete(s.pointers, id)
+}
+
+// timeOf returns the time held in a value, if it can be read.
+func timeOf(value reflect.Value) (time.Time, bool) {
+	switch {
+	case value.CanInterface():
+		return value.Interface().(time.Time), true
+	case value.CanAddr():
+		// an unexported field: read it through its address
+		return *(*time.Time)(unsafe.Pointer(value.UnsafeAddr())), true
+	}
+	return time.Time{}, false
+}
+
+// writeNil adds a marker telling whether a value is nil.
+func writeNil(digest hash.Hash, isNil bool) {
+	if isNil {
+		digest.Write([]byte{0})
+	} else {
+		digest.Write([]byte{1})
+	}
+}
+
+// writeUint adds a fixed-length integer.
+func writeUint(digest hash.Hash, n uint64) {
+	var buffer [8]byte
+	binary.BigEndian.PutUint64(buffer[:], n)
+	digest.Write(buffer[:])
+}
+
+// writeFloat adds a floating point number, with all zeros and all NaNs
+// normalised to a single representation.
+func writeFloat(digest hash.Hash, f float64) {
+	writeUint(digest, floatBits(f))
+}
+
+// floatBits returns the IEEE 754 representation of a floating point number,
+// with all zeros and all NaNs normalised to a single representation.
+func floatBits(f float64) uint64 {
+	switch {
+	case f == 0:
+		f = 0
+	case math.IsNaN(f):
+		f = math.NaN()
+	}
+	return math.Float64bits(f)
+}
+
+// writeString adds a length-prefixed string.
+func writeString(digest hash.Hash, s string) {
+	writeUint(digest, uint64(len(s)))
+	digest.Write([]byte(s))
+}
@@ -0,0 +1,108 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHashEqualValues(t *testing.T) {
+	type item struct {
+		Name string
+		Tags map[string]int
+	}
+	tests := []struct {
+		name string
+		a, b interface{}
+		same bool
+	}{
+		{"ints", 1, 1, true},
+		{"widths", int32(1), int64(1), false},
+		{"strings", "a", "b", false},
+		{"maps", map[string]int{"a": 1, "b": 2, "c": 3}, map[string]int{"c": 3, "b": 2, "a": 1}, true},
+		{"structs", item{Name: "x", Tags: map[string]int{"a": 1}}, item{Name: "x", Tags: map[string]int{"a": 1}}, true},
+		{"nil and empty", []int(nil), []int{}, false},
+		{"zeros", 0.0, math.Copysign(0, -1), true},
+		{"NaNs", math.NaN(), -math.NaN(), true},
+		{"times", time.Unix(0, 0).UTC(), time.Unix(0, 0).In(time.FixedZone("X", 3600)), true},
+	}
+	for _, test := range tests {
+		if same := bytes.Equal(Hash(test.a), Hash(test.b)); same != test.same {
+			t.Errorf("%s: expected equal fingerprints to be %v", test.name, test.same)
+		}
+	}
+}
+
+func TestHasherSettings(t *testing.T) {
+	type entry struct {
+		ID      int
+		Seen    time.Time `hash:"-"`
+		Version int       `fingerprint:"-"`
+		Items   []int
+		Labels  map[string]string
+	}
+	ignoring := NewHasher()
+	ignoring.IgnorePaths = []string{"Items[1]", `Labels["etag"]`}
+	tagged := NewHasher()
+	tagged.Tag = "fingerprint"
+	empty := NewHasher()
+	empty.NilAsEmpty = true
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		a, b   entry
+		same   bool
+	}{
+		{"hash tag", DefaultHasher, entry{ID: 1, Seen: time.Now()}, entry{ID: 1}, true},
+		{"other tag", DefaultHasher, entry{ID: 1, Version: 1}, entry{ID: 1, Version: 2}, false},
+		{"custom tag", tagged, entry{ID: 1, Version: 1}, entry{ID: 1, Version: 2}, true},
+		{"custom tag only", tagged, entry{ID: 1, Seen: time.Now()}, entry{ID: 1}, false},
+		{"ignored element", ignoring, entry{Items: []int{1, 2, 3}}, entry{Items: []int{1, 5, 3}}, true},
+		{"other element", ignoring, entry{Items: []int{1, 2, 3}}, entry{Items: []int{1, 2, 4}}, false},
+		{"ignored entry", ignoring, entry{Labels: map[string]string{"etag": "x", "a": "b"}}, entry{Labels: map[string]string{"etag": "y", "a": "b"}}, true},
+		{"other entry", ignoring, entry{Labels: map[string]string{"etag": "x", "a": "b"}}, entry{Labels: map[string]string{"etag": "x", "a": "c"}}, false},
+		{"nil slice", DefaultHasher, entry{Items: nil}, entry{Items: []int{}}, false},
+		{"nil slice as empty", empty, entry{Items: nil}, entry{Items: []int{}}, true},
+		{"nil map as empty", empty, entry{Labels: nil}, entry{Labels: map[string]string{}}, true},
+		{"non-empty", empty, entry{Items: nil}, entry{Items: []int{0}}, false},
+	}
+	for _, test := range tests {
+		if same := bytes.Equal(test.hasher.Hash(test.a), test.hasher.Hash(test.b)); same != test.same {
+			t.Errorf("%s: expected equal fingerprints to be %v", test.name, test.same)
+		}
+	}
+}
+
+func TestHashCycles(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	n := map[string]interface{}{"a": 1}
+	n["self"] = n
+	if !bytes.Equal(Hash(m), Hash(n)) {
+		t.Errorf("equal self-containing maps have different fingerprints")
+	}
+	n["a"] = 2
+	if bytes.Equal(Hash(m), Hash(n)) {
+		t.Errorf("different self-containing maps have the same fingerprint")
+	}
+
+	s := []interface{}{nil, 1}
+	s[0] = s
+	if len(Hash(s)) == 0 {
+		t.Errorf("no fingerprint for a self-containing slice")
+	}
+
+	type node struct {
+		Next *node
+	}
+	p := &node{}
+	p.Next = p
+	if len(Hash(p)) == 0 {
+		t.Errorf("no fingerprint for a pointer cycle")
+	}
+}
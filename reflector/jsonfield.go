@@ -0,0 +1,162 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JSONField is a struct field as seen by encoding/json.
+type JSONField struct {
+	// Name is the name of the field in JSON.
+	Name string
+	// Index is the index sequence of the field within the struct, as for
+	// reflect.Type.FieldByIndex; it is longer than one for promoted fields.
+	Index []int
+	// Tagged tells whether the name comes from the json tag.
+	Tagged bool
+	// OmitEmpty tells whether the field has the omitempty option.
+	OmitEmpty bool
+	// OmitZero tells whether the field has the omitzero option.
+	OmitZero bool
+}
+
+// jsonFieldCache caches the fields of struct types.
+var jsonFieldCache sync.Map
+
+// JSONFields returns the fields of a struct type as encoding/json sees them,
+// in field order: fields are named by their json tag or their name, fields
+// tagged "-" and unexported fields are skipped and the fields of embedded
+// structs are promoted, unless hidden by shallower fields with the same
+// name; among fields at the same depth the only tagged one wins, and if
+// there is none (or more than one) the name is dropped altogether.
+func JSONFields(typ reflect.Type) []JSONField {
+	if cached, ok := jsonFieldCache.Load(typ); ok {
+		return cached.([]JSONField)
+	}
+
+	var candidates []JSONField
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+	current, visited := []level{{typ: typ}}, map[reflect.Type]bool{}
+	for len(current) > 0 {
+		var next []level
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+			for i := 0; i < l.typ.NumField(); i++ {
+				sf := l.typ.Field(i)
+				t := sf.Type
+				if t.Kind() == reflect.Ptr {
+					t = t.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && t.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(append([]int{}, l.index...), i)
+				if name == "" && sf.Anonymous && t.Kind() == reflect.Struct {
+					next = append(next, level{typ: t, index: index})
+					continue
+				}
+				field := JSONField{Name: name, Index: index, Tagged: name != ""}
+				if name == "" {
+					field.Name = sf.Name
+				}
+				for _, option := range strings.Split(options, ",") {
+					switch option {
+					case "omitempty":
+						field.OmitEmpty = true
+					case "omitzero":
+						field.OmitZero = true
+					}
+				}
+				candidates = append(candidates, field)
+			}
+		}
+		current = next
+	}
+
+	// keep the dominant field for each name
+	byName := map[string][]JSONField{}
+	for _, field := range candidates {
+		byName[field.Name] = append(byName[field.Name], field)
+	}
+	result := []JSONField{}
+	for _, field := range candidates {
+		if dominant, ok := dominantField(byName[field.Name]); ok && equalIndex(dominant.Index, field.Index) {
+			result = append(result, field)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return lessIndex(result[i].Index, result[j].Index) })
+	jsonFieldCache.Store(typ, result)
+	return result
+}
+
+// dominantField returns the field that wins among those with the same name:
+// the shallowest one, or the only tagged one among the shallowest.
+func dominantField(candidates []JSONField) (JSONField, bool) {
+	depth := len(candidates[0].Index)
+	for _, field := range candidates {
+		if len(field.Index) < depth {
+			depth = len(field.Index)
+		}
+	}
+	var shallowest, tagged []JSONField
+	for _, field := range candidates {
+		if len(field.Index) == depth {
+			shallowest = append(shallowest, field)
+			if field.Tagged {
+				tagged = append(tagged, field)
+			}
+		}
+	}
+	switch {
+	case len(shallowest) == 1:
+		return shallowest[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	}
+	return JSONField{}, false
+}
+
+// equalIndex returns whether two index sequences are the same.
+func equalIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lessIndex orders index sequences as the fields they refer to appear in
+// the struct.
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
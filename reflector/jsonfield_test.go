@@ -0,0 +1,75 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type jsonLeft struct {
+	Name   string `json:"Name"`
+	Shared int
+	Deep   string
+}
+
+type jsonRight struct {
+	Name   string
+	Shared int
+}
+
+type jsonInner struct {
+	Deep string
+}
+
+type jsonSample struct {
+	jsonLeft
+	*jsonRight
+	Inner   jsonInner `json:"inner,omitempty"`
+	Skipped int       `json:"-"`
+	Dash    int       `json:"-,"`
+	Zero    int       `json:",omitzero"`
+	hidden  int
+}
+
+func TestJSONFields(t *testing.T) {
+	expected := []JSONField{
+		{Name: "Name", Index: []int{0, 0}, Tagged: true},
+		{Name: "Deep", Index: []int{0, 2}},
+		{Name: "inner", Index: []int{2}, Tagged: true, OmitEmpty: true},
+		{Name: "-", Index: []int{4}, Tagged: true},
+		{Name: "Zero", Index: []int{5}, OmitZero: true},
+	}
+	if actual := JSONFields(reflect.TypeOf(jsonSample{})); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	// the same members as encoding/json
+	object := jsonSample{jsonLeft: jsonLeft{Name: "left", Shared: 1, Deep: "deep"}, jsonRight: &jsonRight{Name: "right", Shared: 2}, Zero: 1}
+	data, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != len(expected) {
+		t.Errorf("expected %d members, encoding/json has %v", len(expected), members)
+	}
+	for _, field := range expected {
+		if _, ok := members[field.Name]; !ok {
+			t.Errorf("no member %s in %v", field.Name, members)
+		}
+	}
+
+	if value, err := ResolvePointer(object, "/Name"); err != nil || value.Interface() != "left" {
+		t.Errorf("/Name: expected the tagged field, got %v, %v", value, err)
+	}
+	if _, err := ResolvePointer(object, "/Shared"); err == nil {
+		t.Errorf("/Shared: expected an error for an ambiguous field")
+	}
+}
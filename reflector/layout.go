@@ -0,0 +1,224 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldLayout describes where a field sits in memory within its struct.
+type FieldLayout struct {
+	// Name is the name of the field.
+	Name string
+	// Type is the type of the field.
+	Type string
+	// Offset is the offset of the field from the start of the struct.
+	Offset uintptr
+	// Size is the size of the field.
+	Size uintptr
+	// Align is the alignment of the field.
+	Align uintptr
+	// Padding is the number of unused bytes between the end of the field and
+	// the next one (or the end of the struct).
+	Padding uintptr
+}
+
+// StructLayout describes the memory layout of a struct type.
+type StructLayout struct {
+	// Type is the struct type.
+	Type reflect.Type
+	// Size is the size of the struct.
+	Size uintptr
+	// Align is the alignment of the struct.
+	Align uintptr
+	// Padding is the total number of unused bytes in the struct.
+	Padding uintptr
+	// Fields is the layout of the fields, in declaration order.
+	Fields []FieldLayout
+	// Suggested is an ordering of the field names that minimises the size
+	// of the struct.
+	Suggested []string
+	// SuggestedSize is the size of the struct with the suggested ordering.
+	SuggestedSize uintptr
+}
+
+// Layout returns the memory layout of a struct type, along with a suggested
+// field ordering that minimises padding (fields sorted by decreasing
+// alignment, then size, with zero-sized fields first so that none ends up
+// last, where it would need padding of its own).
+func Layout(typ reflect.Type) (StructLayout, error) {
+	if typ.Kind() != reflect.Struct {
+		return StructLayout{}, fmt.Errorf("reflector: %s is not a struct", typ)
+	}
+	layout := StructLayout{
+		Type:  typ,
+		Size:  typ.Size(),
+		Align: uintptr(typ.Align()),
+	}
+	fields := make([]FieldLayout, typ.NumField())
+	for i := range fields {
+		field := typ.Field(i)
+		fields[i] = FieldLayout{
+			Name:   field.Name,
+			Type:   field.Type.String(),
+			Offset: field.Offset,
+			Size:   field.Type.Size(),
+			Align:  uintptr(field.Type.FieldAlign()),
+		}
+	}
+	for i := range fields {
+		end := typ.Size()
+		if i < len(fields)-1 {
+			end = fields[i+1].Offset
+		}
+		fields[i].Padding = end - fields[i].Offset - fields[i].Size
+		layout.Padding += fields[i].Padding
+	}
+	layout.Fields = fields
+
+	sorted := make([]FieldLayout, len(fields))
+	copy(sorted, fields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Size == 0) != (sorted[j].Size == 0) {
+			return sorted[i].Size == 0
+		}
+		if sorted[i].Align != sorted[j].Align {
+			return sorted[i].Align > sorted[j].Align
+		}
+		return sorted[i].Size > sorted[j].Size
+	})
+	layout.SuggestedSize = packedSize(sorted, layout.Align)
+	if layout.SuggestedSize >= layout.Size {
+		// never suggest anything worse than the current layout
+		sorted = fields
+		layout.SuggestedSize = layout.Size
+	}
+	for _, field := range sorted {
+		layout.Suggested = append(layout.Suggested, field.Name)
+	}
+	return layout, nil
+}
+
+// packedSize returns the size of a struct with the given fields, in the given
+// order, laid out as the compiler does.
+func packedSize(fields []FieldLayout, align uintptr) uintptr {
+	var offset uintptr
+	for _, field := range fields {
+		offset = alignUp(offset, field.Align) + field.Size
+	}
+	if len(fields) > 0 && fields[len(fields)-1].Size == 0 && offset > 0 {
+		// a zero-sized final field must not point past the end of the struct
+		offset++
+	}
+	return alignUp(offset, align)
+}
+
+// alignUp rounds the offset up to a multiple of the alignment.
+func alignUp(offset uintptr, align uintptr) uintptr {
+	if align <= 1 {
+		return offset
+	}
+	return (offset + align - 1) / align * align
+}
+
+// String returns a human-readable report of the layout.
+func (layout StructLayout) String() string {
+	var buffer strings.Builder
+	fmt.Fprintf(&buffer, "%s: size %d, align %d, padding %d\n", layout.Type, layout.Size, layout.Align, layout.Padding)
+	fmt.Fprintf(&buffer, "  %6s  %6s  %5s  %7s  %s\n", "offset", "size", "align", "padding", "field")
+	for _, field := range layout.Fields {
+		fmt.Fprintf(&buffer, "  %6d  %6d  %5d  %7d  %s %s\n", field.Offset, field.Size, field.Align, field.Padding, field.Name, field.Type)
+	}
+	if layout.SuggestedSize < layout.Size {
+		fmt.Fprintf(&buffer, "  suggested order (size %d, saves %d): %s\n", layout.SuggestedSize, layout.Size-layout.SuggestedSize, strings.Join(layout.Suggested, ", "))
+	} else {
+		fmt.Fprintf(&buffer, "  field order is optimal\n")
+	}
+	return buffer.String()
+}
+
+// LayoutObserver is an Observer that collects the memory layout of all the
+// (non-empty) struct types reached during a visit, each reported once.
+type LayoutObserver struct {
+	seen    map[reflect.Type]bool
+	layouts []StructLayout
+}
+
+// NewLayoutObserver returns a new LayoutObserver.
+func NewLayoutObserver() *LayoutObserver {
+	return &LayoutObserver{
+		seen: map[reflect.Type]bool{},
+	}
+}
+
+// Layouts returns the layouts of the struct types reached so far, in the
+// order they were first encountered.
+func (o *LayoutObserver) Layouts() []StructLayout {
+	return o.layouts
+}
+
+// Report writes the layouts of the struct types reached so far to the
+// stream; if wasteful is set, only those that can be made smaller are
+// reported.
+func (o *LayoutObserver) Report(writer io.Writer, wasteful bool) error {
+	for _, layout := range o.layouts {
+		if wasteful && layout.SuggestedSize >= layout.Size {
+			continue
+		}
+		if _, err := fmt.Fprintln(writer, layout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *LayoutObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && !o.seen[object.Type()] && object.NumField() > 0 {
+		o.seen[object.Type()] = true
+		layout, _ := Layout(object.Type())
+		o.layouts = append(o.layouts, layout)
+	}
+	return true
+}
+
+func (o *LayoutObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
+
+func (o *LayoutObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	return true
+}
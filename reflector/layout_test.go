@@ -0,0 +1,100 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type layoutWasteful struct {
+	A bool
+	B int32
+	C bool
+	D int16
+}
+
+type layoutOptimal struct {
+	B int32
+	D int16
+	A bool
+}
+
+type layoutZero struct {
+	A int32
+	Z struct{}
+}
+
+type layoutNested struct {
+	Wasteful layoutWasteful
+	Optimal  []layoutOptimal
+	Again    *layoutWasteful
+	Empty    struct{}
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		typ           reflect.Type
+		size          uintptr
+		padding       uintptr
+		suggested     []string
+		suggestedSize uintptr
+	}{
+		{reflect.TypeOf(layoutWasteful{}), 12, 4, []string{"B", "D", "A", "C"}, 8},
+		{reflect.TypeOf(layoutOptimal{}), 8, 1, []string{"B", "D", "A"}, 8},
+		{reflect.TypeOf(layoutZero{}), 8, 4, []string{"Z", "A"}, 4},
+		{reflect.TypeOf(struct{}{}), 0, 0, nil, 0},
+	}
+	for _, test := range tests {
+		layout, err := Layout(test.typ)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.typ, err)
+			continue
+		}
+		if layout.Size != test.size || layout.Padding != test.padding || layout.SuggestedSize != test.suggestedSize {
+			t.Errorf("%s: expected size %d, padding %d, suggested size %d, got %d, %d, %d", test.typ, test.size, test.padding, test.suggestedSize, layout.Size, layout.Padding, layout.SuggestedSize)
+		}
+		if !reflect.DeepEqual(layout.Suggested, test.suggested) {
+			t.Errorf("%s: expected order %v, got %v", test.typ, test.suggested, layout.Suggested)
+		}
+	}
+
+	layout, _ := Layout(reflect.TypeOf(layoutWasteful{}))
+	if b := layout.Fields[1]; b.Name != "B" || b.Offset != 4 || b.Size != 4 || b.Align != 4 || b.Padding != 0 {
+		t.Errorf("unexpected layout of B: %+v", b)
+	}
+	if a := layout.Fields[0]; a.Padding != 3 {
+		t.Errorf("expected 3 bytes of padding after A, got %d", a.Padding)
+	}
+	if report := layout.String(); !strings.Contains(report, "suggested order (size 8, saves 4): B, D, A, C") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+
+	if _, err := Layout(reflect.TypeOf(0)); err == nil {
+		t.Errorf("expected an error for a non-struct type")
+	}
+}
+
+func TestLayoutObserver(t *testing.T) {
+	object := layoutNested{Optimal: []layoutOptimal{{}, {}}, Again: &layoutWasteful{}}
+	observer := NewLayoutObserver()
+	Visit("", "", object, nil, observer)
+	var types []string
+	for _, layout := range observer.Layouts() {
+		types = append(types, layout.Type.Name())
+	}
+	if expected := []string{"layoutNested", "layoutWasteful", "layoutOptimal"}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, got %v", expected, types)
+	}
+
+	var buffer strings.Builder
+	if err := observer.Report(&buffer, true); err != nil {
+		t.Fatal(err)
+	}
+	if report := buffer.String(); !strings.Contains(report, "reflector.layoutWasteful: size") || strings.Contains(report, "reflector.layoutOptimal: size") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
@@ -0,0 +1,704 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Node is a node in a generic tree model of an object graph, built by a
+// NodeObserver; unlike the Observer callbacks, the tree can be navigated in
+// any order, queried, edited, serialised to JSON and decoded back into a Go
+// value.
+//
+// Leaves hold their value as a bool, int64, uint64, float64, complex128,
+// string or (for values of type time.Time, which are leaves too) time.Time.
+// Pointers and interfaces have a single child, the value they refer to,
+// unless they are nil; map entries are children named after their key, which
+// is held in Key.
+type Node struct {
+	// Kind is the kind of the value.
+	Kind reflect.Kind
+	// Type is the name of the type of the value.
+	Type string
+	// Name is the name of the node within its parent, e.g. a field name,
+	// "[2]" for list elements or "[\"key\"]" for map entries.
+	Name string
+	// Path is the full path of the node (see Observer).
+	Path string
+	// Tags are the struct tags of the field, if any.
+	Tags string
+	// Nil tells whether the value is nil.
+	Nil bool
+	// Value is the value of a leaf.
+	Value interface{}
+	// Key is the key of a map entry.
+	Key *Node
+	// Children are the elements, fields, entries or referenced value of a
+	// composite value.
+	Children []*Node
+	// Parent is the parent node, nil for the root.
+	Parent *Node
+}
+
+// NewNode visits an object and returns the root of its tree model.
+func NewNode(object interface{}) *Node {
+	observer := NewNodeObserver()
+	value := reflect.ValueOf(object)
+	if value.IsValid() {
+		// work on an addressable copy, so that unexported times can be read
+		root := reflect.New(value.Type()).Elem()
+		root.Set(value)
+		value = root
+	}
+	Visit("", "", value, nil, observer)
+	return observer.Root()
+}
+
+// Lookup returns the node with the given path in the subtree, or nil; since
+// pointers and interfaces do not add to the path, the outermost of the
+// nodes sharing a path is returned.
+func (n *Node) Lookup(path string) *Node {
+	var found *Node
+	n.Walk(func(node *Node) bool {
+		if found == nil && node.Path == path {
+			found = node
+		}
+		return found == nil && strings.HasPrefix(path, node.Path)
+	})
+	return found
+}
+
+// Child returns the child with the given name, or nil.
+func (n *Node) Child(name string) *Node {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Walk calls the function on all the nodes in the subtree, depth-first;
+// returning false skips the children of a node.
+func (n *Node) Walk(f func(node *Node) bool) {
+	if f(n) {
+		for _, child := range n.Children {
+			child.Walk(f)
+		}
+	}
+}
+
+// Add appends a child node, setting its parent and the paths of all the
+// nodes in its subtree.
+func (n *Node) Add(child *Node) {
+	child.Parent = n
+	child.repath(n.Path)
+	n.Children = append(n.Children, child)
+}
+
+// repath sets the paths of the nodes in a subtree, given the path of the
+// parent of its root.
+func (n *Node) repath(path string) {
+	n.Path = chain(path, n.Name)
+	for _, child := range n.Children {
+		child.repath(n.Path)
+	}
+}
+
+// Remove removes the child with the given name, and returns it, or nil.
+func (n *Node) Remove(name string) *Node {
+	for i, child := range n.Children {
+		if child.Name == name {
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			child.Parent = nil
+			return child
+		}
+	}
+	return nil
+}
+
+// Interface returns the value of the subtree as generic Go values: leaves as
+// their Value, lists as []interface{}, structs and maps as
+// map[string]interface{} (keyed by field name or formatted key), pointers
+// and interfaces as the value they refer to, nils as nil.
+func (n *Node) Interface() interface{} {
+	if n.Nil {
+		return nil
+	}
+	switch n.Kind {
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, len(n.Children))
+		for i, child := range n.Children {
+			list[i] = child.Interface()
+		}
+		return list
+	case reflect.Struct, reflect.Map:
+		if n.Value != nil {
+			return n.Value
+		}
+		object := map[string]interface{}{}
+		for _, child := range n.Children {
+			name := child.Name
+			if child.Key != nil {
+				name = fmt.Sprint(child.Key.Interface())
+			}
+			object[name] = child.Interface()
+		}
+		return object
+	case reflect.Ptr, reflect.Interface:
+		if len(n.Children) == 0 {
+			return nil
+		}
+		return n.Children[0].Interface()
+	}
+	return n.Value
+}
+
+// Decode stores the value of the subtree into the value pointed to by
+// target, which may be of a different type as long as the shapes match:
+// fields are matched by name, numbers are converted (if they fit) and
+// interfaces receive generic values (see Interface). Unexported fields are
+// decoded too.
+func (n *Node) Decode(target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("reflector: cannot decode into non-pointer %T", target)
+	}
+	return n.decode(value.Elem())
+}
+
+// decode stores the value of the subtree into a settable value.
+func (n *Node) decode(value reflect.Value) error {
+	if !value.CanSet() {
+		// an unexported field: write it through its address
+		value = reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+	}
+	if (n.Kind == reflect.Ptr || n.Kind == reflect.Interface) && value.Kind() != reflect.Ptr && value.Kind() != reflect.Interface {
+		// dereference the node to match the target
+		if n.Nil || len(n.Children) == 0 {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		return n.Children[0].decode(value)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if n.Nil {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		if n.Kind == reflect.Ptr || n.Kind == reflect.Interface {
+			if len(n.Children) == 0 {
+				return nil
+			}
+			return n.Children[0].decode(value.Elem())
+		}
+		return n.decode(value.Elem())
+
+	case reflect.Interface:
+		if n.Nil {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		generic := reflect.ValueOf(n.Interface())
+		if !generic.IsValid() {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		if !generic.Type().AssignableTo(value.Type()) {
+			return n.mismatch(value)
+		}
+		value.Set(generic)
+		return nil
+
+	case reflect.Slice:
+		if n.Nil {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		if n.Kind != reflect.Slice && n.Kind != reflect.Array {
+			return n.mismatch(value)
+		}
+		value.Set(reflect.MakeSlice(value.Type(), len(n.Children), len(n.Children)))
+		for i, child := range n.Children {
+			if err := child.decode(value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Array:
+		if n.Kind != reflect.Slice && n.Kind != reflect.Array {
+			return n.mismatch(value)
+		}
+		for i, child := range n.Children {
+			if i >= value.Len() {
+				return fmt.Errorf("reflector: too many elements for %s at %s", value.Type(), displayPath(n.Path))
+			}
+			if err := child.decode(value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if n.Nil {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		if n.Kind != reflect.Map {
+			return n.mismatch(value)
+		}
+		value.Set(reflect.MakeMapWithSize(value.Type(), len(n.Children)))
+		for _, child := range n.Children {
+			if child.Key == nil {
+				return fmt.Errorf("reflector: map entry with no key at %s", displayPath(child.Path))
+			}
+			key := reflect.New(value.Type().Key()).Elem()
+			if err := child.Key.decode(key); err != nil {
+				return err
+			}
+			element := reflect.New(value.Type().Elem()).Elem()
+			if err := child.decode(element); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, element)
+		}
+		return nil
+
+	case reflect.Struct:
+		if value.Type() == timeType {
+			t, ok := n.Value.(time.Time)
+			if !ok {
+				return n.mismatch(value)
+			}
+			value.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if n.Kind != reflect.Struct {
+			return n.mismatch(value)
+		}
+		for _, child := range n.Children {
+			if field := value.FieldByName(child.Name); field.IsValid() {
+				if err := child.decode(field); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		// only the nil-ness of these values is preserved
+		if n.Nil {
+			value.Set(reflect.Zero(value.Type()))
+		}
+		return nil
+	}
+	return n.decodeLeaf(value)
+}
+
+// decodeLeaf stores the value of a leaf into a settable value of a basic
+// kind, converting numbers as Convert does.
+func (n *Node) decodeLeaf(value reflect.Value) error {
+	leaf := reflect.ValueOf(n.Value)
+	switch {
+	case !leaf.IsValid():
+	case value.Kind() == reflect.Complex64 || value.Kind() == reflect.Complex128:
+		if leaf.Kind() == reflect.Complex128 {
+			value.SetComplex(leaf.Complex())
+			return nil
+		}
+		if isNumber(leaf.Kind()) {
+			f, err := Convert(n.Value, reflect.TypeOf(float64(0)))
+			if err != nil {
+				return n.overflow(value)
+			}
+			value.SetComplex(complex(f.Float(), 0))
+			return nil
+		}
+	case leaf.Kind() == value.Kind() || isNumber(leaf.Kind()) && isNumber(value.Kind()):
+		converted, err := Convert(n.Value, value.Type())
+		if err != nil {
+			return n.overflow(value)
+		}
+		value.Set(converted)
+		return nil
+	}
+	return n.mismatch(value)
+}
+
+// mismatch returns the error for a node that cannot be decoded into a value.
+func (n *Node) mismatch(value reflect.Value) error {
+	return fmt.Errorf("reflector: cannot decode %s (%s) into %s at %s", n.Kind, n.Type, value.Type(), displayPath(n.Path))
+}
+
+// overflow returns the error for a number that does not fit into a value.
+func (n *Node) overflow(value reflect.Value) error {
+	return fmt.Errorf("reflector: value %v overflows %s at %s", n.Value, value.Type(), displayPath(n.Path))
+}
+
+// nodeJSON is the JSON representation of a Node.
+type nodeJSON struct {
+	Kind     string          `json:"kind"`
+	Type     string          `json:"type"`
+	Name     string          `json:"name,omitempty"`
+	Path     string          `json:"path,omitempty"`
+	Tags     string          `json:"tags,omitempty"`
+	Nil      bool            `json:"nil,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Key      *Node           `json:"key,omitempty"`
+	Children []*Node         `json:"children,omitempty"`
+}
+
+// nodeKinds maps the names of the kinds back to them.
+var nodeKinds = func() map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+	for kind := reflect.Invalid; kind <= reflect.UnsafePointer; kind++ {
+		kinds[kind.String()] = kind
+	}
+	return kinds
+}()
+
+// MarshalJSON returns the JSON representation of the subtree; non-finite
+// floats and complex numbers are written as strings.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	out := nodeJSON{
+		Kind:     n.Kind.String(),
+		Type:     n.Type,
+		Name:     n.Name,
+		Path:     n.Path,
+		Tags:     n.Tags,
+		Nil:      n.Nil,
+		Key:      n.Key,
+		Children: n.Children,
+	}
+	if n.Value != nil {
+		value := n.Value
+		switch v := value.(type) {
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				value = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+		case complex128:
+			value = strconv.FormatComplex(v, 'g', -1, 128)
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		out.Value = raw
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON restores the subtree from its JSON representation.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var in nodeJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	kind, ok := nodeKinds[in.Kind]
+	if !ok {
+		return fmt.Errorf("reflector: unknown kind %q at %s", in.Kind, displayPath(in.Path))
+	}
+	*n = Node{
+		Kind:     kind,
+		Type:     in.Type,
+		Name:     in.Name,
+		Path:     in.Path,
+		Tags:     in.Tags,
+		Nil:      in.Nil,
+		Key:      in.Key,
+		Children: in.Children,
+	}
+	for _, child := range n.Children {
+		child.Parent = n
+	}
+	if len(in.Value) == 0 {
+		return nil
+	}
+	var err error
+	switch {
+	case kind == reflect.Bool:
+		var b bool
+		err = json.Unmarshal(in.Value, &b)
+		n.Value = b
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		var i int64
+		err = json.Unmarshal(in.Value, &i)
+		n.Value = i
+	case kind >= reflect.Uint && kind <= reflect.Uintptr:
+		var u uint64
+		err = json.Unmarshal(in.Value, &u)
+		n.Value = u
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		var f float64
+		if err = json.Unmarshal(in.Value, &f); err != nil {
+			var s string
+			if err = json.Unmarshal(in.Value, &s); err == nil {
+				f, err = strconv.ParseFloat(s, 64)
+			}
+		}
+		n.Value = f
+	case kind == reflect.Complex64 || kind == reflect.Complex128:
+		var s string
+		var c complex128
+		if err = json.Unmarshal(in.Value, &s); err == nil {
+			c, err = strconv.ParseComplex(s, 128)
+		}
+		n.Value = c
+	case kind == reflect.Struct && in.Type == timeType.String():
+		var t time.Time
+		err = json.Unmarshal(in.Value, &t)
+		n.Value = t
+	default:
+		var s string
+		err = json.Unmarshal(in.Value, &s)
+		n.Value = s
+	}
+	if err != nil {
+		return fmt.Errorf("reflector: invalid value at %s: %v", displayPath(in.Path), err)
+	}
+	return nil
+}
+
+// NodeObserver is an Observer that builds the tree model of the visited
+// object graph; cycles through pointers, maps and slices are cut, leaving
+// the value that would close the cycle with no children (it decodes as a
+// pointer to a zero value, or as an empty map or slice).
+type NodeObserver struct {
+	root     *Node
+	stack    []*Node
+	keys     []*nodeKeys
+	pointers map[hashPointer]bool
+}
+
+// nodeKeys are the keys of a map being visited, in the order Visit visits
+// its entries; next is the index of the key of the next entry.
+type nodeKeys struct {
+	keys []reflect.Value
+	next int
+}
+
+// NewNodeObserver returns a new NodeObserver.
+func NewNodeObserver() *NodeObserver {
+	return &NodeObserver{
+		pointers: map[hashPointer]bool{},
+	}
+}
+
+// Root returns the root of the tree built so far.
+func (o *NodeObserver) Root() *Node {
+	return o.root
+}
+
+// Reset discards the tree built so far.
+func (o *NodeObserver) Reset() {
+	o.root = nil
+	o.stack = nil
+	o.keys = nil
+	o.pointers = map[hashPointer]bool{}
+}
+
+func (o *NodeObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	if len(o.stack) > 0 {
+		o.stack[len(o.stack)-1].Nil = true
+	}
+	return true
+}
+
+func (o *NodeObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	node := o.node(path, name, tags, object)
+	switch object.Kind() {
+	case reflect.Invalid:
+		node.Nil = true
+	case reflect.Bool:
+		node.Value = object.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		node.Value = object.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		node.Value = object.Uint()
+	case reflect.Float32, reflect.Float64:
+		node.Value = object.Float()
+	case reflect.Complex64, reflect.Complex128:
+		node.Value = object.Complex()
+	case reflect.String:
+		node.Value = object.String()
+	}
+	o.add(node)
+	return true
+}
+
+func (o *NodeObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.leave(object)
+		o.close()
+		return true
+	}
+	o.open(o.node(path, name, tags, object))
+	return o.enter(object)
+}
+
+func (o *NodeObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.leave(object)
+		o.close()
+		return true
+	}
+	node := o.node(path, name, tags, object)
+	node.Nil = object.Kind() == reflect.Slice && object.IsNil()
+	o.open(node)
+	return o.enter(object)
+}
+
+func (o *NodeObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.close()
+		return true
+	}
+	node := o.node(path, name, tags, object)
+	o.open(node)
+	if object.Type() == timeType {
+		if t, ok := timeOf(object); ok {
+			// times are leaves, their internals are not relevant
+			node.Value = t
+			return false
+		}
+	}
+	return true
+}
+
+func (o *NodeObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.keys = o.keys[:len(o.keys)-1]
+		o.leave(object)
+		o.close()
+		return true
+	}
+	node := o.node(path, name, tags, object)
+	node.Nil = object.IsNil()
+	o.open(node)
+	// entries are visited in key order: keep the keys themselves, which
+	// formatted names cannot always tell apart (e.g. int(1) and int8(1)),
+	// to be added to the entries in the same order
+	o.keys = append(o.keys, &nodeKeys{keys: SortedKeys(object)})
+	return o.enter(object)
+}
+
+func (o *NodeObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(o.node(path, name, tags, object))
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *NodeObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	node := o.node(path, name, tags, object)
+	node.Nil = object.IsNil()
+	o.add(node)
+	return true
+}
+
+func (o *NodeObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	node := o.node(path, name, tags, object)
+	node.Nil = object.IsNil()
+	o.add(node)
+	return true
+}
+
+func (o *NodeObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	node := o.node(path, name, tags, object)
+	node.Nil = object.IsNil()
+	o.add(node)
+	return true
+}
+
+// node returns a new node for a value.
+func (o *NodeObserver) node(path string, name string, tags string, object reflect.Value) *Node {
+	node := &Node{
+		Kind: object.Kind(),
+		Name: name,
+		Path: chain(path, name),
+		Tags: tags,
+	}
+	if object.IsValid() {
+		node.Type = object.Type().String()
+	}
+	return node
+}
+
+// add appends a node to the innermost composite node; the children of maps
+// get their key.
+func (o *NodeObserver) add(node *Node) {
+	if len(o.stack) == 0 {
+		o.root = node
+		return
+	}
+	parent := o.stack[len(o.stack)-1]
+	if keys := o.keys; parent.Kind == reflect.Map && len(keys) > 0 {
+		if current := keys[len(keys)-1]; current.next < len(current.keys) {
+			observer := NewNodeObserver()
+			Visit("", "", current.keys[current.next], nil, observer)
+			node.Key = observer.Root()
+			current.next++
+		}
+	}
+	node.Parent = parent
+	parent.Children = append(parent.Children, node)
+}
+
+// open adds a composite node and makes it the innermost one.
+func (o *NodeObserver) open(node *Node) {
+	o.add(node)
+	o.stack = append(o.stack, node)
+}
+
+// enter marks a pointer, map or slice as being followed and returns true or,
+// if it is already being followed (i.e. it closes a cycle), returns false so
+// that its children are skipped.
+func (o *NodeObserver) enter(object reflect.Value) bool {
+	id, ok := identity(object)
+	if !ok {
+		return true
+	}
+	if o.pointers[id] {
+		return false
+	}
+	o.pointers[id] = true
+	return true
+}
+
+// leave marks the pointer, map or slice of the innermost composite node as
+// no longer being followed, unless the node closed a cycle and its children
+// were skipped.
+func (o *NodeObserver) leave(object reflect.Value) {
+	if len(o.stack) == 0 || len(o.stack[len(o.stack)-1].Children) == 0 {
+		return
+	}
+	if id, ok := identity(object); ok {
+		delete(o.pointers, id)
+	}
+}
+
+// close completes the innermost composite node.
+func (o *NodeObserver) close() {
+	if len(o.stack) > 0 {
+		o.stack = o.stack[:len(o.stack)-1]
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNodeAddPaths(t *testing.T) {
+	type inner struct {
+		Values []int
+	}
+	type outer struct {
+		A inner
+	}
+	root := NewNode(outer{})
+	subtree := NewNode(inner{Values: []int{1, 2}})
+	subtree.Name = "B"
+	root.Add(subtree)
+	for path, expected := range map[string]string{"B": "struct", "B.Values": "slice", "B.Values[1]": "int"} {
+		node := root.Lookup(path)
+		if node == nil {
+			t.Errorf("no node at %s", path)
+			continue
+		}
+		if node.Kind.String() != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, node.Kind)
+		}
+	}
+}
+
+func TestNodeMapKeys(t *testing.T) {
+	m := map[interface{}]string{1: "int", int8(1): "int8", "1": "string"}
+	root := NewNode(m)
+	if len(root.Children) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(root.Children))
+	}
+	for _, entry := range root.Children {
+		if entry.Key == nil {
+			t.Fatalf("no key for %s", entry.Path)
+		}
+		key := entry.Key
+		for key.Kind == reflect.Interface {
+			key = key.Children[0]
+		}
+		value := entry
+		for value.Kind == reflect.Interface {
+			value = value.Children[0]
+		}
+		if key.Kind.String() != value.Value {
+			t.Errorf("entry %s has key of kind %s but value %v", entry.Path, key.Kind, value.Value)
+		}
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	type record struct {
+		Name  string
+		Count int16
+		Tags  map[string]float64
+		Next  *record
+	}
+	original := record{Name: "a", Count: 3, Tags: map[string]float64{"x": 1.5, "y": 2}, Next: &record{Name: "b"}}
+	data, err := json.Marshal(NewNode(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var node Node
+	if err := json.Unmarshal(data, &node); err != nil {
+		t.Fatal(err)
+	}
+	var decoded record
+	if err := node.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("expected %+v, got %+v", original, decoded)
+	}
+}
+
+func TestNodeDecodeNumbers(t *testing.T) {
+	type source struct {
+		I8  interface{}
+		U   interface{}
+		F32 interface{}
+		C   interface{}
+	}
+	type numbers struct {
+		I8  int8
+		U   uint
+		F32 float32
+		C   complex64
+	}
+	tests := []struct {
+		object  source
+		decoded numbers
+		ok      bool
+	}{
+		{source{100, int64(7), 0.5, 2}, numbers{I8: 100, U: 7, F32: 0.5, C: 2}, true},
+		{source{uint(1), 2.0, 3, complex(1, 2)}, numbers{I8: 1, U: 2, F32: 3, C: complex(1, 2)}, true},
+		{source{I8: 200}, numbers{}, false},
+		{source{I8: 1.5}, numbers{}, false},
+		{source{U: -1}, numbers{}, false},
+		{source{I8: "1"}, numbers{}, false},
+		{source{C: "1"}, numbers{}, false},
+	}
+	for _, test := range tests {
+		var decoded numbers
+		err := NewNode(test.object).Decode(&decoded)
+		if test.ok && (err != nil || decoded != test.decoded) {
+			t.Errorf("%v: expected %+v, got %+v, %v", test.object, test.decoded, decoded, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: expected an error", test.object)
+		}
+	}
+}
+
+func TestNodeCycles(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	s := []interface{}{1, nil}
+	s[1] = s
+	type loop struct {
+		Next *loop
+	}
+	p := &loop{}
+	p.Next = p
+	tests := []struct {
+		object interface{}
+		path   string
+	}{
+		{m, `["self"]`},
+		{s, "[1]"},
+		{p, "Next"},
+	}
+	for _, test := range tests {
+		root := NewNode(test.object)
+		node := root.Lookup(test.path)
+		if node == nil {
+			t.Errorf("%s: no node", test.path)
+			continue
+		}
+		for len(node.Children) == 1 && (node.Kind == reflect.Interface || node.Kind == reflect.Ptr) {
+			node = node.Children[0]
+		}
+		if len(node.Children) != 0 || node.Nil {
+			t.Errorf("%s: expected the cycle to be cut, got %d children", test.path, len(node.Children))
+		}
+	}
+}
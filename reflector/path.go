@@ -0,0 +1,279 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unsafe"
+)
+
+// PathError is the error returned when a path is invalid or cannot be
+// resolved; it names the segment that failed.
+type PathError struct {
+	// Path is the whole path.
+	Path string
+	// Segment is the segment that failed, as written in the path.
+	Segment string
+	// Column is the (1-based) column of the segment in the path, or 0 if
+	// the error is not about a segment (e.g. the object is nil).
+	Column int
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the description of the error.
+func (e *PathError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("reflector: cannot resolve %q: %v", e.Path, e.Err)
+	}
+	if e.Segment == "" {
+		return fmt.Sprintf("reflector: invalid path %q at column %d: %v", e.Path, e.Column, e.Err)
+	}
+	return fmt.Sprintf("reflector: cannot resolve %q at %s (column %d): %v", e.Path, e.Segment, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// pathSegment is a segment of a path: either a dotted name, or the text
+// within brackets, unquoted if it was quoted; in patterns, it can be a
+// wildcard (* or [*]) or a recursive descent (**).
+type pathSegment struct {
+	name      string
+	bracket   bool
+	quoted    bool
+	text      string
+	column    int
+	wildcard  bool
+	recursive bool
+}
+
+// parsePath splits a path into its segments.
+func parsePath(path string) ([]pathSegment, error) {
+	return splitPath(path, false)
+}
+
+// parsePattern splits a pattern, i.e. a path that can contain wildcards,
+// into its segments.
+func parsePattern(pattern string) ([]pathSegment, error) {
+	return splitPath(pattern, true)
+}
+
+// splitPath splits a path into its segments, recognising wildcards if
+// asked to.
+func splitPath(path string, wildcards bool) ([]pathSegment, error) {
+	var segments []pathSegment
+	invalid := func(column int, format string, args ...interface{}) error {
+		return &PathError{Path: path, Column: column, Err: fmt.Errorf(format, args...)}
+	}
+	for i := 0; i < len(path); {
+		start := i
+		switch {
+		case path[i] == '[':
+			end := i + 1
+			segment := pathSegment{bracket: true, column: start + 1}
+			if end < len(path) && (path[end] == '"' || path[end] == '`') {
+				// a quoted key: find the closing quote, honouring escapes
+				quote := path[end]
+				j := end + 1
+				for ; j < len(path) && path[j] != quote; j++ {
+					if path[j] == '\\' && quote == '"' {
+						j++
+					}
+				}
+				if j >= len(path) {
+					return nil, invalid(end+1, "unterminated quoted key")
+				}
+				key, err := strconv.Unquote(path[end : j+1])
+				if err != nil {
+					return nil, invalid(end+1, "invalid quoted key %s", path[end:j+1])
+				}
+				segment.name, segment.quoted = key, true
+				end = j + 1
+				if end >= len(path) || path[end] != ']' {
+					return nil, invalid(end+1, "expected ] after quoted key")
+				}
+			} else {
+				j := strings.IndexByte(path[end:], ']')
+				if j < 0 {
+					return nil, invalid(start+1, "unterminated [")
+				}
+				segment.name = strings.TrimSpace(path[end : end+j])
+				if segment.name == "" {
+					return nil, invalid(start+1, "empty []")
+				}
+				end += j
+				segment.wildcard = wildcards && segment.name == "*"
+			}
+			i = end + 1
+			segment.text = path[start:i]
+			segments = append(segments, segment)
+		case path[i] == '.' || i == 0:
+			if path[i] == '.' {
+				i++
+			}
+			j := i
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			name := path[i:j]
+			segment := pathSegment{name: name, text: name, column: i + 1}
+			switch {
+			case wildcards && name == "*":
+				segment.wildcard = true
+			case wildcards && name == "**":
+				segment.recursive = true
+			case !isPathName(name):
+				return nil, invalid(i+1, "invalid name %q", name)
+			}
+			segments = append(segments, segment)
+			i = j
+		default:
+			return nil, invalid(i+1, "unexpected %q", path[i])
+		}
+	}
+	return segments, nil
+}
+
+// isPathName returns whether a dotted segment is a valid name.
+func isPathName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// fail returns the error for a segment that cannot be resolved.
+func (s pathSegment) fail(path string, format string, args ...interface{}) error {
+	return &PathError{Path: path, Segment: s.text, Column: s.column, Err: fmt.Errorf(format, args...)}
+}
+
+// indirect dereferences pointers and interfaces; it returns false if it
+// meets a nil one.
+func indirect(value reflect.Value) (reflect.Value, bool) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value, false
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
+
+// child returns the child of a (dereferenced) value addressed by a segment.
+func (s pathSegment) child(path string, value reflect.Value) (reflect.Value, error) {
+	switch value.Kind() {
+	case reflect.Struct:
+		if s.bracket && !s.quoted {
+			return value, s.fail(path, "cannot index %s", value.Type())
+		}
+		field := value.FieldByName(s.name)
+		if !field.IsValid() {
+			return value, s.fail(path, "no field %s in %s", s.name, value.Type())
+		}
+		return field, nil
+
+	case reflect.Slice, reflect.Array, reflect.String:
+		if !s.bracket || s.quoted {
+			return value, s.fail(path, "cannot get %s of %s", s.name, value.Type())
+		}
+		index, err := strconv.Atoi(s.name)
+		if err != nil {
+			return value, s.fail(path, "invalid index %s", s.name)
+		}
+		if index < 0 || index >= value.Len() {
+			return value, s.fail(path, "index %d out of range [0:%d]", index, value.Len())
+		}
+		return value.Index(index), nil
+
+	case reflect.Map:
+		key, err := s.key(value)
+		if err != nil {
+			return value, s.fail(path, "%v", err)
+		}
+		element := value.MapIndex(key)
+		if !element.IsValid() {
+			return value, s.fail(path, "no key %s in %s", s.name, value.Type())
+		}
+		return element, nil
+	}
+	return value, s.fail(path, "cannot get %s of %s", s.text, value.Type())
+}
+
+// key returns the map key addressed by a segment, converted to the key type
+// of the map; if the segment is not a valid literal of that type, the key is
+// looked up by its formatted value.
+func (s pathSegment) key(value reflect.Value) (reflect.Value, error) {
+	typ := value.Type().Key()
+	if !s.bracket && typ.Kind() != reflect.String {
+		return reflect.Value{}, fmt.Errorf("cannot get %s of %s", s.name, value.Type())
+	}
+	if key, err := parseLiteral(s.name, typ); err == nil {
+		return key, nil
+	}
+	text := s.text[1 : len(s.text)-1]
+	for _, key := range value.MapKeys() {
+		if formatted := DefaultFormatter.FormatValue(key); formatted == text || formatted == s.name {
+			return key, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("invalid key %s for %s", s.name, value.Type())
+}
+
+// parseLiteral converts the text of a literal to a value of the given type.
+func parseLiteral(text string, typ reflect.Type) (reflect.Value, error) {
+	value := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return value, err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 0, typ.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(text, 0, typ.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, typ.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetFloat(f)
+	default:
+		return value, fmt.Errorf("cannot parse %s literal", typ)
+	}
+	return value, nil
+}
+
+// exposed returns a value that can be read (and, if addressable, written)
+// through the reflect API even if it was reached through unexported fields.
+func exposed(value reflect.Value) reflect.Value {
+	if !value.CanInterface() && value.CanAddr() {
+		return reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+	}
+	return value
+}
@@ -0,0 +1,331 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits a JSON Pointer (RFC 6901) into its reference tokens,
+// unescaping ~1 into / and ~0 into ~; the empty pointer, which refers to the
+// whole document, has no tokens.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("reflector: invalid JSON pointer %q: it must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 >= len(token) || token[j+1] != '0' && token[j+1] != '1') {
+				return nil, fmt.Errorf("reflector: invalid JSON pointer %q: invalid escape in %q", pointer, token)
+			}
+		}
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// FormatPointer returns the JSON Pointer made of the given reference tokens,
+// escaping ~ as ~0 and / as ~1.
+func FormatPointer(tokens ...string) string {
+	var buffer strings.Builder
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, token := range tokens {
+		buffer.WriteByte('/')
+		buffer.WriteString(escaper.Replace(token))
+	}
+	return buffer.String()
+}
+
+// ResolvePointer returns the value referred to by a JSON Pointer within an
+// object: struct fields are addressed by their json tag names (fields of
+// embedded structs being promoted, as encoding/json does), map entries by
+// their keys and slice and array elements by their indexes.
+func ResolvePointer(object interface{}, pointer string) (reflect.Value, error) {
+	path, err := pointerToPath(reflect.ValueOf(object), pointer, false)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return Get(object, path)
+}
+
+// SetPointer assigns a value at a JSON Pointer within the object pointed to
+// by object (see ResolvePointer), using DefaultSetter.
+func SetPointer(object interface{}, pointer string, value interface{}) error {
+	return DefaultSetter.SetPointer(object, pointer, value)
+}
+
+// SetPointer assigns a value at a JSON Pointer within the object pointed to
+// by object (see ResolvePointer); as in JSON Patch, a final - token appends
+// the value to a slice.
+func (s *Setter) SetPointer(object interface{}, pointer string, value interface{}) error {
+	path, err := pointerToPath(reflect.ValueOf(object), pointer, true)
+	if err != nil {
+		return err
+	}
+	setter := *s
+	if strings.HasSuffix(pointer, "/-") {
+		setter.Grow = true
+	}
+	return setter.Set(object, path, value)
+}
+
+// PointerToPath converts a JSON Pointer within an object into the equivalent
+// dotted path, as used by Get, Set and the Observer.
+func PointerToPath(object interface{}, pointer string) (string, error) {
+	return pointerToPath(reflect.ValueOf(object), pointer, false)
+}
+
+// pointerToPath converts a JSON Pointer into a dotted path, following the
+// value where it is available and its type where it is nil; if setting, a
+// final - token refers to the element past the end of a slice, and indexes
+// are not checked against the length of slices.
+func pointerToPath(value reflect.Value, pointer string, setting bool) (string, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	if !value.IsValid() {
+		if len(tokens) == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("reflector: cannot resolve JSON pointer %q in nil", pointer)
+	}
+	typ := value.Type()
+	fail := func(i int, format string, args ...interface{}) error {
+		return fmt.Errorf("reflector: cannot resolve JSON pointer %q at %q: %s", pointer, FormatPointer(tokens[:i+1]...), fmt.Sprintf(format, args...))
+	}
+	path := ""
+	for i, token := range tokens {
+		// dereference pointers and interfaces, down to the type only if nil
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+			if value.IsValid() && !value.IsNil() {
+				value = value.Elem()
+				typ = value.Type()
+				continue
+			}
+			if typ.Kind() == reflect.Interface {
+				return "", fail(i, "nil %s", typ)
+			}
+			value, typ = reflect.Value{}, typ.Elem()
+		}
+
+		switch typ.Kind() {
+		case reflect.Struct:
+			index, ok := jsonField(typ, token)
+			if !ok {
+				return "", fail(i, "no member %s in %s", token, typ)
+			}
+			for k, n := range index {
+				if k > 0 && typ.Kind() == reflect.Ptr {
+					// an embedded pointer to struct
+					if value.IsValid() && value.IsNil() {
+						value = reflect.Value{}
+					} else if value.IsValid() {
+						value = value.Elem()
+					}
+					typ = typ.Elem()
+				}
+				field := typ.Field(n)
+				path = chain(path, field.Name)
+				if value.IsValid() {
+					value = value.Field(n)
+				}
+				typ = field.Type
+			}
+
+		case reflect.Slice, reflect.Array:
+			length := -1
+			switch {
+			case typ.Kind() == reflect.Array:
+				length = typ.Len()
+			case value.IsValid():
+				length = value.Len()
+			}
+			if token == "-" && setting && i == len(tokens)-1 && typ.Kind() == reflect.Slice {
+				// the element past the end of the slice
+				if length < 0 {
+					length = 0
+				}
+				return chain(path, fmt.Sprintf("[%d]", length)), nil
+			}
+			n, err := strconv.Atoi(token)
+			if err != nil || n < 0 || token != strconv.Itoa(n) {
+				return "", fail(i, "invalid index %s", token)
+			}
+			if length >= 0 && n >= length && (!setting || typ.Kind() == reflect.Array) {
+				return "", fail(i, "index %d out of range [0:%d]", n, length)
+			}
+			path = chain(path, fmt.Sprintf("[%d]", n))
+			if value.IsValid() && n < length {
+				value = value.Index(n)
+			} else {
+				value = reflect.Value{}
+			}
+			typ = typ.Elem()
+
+		case reflect.Map:
+			key, err := parseLiteral(token, typ.Key())
+			if err != nil {
+				return "", fail(i, "invalid key %s for %s", token, typ)
+			}
+			path = chain(path, "["+DefaultFormatter.FormatValue(key)+"]")
+			if value.IsValid() {
+				value = value.MapIndex(key)
+			}
+			typ = typ.Elem()
+			if value.IsValid() {
+				typ = value.Type()
+			}
+
+		default:
+			return "", fail(i, "cannot resolve %s in %s", token, typ)
+		}
+	}
+	return path, nil
+}
+
+// PathToPointer converts a dotted path within an object into the equivalent
+// JSON Pointer, naming struct fields by their json tags; embedded structs
+// whose fields are promoted contribute no token.
+func PathToPointer(object interface{}, path string) (string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	value := reflect.ValueOf(object)
+	if !value.IsValid() {
+		if len(segments) == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("reflector: cannot convert %q in nil", path)
+	}
+	typ := value.Type()
+	var tokens []string
+	for _, segment := range segments {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+			if value.IsValid() && !value.IsNil() {
+				value = value.Elem()
+				typ = value.Type()
+				continue
+			}
+			if typ.Kind() == reflect.Interface {
+				return "", segment.fail(path, "nil %s", typ)
+			}
+			value, typ = reflect.Value{}, typ.Elem()
+		}
+
+		switch typ.Kind() {
+		case reflect.Struct:
+			if segment.bracket && !segment.quoted {
+				return "", segment.fail(path, "cannot index %s", typ)
+			}
+			field, ok := typ.FieldByName(segment.name)
+			if !ok {
+				return "", segment.fail(path, "no field %s in %s", segment.name, typ)
+			}
+			name, promoted, ok := jsonName(field)
+			if !ok {
+				return "", segment.fail(path, "field %s of %s has no JSON name", segment.name, typ)
+			}
+			if !promoted {
+				tokens = append(tokens, name)
+			}
+			if value.IsValid() {
+				value, _ = value.FieldByIndexErr(field.Index)
+			}
+			typ = field.Type
+
+		case reflect.Slice, reflect.Array:
+			n, err := strconv.Atoi(segment.name)
+			if !segment.bracket || segment.quoted || err != nil || n < 0 {
+				return "", segment.fail(path, "invalid index %s", segment.name)
+			}
+			tokens = append(tokens, strconv.Itoa(n))
+			if value.IsValid() {
+				if n >= value.Len() {
+					return "", segment.fail(path, "index %d out of range [0:%d]", n, value.Len())
+				}
+				value = value.Index(n)
+			}
+			typ = typ.Elem()
+
+		case reflect.Map:
+			var key reflect.Value
+			if value.IsValid() {
+				key, err = segment.key(value)
+			} else {
+				key, err = parseLiteral(segment.name, typ.Key())
+			}
+			if err != nil {
+				return "", segment.fail(path, "%v", err)
+			}
+			switch key.Kind() {
+			case reflect.String:
+				tokens = append(tokens, key.String())
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				tokens = append(tokens, DefaultFormatter.FormatValue(key))
+			default:
+				return "", segment.fail(path, "key of %s has no JSON form", typ)
+			}
+			if value.IsValid() {
+				value = value.MapIndex(key)
+			}
+			typ = typ.Elem()
+			if value.IsValid() {
+				typ = value.Type()
+			}
+
+		default:
+			return "", segment.fail(path, "cannot get %s of %s", segment.text, typ)
+		}
+	}
+	return FormatPointer(tokens...), nil
+}
+
+// jsonName returns the name of a struct field in JSON, or whether it is an
+// embedded struct whose fields are promoted; fields that are not visible in
+// JSON have no name.
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" && field.Anonymous {
+		inner := field.Type
+		if inner.Kind() == reflect.Ptr {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct {
+			return "", true, true
+		}
+	}
+	if field.PkgPath != "" {
+		return "", false, false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, false, true
+}
+
+// jsonField returns the index sequence of the field of a struct type with
+// the given JSON name (see JSONFields).
+func jsonField(typ reflect.Type, name string) ([]int, bool) {
+	for _, field := range JSONFields(typ) {
+		if field.Name == name {
+			return field.Index, true
+		}
+	}
+	return nil, false
+}
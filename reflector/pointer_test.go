@@ -0,0 +1,144 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"testing"
+)
+
+type pointerBase struct {
+	ID int `json:"id"`
+}
+
+type pointerItem struct {
+	Name string `json:"name"`
+}
+
+type pointerDocument struct {
+	*pointerBase
+	Title   string            `json:"title"`
+	Items   []pointerItem     `json:"items"`
+	Fixed   [2]int            `json:"fixed"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Counts  map[int]int       `json:"counts"`
+	Ignored string            `json:"-"`
+	Plain   string
+}
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		tokens  []string
+	}{
+		{"", nil},
+		{"/", []string{""}},
+		{"/a/b", []string{"a", "b"}},
+		{"/a~1b/m~0n", []string{"a/b", "m~n"}},
+		{"/~01", []string{"~1"}},
+	}
+	for _, test := range tests {
+		tokens, err := ParsePointer(test.pointer)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.pointer, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("%q: expected %q, got %q", test.pointer, test.tokens, tokens)
+		}
+		if formatted := FormatPointer(tokens...); formatted != test.pointer {
+			t.Errorf("%q: formatted as %q", test.pointer, formatted)
+		}
+	}
+	for _, pointer := range []string{"a", "/~", "/~2", "/a~"} {
+		if _, err := ParsePointer(pointer); err == nil {
+			t.Errorf("%q: expected an error", pointer)
+		}
+	}
+}
+
+func TestResolvePointer(t *testing.T) {
+	document := &pointerDocument{
+		pointerBase: &pointerBase{ID: 1},
+		Title:       "doc",
+		Items:       []pointerItem{{Name: "a"}, {Name: "b"}},
+		Fixed:       [2]int{1, 2},
+		Labels:      map[string]string{"a/b": "slash", "m~n": "tilde"},
+		Counts:      map[int]int{3: 30},
+	}
+	tests := []struct {
+		pointer  string
+		path     string
+		expected interface{}
+	}{
+		{"/title", "Title", "doc"},
+		{"/id", "pointerBase.ID", 1},
+		{"/items/1/name", "Items[1].Name", "b"},
+		{"/fixed/0", "Fixed[0]", 1},
+		{"/labels/a~1b", `Labels["a/b"]`, "slash"},
+		{"/labels/m~0n", `Labels["m~n"]`, "tilde"},
+		{"/counts/3", "Counts[3]", 30},
+		{"/Plain", "Plain", ""},
+	}
+	for _, test := range tests {
+		path, err := PointerToPath(document, test.pointer)
+		if err != nil || path != test.path {
+			t.Errorf("%s: expected path %s, got %s, %v", test.pointer, test.path, path, err)
+		}
+		value, err := ResolvePointer(document, test.pointer)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.pointer, err)
+			continue
+		}
+		if !reflect.DeepEqual(value.Interface(), test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.pointer, test.expected, value.Interface())
+		}
+		if pointer, err := PathToPointer(document, test.path); err != nil || pointer != test.pointer {
+			t.Errorf("%s: expected pointer %s, got %s, %v", test.path, test.pointer, pointer, err)
+		}
+	}
+
+	for _, pointer := range []string{"/Title", "/Ignored", "/missing", "/items/2", "/items/01", "/items/-", "/fixed/2", "/counts/x", "/title/x"} {
+		if _, err := ResolvePointer(document, pointer); err == nil {
+			t.Errorf("%s: expected an error", pointer)
+		}
+	}
+}
+
+func TestSetPointer(t *testing.T) {
+	document := &pointerDocument{}
+	for pointer, value := range map[string]interface{}{
+		"/id":          5,
+		"/title":       "new",
+		"/items/-":     pointerItem{Name: "c"},
+		"/labels/x~1y": "z",
+		"/counts/4":    "40",
+		"/fixed/1":     9,
+	} {
+		if err := SetPointer(document, pointer, value); err != nil {
+			t.Errorf("%s: unexpected error %v", pointer, err)
+		}
+	}
+	if err := SetPointer(document, "/items/-/name", "d"); err == nil {
+		t.Errorf("expected an error for - before the last token")
+	}
+	if err := SetPointer(document, "/items/-", pointerItem{Name: "d"}); err != nil {
+		t.Errorf("unexpected error appending: %v", err)
+	}
+	if err := SetPointer(document, "/items/5", pointerItem{}); err == nil {
+		t.Errorf("expected an error growing past the end")
+	}
+	expected := &pointerDocument{
+		pointerBase: &pointerBase{ID: 5},
+		Title:       "new",
+		Items:       []pointerItem{{Name: "c"}, {Name: "d"}},
+		Fixed:       [2]int{0, 9},
+		Labels:      map[string]string{"x/y": "z"},
+		Counts:      map[int]int{4: 40},
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("expected %+v, got %+v", expected, document)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// PrettyObserver is an Observer that lays out the visited object in a
+// compact, Go-like syntax, keeping composite values on a single line when
+// they fit in the given width (as in {X: 1, Y: 2}) and breaking them one
+// element per line, indented, when they do not; the layout algorithm is the
+// one described by Wadler in "A prettier printer". Map entries follow the
+// key order of Visit. The document is written out by Flush.
+type PrettyObserver struct {
+	writer    io.Writer
+	width     int
+	indent    int
+	formatter *Formatter
+	colorizer *Colorizer
+	root      ppDoc
+	frames    []*ppFrame
+}
+
+// ppFrame is a composite value whose elements are being visited.
+type ppFrame struct {
+	name  string
+	kind  reflect.Kind
+	typ   reflect.Type
+	items []ppItem
+}
+
+// ppItem is an element of a composite value: key is the field name or map
+// key it is prefixed with, if any, and null tells whether it is a nil value.
+type ppItem struct {
+	key  string
+	doc  ppDoc
+	null bool
+}
+
+// NewPrettyObserver returns a PrettyObserver writing to the given stream
+// and trying to keep lines within the given width.
+func NewPrettyObserver(writer io.Writer, width int) *PrettyObserver {
+	return &PrettyObserver{
+		writer:    writer,
+		width:     width,
+		indent:    2,
+		formatter: DefaultFormatter,
+		colorizer: &Colorizer{},
+	}
+}
+
+// Pretty returns the pretty printed representation of an object, trying to
+// keep lines within the given width.
+func Pretty(object interface{}, width int) string {
+	var buffer strings.Builder
+	observer := NewPrettyObserver(&buffer, width)
+	Visit("", "", object, nil, observer)
+	observer.Flush()
+	return buffer.String()
+}
+
+// SetIndent sets the number of spaces each nesting level is indented by.
+func (o *PrettyObserver) SetIndent(indent int) {
+	o.indent = indent
+}
+
+// SetFormatter sets the Formatter used for leaf values.
+func (o *PrettyObserver) SetFormatter(formatter *Formatter) {
+	o.formatter = formatter
+}
+
+// SetTheme sets the colour theme, if the stream supports colours; a nil
+// theme disables colours.
+func (o *PrettyObserver) SetTheme(theme *Theme) {
+	o.colorizer = NewColorizer(o.writer, theme)
+}
+
+// Flush lays out the visited object, writes it to the stream followed by a
+// new line and resets the observer.
+func (o *PrettyObserver) Flush() error {
+	root := o.root
+	o.root = nil
+	o.frames = nil
+	if root == nil {
+		return nil
+	}
+	var buffer strings.Builder
+	ppLayout(&buffer, o.width, root)
+	buffer.WriteByte('\n')
+	_, err := io.WriteString(o.writer, buffer.String())
+	return err
+}
+
+// IsLeaf returns whether the Formatter treats a composite value as a leaf,
+// which is then rendered as a single value.
+func (o *PrettyObserver) IsLeaf(object reflect.Value) bool {
+	return o.formatter.IsLeaf(object)
+}
+
+func (o *PrettyObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	o.add(name, o.nilDoc(), true)
+	return true
+}
+
+func (o *PrettyObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	if object.Kind() == reflect.Invalid {
+		o.add(name, o.styled("<invalid>", o.colorizer.Nil), false)
+	} else {
+		value := o.formatter.FormatValue(object)
+		o.add(name, ppStyled{text: value, styled: o.colorizer.Leaf(object.Kind(), value)}, false)
+	}
+	return true
+}
+
+func (o *PrettyObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, object)
+	return true
+}
+
+func (o *PrettyObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, object)
+	return true
+}
+
+func (o *PrettyObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, object)
+	return true
+}
+
+func (o *PrettyObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, object)
+	return true
+}
+
+func (o *PrettyObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, object)
+	return true
+}
+
+func (o *PrettyObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	o.add(name, o.styled(fmt.Sprintf("(%s)(%#x)", object.Type(), object.Pointer()), o.colorizer.Type), false)
+	return true
+}
+
+func (o *PrettyObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	o.add(name, o.styled(fmt.Sprintf("(%s)(%#x)", object.Type(), object.Pointer()), o.colorizer.Type), false)
+	return true
+}
+
+func (o *PrettyObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	o.add(name, o.styled(fmt.Sprintf("unsafe.Pointer(%#x)", object.Pointer()), o.colorizer.Type), false)
+	return true
+}
+
+// composite opens a frame for a composite value, or closes it turning it
+// into a document for its parent.
+func (o *PrettyObserver) composite(name string, start bool, object reflect.Value) {
+	if start {
+		o.frames = append(o.frames, &ppFrame{name: name, kind: object.Kind(), typ: object.Type()})
+		return
+	}
+	if len(o.frames) == 0 {
+		return
+	}
+	frame := o.frames[len(o.frames)-1]
+	o.frames = o.frames[:len(o.frames)-1]
+	o.add(frame.name, o.document(frame), false)
+}
+
+// document builds the document for a completed composite value.
+func (o *PrettyObserver) document(frame *ppFrame) ppDoc {
+	switch frame.kind {
+	case reflect.Ptr:
+		if len(frame.items) == 0 || frame.items[0].null {
+			return o.nilDoc()
+		}
+		return ppConcat{o.styled("&", o.colorizer.Punctuation), frame.items[0].doc}
+	case reflect.Interface:
+		if len(frame.items) == 0 {
+			return o.nilDoc()
+		}
+		return frame.items[0].doc
+	case reflect.Map:
+		return o.bracket("{", "}", frame.items)
+	case reflect.Slice, reflect.Array:
+		return o.bracket("[", "]", frame.items)
+	}
+	return o.bracket("{", "}", frame.items)
+}
+
+// bracket returns a group with the items, separated by commas, between the
+// opening and closing brackets; if the group is broken, each item goes on
+// its own line, with a trailing comma.
+func (o *PrettyObserver) bracket(open string, close string, items []ppItem) ppDoc {
+	punctuation := o.colorizer.Punctuation
+	if len(items) == 0 {
+		return o.styled(open+close, punctuation)
+	}
+	body := ppConcat{ppLine("")}
+	for i, item := range items {
+		if i > 0 {
+			body = append(body, o.styled(",", punctuation), ppLine(" "))
+		}
+		if item.key != "" {
+			body = append(body, o.styled(item.key, o.colorizer.Name), o.styled(": ", punctuation))
+		}
+		body = append(body, item.doc)
+	}
+	body = append(body, ppBreak{doc: o.styled(",", punctuation)})
+	return ppGroup{o.styled(open, punctuation), ppNest{indent: o.indent, doc: body}, ppLine(""), o.styled(close, punctuation)}
+}
+
+// styled returns a text document coloured by the given function.
+func (o *PrettyObserver) styled(text string, paint func(string) string) ppDoc {
+	return ppStyled{text: text, styled: paint(text)}
+}
+
+// nilDoc returns the document representing a nil value.
+func (o *PrettyObserver) nilDoc() ppDoc {
+	return o.styled("nil", o.colorizer.Nil)
+}
+
+// add appends an element to the innermost composite value, or makes it the
+// root if there is none; null tells whether the element is a nil value.
+func (o *PrettyObserver) add(name string, doc ppDoc, null bool) {
+	if len(o.frames) == 0 {
+		o.root = doc
+		return
+	}
+	key := ""
+	switch {
+	case name == ".value", strings.HasPrefix(name, "[") && o.frames[len(o.frames)-1].kind != reflect.Map:
+	case strings.HasPrefix(name, "["):
+		key = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+	default:
+		key = name
+	}
+	frame := o.frames[len(o.frames)-1]
+	frame.items = append(frame.items, ppItem{key: key, doc: doc, null: null})
+}
+
+// ppDoc is a document to be laid out: one of ppStyled, ppLine, ppBreak,
+// ppConcat, ppNest and ppGroup.
+type ppDoc interface{}
+
+// ppStyled is a literal string, with no new lines, and its coloured version,
+// which is what gets written out.
+type ppStyled struct {
+	text   string
+	styled string
+}
+
+// ppLine is a line break, rendered as the given string when its group fits
+// on the current line.
+type ppLine string
+
+// ppBreak is a document that is only rendered when its group does not fit on
+// the current line (e.g. a trailing comma).
+type ppBreak struct {
+	doc ppDoc
+}
+
+// ppConcat is a sequence of documents.
+type ppConcat []ppDoc
+
+// ppNest indents the line breaks in its document.
+type ppNest struct {
+	indent int
+	doc    ppDoc
+}
+
+// ppGroup is a sequence of documents laid out on a single line if it fits,
+// with all its (direct) line breaks broken otherwise.
+type ppGroup []ppDoc
+
+// ppCommand is a document to be laid out, with its indentation and mode.
+type ppCommand struct {
+	indent int
+	flat   bool
+	doc    ppDoc
+}
+
+// ppLayout lays out a document within the given width.
+func ppLayout(writer *strings.Builder, width int, doc ppDoc) {
+	column := 0
+	stack := []ppCommand{{doc: doc}}
+	for len(stack) > 0 {
+		command := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch doc := command.doc.(type) {
+		case ppStyled:
+			writer.WriteString(doc.styled)
+			column += utf8.RuneCountInString(doc.text)
+		case ppLine:
+			if command.flat {
+				writer.WriteString(string(doc))
+				column += utf8.RuneCountInString(string(doc))
+			} else {
+				writer.WriteString("\n" + strings.Repeat(" ", command.indent))
+				column = command.indent
+			}
+		case ppBreak:
+			if !command.flat {
+				stack = append(stack, ppCommand{indent: command.indent, flat: command.flat, doc: doc.doc})
+			}
+		case ppConcat:
+			for i := len(doc) - 1; i >= 0; i-- {
+				stack = append(stack, ppCommand{indent: command.indent, flat: command.flat, doc: doc[i]})
+			}
+		case ppNest:
+			stack = append(stack, ppCommand{indent: command.indent + doc.indent, flat: command.flat, doc: doc.doc})
+		case ppGroup:
+			flat := command.flat || ppFits(width-column, ppCommand{indent: command.indent, flat: true, doc: ppConcat(doc)}, stack)
+			stack = append(stack, ppCommand{indent: command.indent, flat: flat, doc: ppConcat(doc)})
+		}
+	}
+}
+
+// ppFits returns whether the command, followed by the rest of the stack up to
+// the next line break, fits in the given space.
+func ppFits(space int, command ppCommand, rest []ppCommand) bool {
+	stack := []ppCommand{command}
+	for space >= 0 {
+		if len(stack) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			stack = append(stack, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		command := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch doc := command.doc.(type) {
+		case ppStyled:
+			space -= utf8.RuneCountInString(doc.text)
+		case ppLine:
+			if !command.flat {
+				return true
+			}
+			space -= utf8.RuneCountInString(string(doc))
+		case ppBreak:
+			if !command.flat {
+				stack = append(stack, ppCommand{indent: command.indent, flat: command.flat, doc: doc.doc})
+			}
+		case ppConcat:
+			for i := len(doc) - 1; i >= 0; i-- {
+				stack = append(stack, ppCommand{indent: command.indent, flat: command.flat, doc: doc[i]})
+			}
+		case ppNest:
+			stack = append(stack, ppCommand{indent: command.indent + doc.indent, flat: command.flat, doc: doc.doc})
+		case ppGroup:
+			stack = append(stack, ppCommand{indent: command.indent, flat: command.flat, doc: ppConcat(doc)})
+		}
+	}
+	return false
+}
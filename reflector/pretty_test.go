@@ -0,0 +1,102 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPretty(t *testing.T) {
+	type point struct{ X, Y int }
+	type shape struct {
+		Name   string
+		Points []point
+		Tags   map[string]int
+	}
+	object := shape{Name: "square", Points: []point{{1, 2}, {3, 4}}, Tags: map[string]int{"b": 2, "a": 1}}
+	tests := []struct {
+		width    int
+		expected string
+	}{
+		{
+			width:    80,
+			expected: "{Name: \"square\", Points: [{X: 1, Y: 2}, {X: 3, Y: 4}], Tags: {\"a\": 1, \"b\": 2}}\n",
+		},
+		{
+			width: 30,
+			expected: "{\n" +
+				"  Name: \"square\",\n" +
+				"  Points: [\n" +
+				"    {X: 1, Y: 2},\n" +
+				"    {X: 3, Y: 4},\n" +
+				"  ],\n" +
+				"  Tags: {\"a\": 1, \"b\": 2},\n" +
+				"}\n",
+		},
+		{
+			width: 10,
+			expected: "{\n" +
+				"  Name: \"square\",\n" +
+				"  Points: [\n" +
+				"    {\n" +
+				"      X: 1,\n" +
+				"      Y: 2,\n" +
+				"    },\n" +
+				"    {\n" +
+				"      X: 3,\n" +
+				"      Y: 4,\n" +
+				"    },\n" +
+				"  ],\n" +
+				"  Tags: {\n" +
+				"    \"a\": 1,\n" +
+				"    \"b\": 2,\n" +
+				"  },\n" +
+				"}\n",
+		},
+	}
+	for _, test := range tests {
+		if actual := Pretty(object, test.width); actual != test.expected {
+			t.Errorf("width %d: expected\n%s\ngot\n%s", test.width, test.expected, actual)
+		}
+	}
+}
+
+func TestPrettyLeaves(t *testing.T) {
+	formatter := NewFormatter()
+	formatter.TimeLayout = "2006"
+	var buffer strings.Builder
+	observer := NewPrettyObserver(&buffer, 80)
+	observer.SetFormatter(formatter)
+	Visit("", "", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), nil, observer)
+	observer.Flush()
+	if actual := buffer.String(); actual != "2020\n" {
+		t.Errorf("unexpected output %q", actual)
+	}
+}
+
+func TestPrettyValues(t *testing.T) {
+	var empty interface{}
+	var pointer *int
+	one := 1
+	tests := []struct {
+		object   interface{}
+		expected string
+	}{
+		{map[int]string{2: "b", 10: "a", 1: "c"}, "{1: \"c\", 2: \"b\", 10: \"a\"}\n"},
+		{map[interface{}]int{"a": 1, 2: 2, 1: 1}, "{1: 1, 2: 2, \"a\": 1}\n"},
+		{&one, "&1\n"},
+		{pointer, "nil\n"},
+		{&pointer, "&nil\n"},
+		{&empty, "&nil\n"},
+		{[]interface{}{nil, 1}, "[nil, 1]\n"},
+	}
+	for _, test := range tests {
+		if actual := Pretty(test.object, 80); actual != test.expected {
+			t.Errorf("%#v: expected %q, got %q", test.object, test.expected, actual)
+		}
+	}
+}
@@ -6,13 +6,20 @@ package reflector
 
 import (
 	"fmt"
-	"math"
 	"reflect"
-	"strconv"
+	"sort"
+	"strings"
 
 	"github.com/dihedron/go-reflector/log"
 )
 
+// Observer is notified by Visit of each node in the object graph: path is
+// the path of the parent node, name the name of the node within its parent
+// and tags the struct tags of the field, if any. Composite nodes (pointers,
+// lists, structs, maps and interfaces) are notified twice, with start set
+// before and after their children are visited; returning false from the
+// first call skips the children (the second call is made anyway). The value
+// returned by all other calls is currently ignored.
 type Observer interface {
 	OnNil(path string, name string, tags string, typ reflect.Type) bool
 	OnValue(path string, name string, tags string, object reflect.Value) bool
@@ -26,6 +33,19 @@ type Observer interface {
 	OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool
 }
 
+// LeafObserver is an Observer that treats some composite values as leaves,
+// e.g. times and byte slices as configured in its Formatter (see
+// Formatter.IsLeaf): Visit notifies them through OnValue, without visiting
+// their contents.
+type LeafObserver interface {
+	Observer
+	IsLeaf(object reflect.Value) bool
+}
+
+// Visit walks the object graph depth-first, notifying the observer of each
+// node, with map entries in key order (see SortedKeys); path and name are
+// those of the object itself (e.g. "" and "o"), field is the
+// reflect.StructField it was read from, if any, and can be nil.
 func Visit(path string, name string, object interface{}, field interface{}, observer Observer) {
 
 	tag := ""
@@ -37,6 +57,10 @@ func Visit(path string, name string, object interface{}, field interface{}, obse
 
 	switch object := object.(type) {
 	case reflect.Value:
+		if leaves, ok := observer.(LeafObserver); ok && object.IsValid() && leaves.IsLeaf(object) {
+			observer.OnValue(path, name, tag, object)
+			return
+		}
 		switch object.Kind() {
 
 		case reflect.Invalid:
@@ -58,48 +82,53 @@ func Visit(path string, name string, object interface{}, field interface{}, obse
 			observer.OnUnsafePointer(path, name, tag, object)
 
 		case reflect.Slice, reflect.Array:
-			observer.OnList(path, name, true, tag, object)
-			for i := 0; i < object.Len(); i++ {
-				Visit(chain(path, name), fmt.Sprintf("[%d]", i), object.Index(i), nil, observer)
+			if observer.OnList(path, name, true, tag, object) {
+				for i := 0; i < object.Len(); i++ {
+					Visit(chain(path, name), fmt.Sprintf("[%d]", i), object.Index(i), nil, observer)
+				}
 			}
 			observer.OnList(path, name, false, tag, object)
 
 		case reflect.Struct:
-			observer.OnStruct(path, name, true, tag, object)
-			for i := 0; i < object.NumField(); i++ {
-				// if object.Type().Field(i).Name
-				Visit(chain(path, name), object.Type().Field(i).Name, object.Field(i), object.Type().Field(i), observer)
+			if observer.OnStruct(path, name, true, tag, object) {
+				for i := 0; i < object.NumField(); i++ {
+					// if object.Type().Field(i).Name
+					Visit(chain(path, name), object.Type().Field(i).Name, object.Field(i), object.Type().Field(i), observer)
+				}
 			}
 			observer.OnStruct(path, name, false, tag, object)
 
 		case reflect.Map:
-			observer.OnMap(path, name, true, tag, object)
-			for _, key := range object.MapKeys() {
-				Visit(path, format(key), object.MapIndex(key), nil, observer)
+			if observer.OnMap(path, name, true, tag, object) {
+				for _, key := range SortedKeys(object) {
+					Visit(chain(path, name), "["+DefaultFormatter.FormatValue(key)+"]", object.MapIndex(key), nil, observer)
+				}
 			}
 			observer.OnMap(path, name, false, tag, object)
 
 		case reflect.Ptr:
-			observer.OnPointer(path, name, true, tag, object)
-			if object.IsNil() {
-				observer.OnNil(path, ".value", tag, object.Type())
-			} else {
-				Visit(path, ".value", object.Elem(), nil, observer)
+			if observer.OnPointer(path, name, true, tag, object) {
+				if object.IsNil() {
+					observer.OnNil(chain(path, name), ".value", tag, object.Type())
+				} else {
+					Visit(chain(path, name), ".value", object.Elem(), nil, observer)
+				}
 			}
 			observer.OnPointer(path, name, false, tag, object)
 
 		case reflect.Interface:
-			observer.OnInterface(path, name, true, tag, object)
-			if object.IsNil() {
-				observer.OnNil(path, ".value", tag, object.Type())
-			} else {
-				Visit(path, ".value", object.Elem(), nil, observer)
+			if observer.OnInterface(path, name, true, tag, object) {
+				if object.IsNil() {
+					observer.OnNil(chain(path, name), ".value", tag, object.Type())
+				} else {
+					Visit(chain(path, name), ".value", object.Elem(), nil, observer)
+				}
 			}
 			observer.OnInterface(path, name, false, tag, object)
 
 		default:
 			// basic types, channels, funcs
-			fmt.Printf("***%s = %s\n", path, format(object))
+			fmt.Printf("***%s = %s\n", path, DefaultFormatter.FormatValue(object))
 		}
 	default:
 		log.Debugf("Starting visit of: %s%s (type: %T):\n", path, name, object)
@@ -110,58 +139,51 @@ func Visit(path string, name string, object interface{}, field interface{}, obse
 	}
 }
 
-func chain(path, name string) string {
-	if path == "" {
-		return name
-	} else if name == "" {
-		return path
-	}
-	return path + "." + name
+// SortedKeys returns the keys of a map in the order Visit visits its entries
+// in: keys of different types by type, then numbers and strings by value and
+// everything else by formatted value (see lessKey).
+func SortedKeys(object reflect.Value) []reflect.Value {
+	keys := object.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+	return keys
 }
 
-// format formats a value without inspecting its internal structure.
-func format(v reflect.Value) string {
-	switch v.Kind() {
-	case reflect.Invalid:
-		return "invalid"
+// lessKey orders map keys: keys of different types by type, then numbers and
+// strings by value and everything else by formatted value, so that keys of
+// mixed types (e.g. in a map[interface{}]string) are totally ordered.
+func lessKey(a reflect.Value, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if ta, tb := a.Type().String(), b.Type().String(); ta != tb {
+		return ta < tb
+	}
+	switch a.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(v.Int(), 10)
+		return a.Int() < b.Int()
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return strconv.FormatUint(v.Uint(), 10)
-	case reflect.Float32:
-		return strconv.FormatFloat(v.Float(), 'E', -1, 32)
-	case reflect.Float64:
-		return strconv.FormatFloat(v.Float(), 'E', -1, 64)
-	case reflect.Complex64:
-		result := strconv.FormatFloat(real(v.Complex()), 'E', -1, 32)
-		if imag(v.Complex()) > 0 {
-			result += "+"
-		} else {
-			result += "-"
-		}
-		result += strconv.FormatFloat(math.Abs(imag(v.Complex())), 'E', -1, 32)
-		result += "j"
-		return result
-	case reflect.Complex128:
-		result := "("
-		result += strconv.FormatFloat(real(v.Complex()), 'E', -1, 64)
-		result += ")"
-		if imag(v.Complex()) > 0 {
-			result += "+i("
-		} else {
-			result += "-i("
-		}
-		result += strconv.FormatFloat(math.Abs(imag(v.Complex())), 'E', -1, 64)
-		result += ")"
-		return result
-	case reflect.Bool:
-		return strconv.FormatBool(v.Bool())
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
 	case reflect.String:
-		return strconv.Quote(v.String())
-	case reflect.Chan, reflect.Func, reflect.Ptr, reflect.Slice, reflect.Map:
-		return v.Type().String() + " 0x" + strconv.FormatUint(uint64(v.Pointer()), 16)
-	default:
-		// reflect.Array, reflect.Struct, reflect.Interface
-		return v.Type().String() + " value"
+		return a.String() < b.String()
+	}
+	return DefaultFormatter.FormatValue(a) < DefaultFormatter.FormatValue(b)
+}
+
+// chain appends a name to a dotted path, so that the full path of a node
+// is chain(path, name) as passed to the Observer; indexes and map keys are
+// appended with no dot (e.g. "a.b[2]" or "a.m[\"key\"]") and the ".value"
+// of pointers and interfaces is transparent, i.e. it leaves the path as is.
+func chain(path, name string) string {
+	switch {
+	case name == "", name == ".value":
+		return path
+	case path == "", strings.HasPrefix(name, "["):
+		return path + name
 	}
+	return path + "." + name
 }
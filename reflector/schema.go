@@ -0,0 +1,483 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaURI is the URI of the JSON Schema dialect generated by
+// SchemaGenerator.
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaGenerator generates the JSON Schema (draft 2020-12) of Go types, as
+// they are marshalled by encoding/json: struct fields become properties,
+// named and renamed according to their json tags, and those that are not
+// omitempty are required; embedded structs have their fields promoted.
+// Pointers, slices and maps also accept null, which is what encoding/json
+// writes for nil ones.
+// Named struct types other than the root are defined once in $defs and
+// referenced from there, which also supports recursive types; the root is
+// referenced as "#". Further constraints come from the schema tag, as a
+// comma-separated list of keywords and values, e.g.
+//
+//	Level string `json:"level" schema:"enum=debug|info|warn,default=info"`
+//	Port  int    `json:"port" schema:"minimum=1,maximum=65535"`
+//
+// Supported keywords are title, description, default, examples (separated
+// by |), enum (separated by |), const, format, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength,
+// minItems, maxItems, minProperties, maxProperties, and the flags
+// uniqueItems, deprecated, readOnly and writeOnly. On slices and arrays, the
+// keywords that do not apply to arrays apply to their items.
+type SchemaGenerator struct {
+	tag        string
+	schemaTag  string
+	id         string
+	root       reflect.Type
+	names      map[reflect.Type]string
+	taken      map[string]bool
+	defs       map[string]interface{}
+	inProgress map[reflect.Type]bool
+}
+
+// NewSchemaGenerator returns a new SchemaGenerator.
+func NewSchemaGenerator() *SchemaGenerator {
+	return &SchemaGenerator{
+		tag:       "json",
+		schemaTag: "schema",
+	}
+}
+
+// SetTag sets the struct tag key used for property names and omitempty.
+func (g *SchemaGenerator) SetTag(tag string) {
+	g.tag = tag
+}
+
+// SetSchemaTag sets the struct tag key used for constraints.
+func (g *SchemaGenerator) SetSchemaTag(tag string) {
+	g.schemaTag = tag
+}
+
+// SetID sets the $id of the generated schemas.
+func (g *SchemaGenerator) SetID(id string) {
+	g.id = id
+}
+
+// JSONSchema returns the indented JSON Schema of a type.
+func JSONSchema(typ reflect.Type) ([]byte, error) {
+	schema, err := NewSchemaGenerator().Generate(typ)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// Generate returns the JSON Schema of a type, ready to be marshalled.
+func (g *SchemaGenerator) Generate(typ reflect.Type) (map[string]interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	g.root = typ
+	g.names = map[reflect.Type]string{}
+	g.taken = map[string]bool{}
+	g.defs = map[string]interface{}{}
+	g.inProgress = map[reflect.Type]bool{}
+
+	schema, err := g.valueSchema(typ, "")
+	if err != nil {
+		return nil, err
+	}
+	schema["$schema"] = SchemaURI
+	if g.id != "" {
+		schema["$id"] = g.id
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema, nil
+}
+
+// schemaOf returns the schema of a type, accepting null for pointers,
+// slices and maps; path is used in errors.
+func (g *SchemaGenerator) schemaOf(typ reflect.Type, path string) (map[string]interface{}, error) {
+	schema, err := g.valueSchema(typ, path)
+	if err != nil {
+		return nil, err
+	}
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		return schemaNullable(schema), nil
+	}
+	return schema, nil
+}
+
+// valueSchema returns the schema of the non-nil values of a type.
+func (g *SchemaGenerator) valueSchema(typ reflect.Type, path string) (map[string]interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case typ.Implements(jsonMarshalerType) || reflect.PtrTo(typ).Implements(jsonMarshalerType):
+		// marshalled by its own code, into anything
+		return map[string]interface{}{}, nil
+	case typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := g.schemaOf(typ.Elem(), path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]interface{}{"type": "array", "items": items}
+		if typ.Kind() == reflect.Array {
+			schema["minItems"] = typ.Len()
+			schema["maxItems"] = typ.Len()
+		}
+		return schema, nil
+	case reflect.Map:
+		switch typ.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !typ.Key().Implements(textMarshalerType) {
+				return nil, fmt.Errorf("reflector: unsupported map key type %s at %s", typ.Key(), displayPath(path))
+			}
+		}
+		values, err := g.schemaOf(typ.Elem(), path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if typ == g.root {
+			if g.inProgress[typ] {
+				return map[string]interface{}{"$ref": "#"}, nil
+			}
+			g.inProgress[typ] = true
+			return g.structSchema(typ, path)
+		}
+		if typ.Name() == "" {
+			return g.structSchema(typ, path)
+		}
+		name := g.name(typ)
+		if !g.inProgress[typ] {
+			g.inProgress[typ] = true
+			schema, err := g.structSchema(typ, path)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[name] = schema
+		}
+		// names of generic types contain slashes, as in a[example.com/b.C]
+		return map[string]interface{}{"$ref": "#" + FormatPointer("$defs", name)}, nil
+	}
+	return nil, fmt.Errorf("reflector: unsupported type %s at %s", typ, displayPath(path))
+}
+
+// name returns the unique name of a type in $defs: its name, qualified by
+// its package name if another type has the same name.
+func (g *SchemaGenerator) name(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+	name := typ.Name()
+	if g.taken[name] {
+		name = strings.Replace(typ.String(), ".", "_", -1)
+		for i := 2; g.taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", strings.Replace(typ.String(), ".", "_", -1), i)
+		}
+	}
+	g.names[typ] = name
+	g.taken[name] = true
+	return name
+}
+
+// structSchema returns the schema of a struct type.
+func (g *SchemaGenerator) structSchema(typ reflect.Type, path string) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+	if err := g.properties(typ, path, properties, &required, map[reflect.Type]bool{}); err != nil {
+		return nil, err
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// properties adds the properties of the fields of a struct type, including
+// those promoted from embedded structs; fields declared at a shallower depth
+// (i.e. added first) win.
+func (g *SchemaGenerator) properties(typ reflect.Type, path string, properties map[string]interface{}, required *[]string, embedded map[reflect.Type]bool) error {
+	embedded[typ] = true
+	var promoted []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(g.tag)
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if field.Anonymous && name == "" {
+			inner := field.Type
+			if inner.Kind() == reflect.Ptr {
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				promoted = append(promoted, field)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		schema, err := g.schemaOf(field.Type, chain(path, field.Name))
+		if err != nil {
+			return err
+		}
+		if hasOption(options[1:], "string") {
+			switch schemaType(schema) {
+			case "integer", "number", "boolean":
+				nullable := schemaAllowsNull(schema)
+				schema = map[string]interface{}{"type": "string"}
+				if nullable {
+					schema = schemaNullable(schema)
+				}
+			}
+		}
+		if err := g.constrain(schema, field, chain(path, field.Name)); err != nil {
+			return err
+		}
+		properties[name] = schema
+		if !hasOption(options[1:], "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+	for _, field := range promoted {
+		inner := field.Type
+		if inner.Kind() == reflect.Ptr {
+			inner = inner.Elem()
+		}
+		if embedded[inner] {
+			continue
+		}
+		if err := g.properties(inner, chain(path, field.Name), properties, required, embedded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasOption returns whether a tag option is present.
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// constrain applies the constraints in the schema tag of a field to its
+// schema.
+func (g *SchemaGenerator) constrain(schema map[string]interface{}, field reflect.StructField, path string) error {
+	tag := field.Tag.Get(g.schemaTag)
+	if tag == "" {
+		return nil
+	}
+	typ := field.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	items := schema
+	if schemaType(schema) == "array" {
+		items, _ = schema["items"].(map[string]interface{})
+	}
+
+	for _, constraint := range strings.Split(tag, ",") {
+		keyword := strings.TrimSpace(constraint)
+		value := ""
+		if i := strings.Index(keyword, "="); i >= 0 {
+			keyword, value = keyword[:i], keyword[i+1:]
+		}
+		var err error
+		switch keyword {
+		case "":
+		case "title", "description":
+			schema[keyword] = value
+		case "uniqueItems", "deprecated", "readOnly", "writeOnly":
+			schema[keyword] = value == "" || value == "true"
+		case "minItems", "maxItems", "minProperties", "maxProperties":
+			schema[keyword], err = strconv.Atoi(value)
+		case "default":
+			schema[keyword], err = schemaValue(typ, value)
+		case "examples":
+			var examples []interface{}
+			for _, example := range strings.Split(value, "|") {
+				var v interface{}
+				if v, err = schemaValue(typ, example); err != nil {
+					break
+				}
+				examples = append(examples, v)
+			}
+			schema[keyword] = examples
+		case "enum":
+			var enum []interface{}
+			for _, option := range strings.Split(value, "|") {
+				var v interface{}
+				if v, err = schemaValue(schemaItemType(typ), option); err != nil {
+					break
+				}
+				enum = append(enum, v)
+			}
+			if schemaAllowsNull(items) {
+				enum = append(enum, nil)
+			}
+			items[keyword] = enum
+		case "const":
+			var v interface{}
+			if v, err = schemaValue(schemaItemType(typ), value); err == nil {
+				if schemaAllowsNull(items) {
+					// a nil value is still allowed
+					items["enum"] = []interface{}{v, nil}
+				} else {
+					items[keyword] = v
+				}
+			}
+		case "format", "pattern":
+			items[keyword] = value
+		case "minLength", "maxLength":
+			items[keyword], err = strconv.Atoi(value)
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			items[keyword], err = schemaNumber(value)
+		default:
+			return fmt.Errorf("reflector: unknown schema keyword %q at %s", keyword, displayPath(path))
+		}
+		if err != nil {
+			return fmt.Errorf("reflector: invalid value for schema keyword %q at %s: %v", keyword, displayPath(path), err)
+		}
+	}
+	return nil
+}
+
+// schemaNullable returns a schema that also accepts null: null is added to
+// its type if it has one, otherwise it is wrapped in an anyOf (a schema with
+// no keywords already accepts anything).
+func schemaNullable(schema map[string]interface{}) map[string]interface{} {
+	if len(schema) == 0 || schemaAllowsNull(schema) {
+		return schema
+	}
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	return map[string]interface{}{
+		"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}},
+	}
+}
+
+// schemaAllowsNull returns whether a schema generated by schemaNullable
+// accepts null.
+func schemaAllowsNull(schema map[string]interface{}) bool {
+	if len(schema) == 0 {
+		return true
+	}
+	if types, ok := schema["type"].([]string); ok {
+		return types[len(types)-1] == "null"
+	}
+	_, ok := schema["anyOf"]
+	return ok
+}
+
+// schemaType returns the type of a schema other than null, or "" if it has
+// none.
+func schemaType(schema map[string]interface{}) string {
+	switch typ := schema["type"].(type) {
+	case string:
+		return typ
+	case []string:
+		return typ[0]
+	}
+	return ""
+}
+
+// schemaItemType returns the type of the items of a slice or array type, or
+// the type itself.
+func schemaItemType(typ reflect.Type) reflect.Type {
+	if (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && typ.Elem().Kind() != reflect.Uint8 {
+		typ = typ.Elem()
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	return typ
+}
+
+// schemaValue parses a value in a schema tag according to the type it
+// applies to.
+func schemaValue(typ reflect.Type, value string) (interface{}, error) {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	}
+	return value, nil
+}
+
+// schemaNumber parses a numeric bound, as an integer if possible.
+func schemaNumber(value string) (interface{}, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
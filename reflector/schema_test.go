@@ -0,0 +1,97 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type schemaNode struct {
+	Name     string            `json:"name" schema:"minLength=1"`
+	Level    *string           `json:"level" schema:"enum=debug|info"`
+	Count    *int              `json:"count,string"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Data     []byte            `json:"data"`
+	Parent   *schemaNode       `json:"parent"`
+	Children []schemaNode      `json:"children,omitempty"`
+	Fixed    [2]int            `json:"fixed"`
+}
+
+type schemaBox[T interface{}] struct {
+	Value T `json:"value"`
+}
+
+type schemaHolder struct {
+	Box schemaBox[schemaNode] `json:"box"`
+}
+
+func TestSchemaTypes(t *testing.T) {
+	schema, err := NewSchemaGenerator().Generate(reflect.TypeOf(schemaNode{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	tests := []struct {
+		property string
+		expected map[string]interface{}
+	}{
+		{"name", map[string]interface{}{"type": "string", "minLength": 1}},
+		{"level", map[string]interface{}{"type": []string{"string", "null"}, "enum": []interface{}{"debug", "info", nil}}},
+		{"count", map[string]interface{}{"type": []string{"string", "null"}}},
+		{"tags", map[string]interface{}{"type": []string{"array", "null"}, "items": map[string]interface{}{"type": "string"}}},
+		{"labels", map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": map[string]interface{}{"type": "string"}}},
+		{"data", map[string]interface{}{"type": []string{"string", "null"}, "contentEncoding": "base64"}},
+		{"parent", map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"$ref": "#"}, map[string]interface{}{"type": "null"}}}},
+		{"fixed", map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}, "minItems": 2, "maxItems": 2}},
+	}
+	for _, test := range tests {
+		if actual := properties[test.property]; !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.property, test.expected, actual)
+		}
+	}
+	if required := schema["required"].([]string); len(required) != 8 {
+		t.Errorf("unexpected required properties %v", required)
+	}
+}
+
+func TestSchemaGenericRef(t *testing.T) {
+	schema, err := NewSchemaGenerator().Generate(reflect.TypeOf(schemaHolder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := reflect.TypeOf(schemaBox[schemaNode]{}).Name()
+	if _, ok := schema["$defs"].(map[string]interface{})[name]; !ok {
+		t.Fatalf("expected a definition for %s", name)
+	}
+	ref := schema["properties"].(map[string]interface{})["box"].(map[string]interface{})["$ref"]
+	if expected := "#/$defs/" + strings.Replace(name, "/", "~1", -1); ref != expected {
+		t.Errorf("expected %s, got %v", expected, ref)
+	}
+}
+
+func TestSchemaAcceptsMarshalledNils(t *testing.T) {
+	schema, err := NewSchemaGenerator().Generate(reflect.TypeOf(schemaNode{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	data, err := json.Marshal(schemaNode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range document {
+		if value == nil && !schemaAllowsNull(properties[name].(map[string]interface{})) {
+			t.Errorf("%s is null in %s, but its schema does not accept null", name, data)
+		}
+	}
+}
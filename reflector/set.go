@@ -0,0 +1,209 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Setter assigns values by path within object graphs (see Get for the path
+// syntax), allocating nil pointers and maps along the way, inserting map
+// entries and converting values to the type of their target where it is
+// safe to do so: between numeric types if the value fits exactly, from
+// strings to booleans, numbers, durations and (RFC 3339) times, from a value
+// to a pointer to it, and between types with the same underlying kind.
+type Setter struct {
+	// Grow makes slices grow when they are indexed past their end, rather
+	// than failing: by one element when indexed at their length, and up to
+	// MaxLength elements otherwise.
+	Grow bool
+	// MaxLength is the length slices can grow to when indexed past their
+	// length; slices longer than that can only grow by one element at a time.
+	MaxLength int
+}
+
+// NewSetter returns a Setter with the default settings.
+func NewSetter() *Setter {
+	return &Setter{}
+}
+
+// DefaultSetter is the Setter used by Set.
+var DefaultSetter = NewSetter()
+
+// Set assigns a value at the given path within the object pointed to by
+// object, using DefaultSetter.
+func Set(object interface{}, path string, value interface{}) error {
+	return DefaultSetter.Set(object, path, value)
+}
+
+// Set assigns a value at the given path within the object pointed to by
+// object; unexported fields can be assigned too.
+func (s *Setter) Set(object interface{}, path string, value interface{}) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(object)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("reflector: cannot set %q in non-pointer %T", path, object)
+	}
+	return s.set(path, target.Elem(), segments, value)
+}
+
+// set assigns a value at the path made of the remaining segments within a
+// settable target.
+func (s *Setter) set(path string, target reflect.Value, segments []pathSegment, value interface{}) error {
+	if len(segments) == 0 {
+		converted, err := Convert(value, target.Type())
+		if err != nil {
+			return fmt.Errorf("reflector: cannot set %s: %v", displayPath(path), err)
+		}
+		target.Set(converted)
+		return nil
+	}
+	segment := segments[0]
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return s.set(path, target.Elem(), segments, value)
+
+	case reflect.Interface:
+		if target.IsNil() {
+			return segment.fail(path, "nil %s", target.Type())
+		}
+		// the value in an interface cannot be modified in place: work on a
+		// copy, then store it back
+		elem := reflect.New(target.Elem().Type()).Elem()
+		elem.Set(target.Elem())
+		if err := s.set(path, elem, segments, value); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		child, err := segment.child(path, target)
+		if err != nil {
+			return err
+		}
+		return s.set(path, exposed(child), segments[1:], value)
+
+	case reflect.Slice:
+		if !segment.bracket || segment.quoted {
+			return segment.fail(path, "cannot get %s of %s", segment.name, target.Type())
+		}
+		index, err := strconv.Atoi(segment.name)
+		if err != nil || index < 0 {
+			return segment.fail(path, "invalid index %s", segment.name)
+		}
+		if index >= target.Len() {
+			if !s.Grow {
+				return segment.fail(path, "index %d out of range [0:%d]", index, target.Len())
+			}
+			if index > target.Len() && index >= s.MaxLength {
+				return segment.fail(path, "index %d out of range [0:%d], cannot grow past length %d", index, target.Len(), max(target.Len()+1, s.MaxLength))
+			}
+			target.Set(reflect.AppendSlice(target, reflect.MakeSlice(target.Type(), index+1-target.Len(), index+1-target.Len())))
+		}
+		return s.set(path, target.Index(index), segments[1:], value)
+
+	case reflect.Array:
+		child, err := segment.child(path, target)
+		if err != nil {
+			return err
+		}
+		return s.set(path, child, segments[1:], value)
+
+	case reflect.Map:
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+		key, err := segment.key(target)
+		if err != nil {
+			return segment.fail(path, "%v", err)
+		}
+		// map elements cannot be modified in place: work on a copy, then
+		// store it back
+		elem := reflect.New(target.Type().Elem()).Elem()
+		if existing := target.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := s.set(path, elem, segments[1:], value); err != nil {
+			return err
+		}
+		target.SetMapIndex(key, elem)
+		return nil
+	}
+	return segment.fail(path, "cannot get %s of %s", segment.text, target.Type())
+}
+
+// Convert converts a value to the given type where it is safe to do so (see
+// Setter).
+func Convert(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, fmt.Errorf("cannot use nil as %s", typ)
+	case v.Type().AssignableTo(typ):
+		return v, nil
+	case typ.Kind() == reflect.Ptr && v.Kind() != reflect.Ptr:
+		elem, err := Convert(value, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		pointer := reflect.New(typ.Elem())
+		pointer.Elem().Set(elem)
+		return pointer, nil
+	case v.Kind() == reflect.String && typ.Kind() != reflect.String:
+		return convertString(v.String(), typ)
+	case isNumber(v.Kind()) && isNumber(typ.Kind()):
+		return convertNumber(v, typ)
+	case v.Kind() == typ.Kind() && v.Type().ConvertibleTo(typ):
+		return v.Convert(typ), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", v.Type(), typ)
+}
+
+// convertString parses a string into a value of the given type.
+func convertString(s string, typ reflect.Type) (reflect.Value, error) {
+	switch typ {
+	case timeType:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(t), nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	}
+	if typ.Kind() == reflect.Complex64 || typ.Kind() == reflect.Complex128 {
+		c, err := strconv.ParseComplex(s, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value := reflect.New(typ).Elem()
+		value.SetComplex(c)
+		return value, nil
+	}
+	value, err := parseLiteral(s, typ)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot convert %q to %s", s, typ)
+	}
+	return value, nil
+}
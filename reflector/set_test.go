@@ -0,0 +1,162 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	type level string
+	tests := []struct {
+		value    interface{}
+		typ      reflect.Type
+		expected interface{}
+	}{
+		{int64(100), reflect.TypeOf(int8(0)), int8(100)},
+		{2.0, reflect.TypeOf(0), 2},
+		{3, reflect.TypeOf(0.0), 3.0},
+		{uint8(7), reflect.TypeOf(int16(0)), int16(7)},
+		{"42", reflect.TypeOf(0), 42},
+		{"true", reflect.TypeOf(false), true},
+		{"1.5", reflect.TypeOf(float32(0)), float32(1.5)},
+		{0.25, reflect.TypeOf(float32(0)), float32(0.25)},
+		{"1h", reflect.TypeOf(time.Duration(0)), time.Hour},
+		{"2020-01-02T03:04:05Z", reflect.TypeOf(time.Time{}), time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"info", reflect.TypeOf(level("")), level("info")},
+		{nil, reflect.TypeOf([]int{}), []int(nil)},
+	}
+	for _, test := range tests {
+		actual, err := Convert(test.value, test.typ)
+		if err != nil {
+			t.Errorf("%v to %s: unexpected error %v", test.value, test.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual.Interface(), test.expected) {
+			t.Errorf("%v to %s: expected %v, got %v", test.value, test.typ, test.expected, actual.Interface())
+		}
+	}
+
+	pointer, err := Convert(5, reflect.TypeOf((*int)(nil)))
+	if err != nil || *(pointer.Interface().(*int)) != 5 {
+		t.Errorf("5 to *int: %v, %v", pointer, err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		typ   reflect.Type
+	}{
+		{300, reflect.TypeOf(int8(0))},
+		{-1, reflect.TypeOf(uint(0))},
+		{1.9, reflect.TypeOf(0)},
+		{1e300, reflect.TypeOf(float32(0))},
+		{0.1, reflect.TypeOf(float32(0))},
+		{1<<24 + 1, reflect.TypeOf(float32(0))},
+		{uint64(1 << 63), reflect.TypeOf(int64(0))},
+		{"x", reflect.TypeOf(0)},
+		{"yes", reflect.TypeOf(false)},
+		{nil, reflect.TypeOf(0)},
+		{[]int{1}, reflect.TypeOf("")},
+	}
+	for _, test := range tests {
+		if actual, err := Convert(test.value, test.typ); err == nil {
+			t.Errorf("%v to %s: expected an error, got %v", test.value, test.typ, actual)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	type inner struct {
+		Values []int
+	}
+	type outer struct {
+		Name   string
+		Count  int8
+		Inner  *inner
+		Tags   map[string]int
+		Nested map[string]*inner
+		Any    interface{}
+		hidden string
+	}
+	object := &outer{Any: map[string]interface{}{"a": 1}}
+	setter := &Setter{Grow: true}
+	for path, value := range map[string]interface{}{
+		"Name":               "x",
+		"Count":              "12",
+		"Inner.Values[0]":    1,
+		`Tags["k"]`:          2.0,
+		`Nested["n"].Values`: []int{3},
+		`Any["a"]`:           4,
+		"hidden":             "h",
+	} {
+		if err := setter.Set(object, path, value); err != nil {
+			t.Errorf("%s: unexpected error %v", path, err)
+		}
+	}
+	expected := &outer{
+		Name:   "x",
+		Count:  12,
+		Inner:  &inner{Values: []int{1}},
+		Tags:   map[string]int{"k": 2},
+		Nested: map[string]*inner{"n": {Values: []int{3}}},
+		Any:    map[string]interface{}{"a": 4},
+		hidden: "h",
+	}
+	if !reflect.DeepEqual(object, expected) {
+		t.Errorf("expected %+v, got %+v", expected, object)
+	}
+
+	for path, value := range map[string]interface{}{"Count": 300, "Missing": 1, "Name.X": 1, "Inner.Values[x]": 1} {
+		if err := setter.Set(object, path, value); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+	if err := Set(*object, "Name", "y"); err == nil {
+		t.Errorf("expected an error setting a non-pointer")
+	}
+	narrow := &struct{ X float32 }{}
+	if err := Set(narrow, "X", 0.1); err == nil {
+		t.Errorf("expected an error rounding 0.1 to float32, got %v", narrow.X)
+	}
+}
+
+func TestSetGrow(t *testing.T) {
+	type list struct {
+		Items []int
+	}
+	object := &list{}
+	if err := Set(object, "Items[0]", 1); err == nil {
+		t.Errorf("expected an error growing without Grow")
+	}
+
+	setter := &Setter{Grow: true}
+	if err := setter.Set(object, "Items[0]", 1); err != nil {
+		t.Errorf("unexpected error appending: %v", err)
+	}
+	if err := setter.Set(object, "Items[2000000000]", 1); err == nil {
+		t.Errorf("expected an error growing past the maximum length")
+	}
+	if err := setter.Set(object, "Items[3]", 1); err == nil {
+		t.Errorf("expected an error growing by more than one element")
+	}
+
+	setter.MaxLength = 5
+	if err := setter.Set(object, "Items[4]", 5); err != nil {
+		t.Errorf("unexpected error growing within the maximum length: %v", err)
+	}
+	if err := setter.Set(object, "Items[5]", 6); err != nil {
+		t.Errorf("unexpected error appending past the maximum length: %v", err)
+	}
+	if err := setter.Set(object, "Items[7]", 8); err == nil {
+		t.Errorf("expected an error growing past the maximum length")
+	}
+	if expected := []int{1, 0, 0, 0, 5, 6}; !reflect.DeepEqual(object.Items, expected) {
+		t.Errorf("expected %v, got %v", expected, object.Items)
+	}
+}
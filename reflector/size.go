@@ -0,0 +1,298 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// SizeObserver is an Observer that computes the approximate amount of memory
+// retained by an object graph: the size of the root value itself, plus that
+// of everything it references, i.e. pointed values, slice and channel
+// buffers, map buckets, memory referenced by map keys and string bytes.
+// Memory reachable through more than one pointer (or slice, map or channel)
+// is only counted once. Sizes are estimates: allocator size classes and
+// runtime headers are not accounted for, and map sizes are derived from
+// their length.
+type SizeObserver struct {
+	seen    map[sizeKey]bool
+	frames  []*sizeFrame
+	entries []SizeEntry
+}
+
+// SizeEntry is the size of a subtree of the visited object graph.
+type SizeEntry struct {
+	// Path is the path of the subtree root.
+	Path string
+	// Type is the type of the subtree root.
+	Type string
+	// Size is the size of the subtree root value, plus the memory it retains
+	// and that was not already accounted for elsewhere.
+	Size uint64
+}
+
+// sizeKey identifies a memory block by its address and type.
+type sizeKey struct {
+	address uintptr
+	typ     reflect.Type
+}
+
+// sizeFrame is a composite node whose children are being visited; retained
+// is the memory retained by the node, over and above its own size.
+type sizeFrame struct {
+	path     string
+	typ      reflect.Type
+	retained uint64
+}
+
+// NewSizeObserver returns a new SizeObserver.
+func NewSizeObserver() *SizeObserver {
+	return &SizeObserver{
+		seen: map[sizeKey]bool{},
+	}
+}
+
+// DeepSize returns the approximate amount of memory retained by an object.
+func DeepSize(object interface{}) uint64 {
+	observer := NewSizeObserver()
+	Visit("", "", object, nil, observer)
+	return observer.Total()
+}
+
+// Reset discards the results of previous visits, so the observer can be
+// reused.
+func (o *SizeObserver) Reset() {
+	o.seen = map[sizeKey]bool{}
+	o.frames = nil
+	o.entries = nil
+}
+
+// Total returns the memory retained by the visited object graph.
+func (o *SizeObserver) Total() uint64 {
+	if len(o.entries) == 0 {
+		return 0
+	}
+	// the root is the last node to be completed
+	return o.entries[len(o.entries)-1].Size
+}
+
+// Top returns the n heaviest subtrees, sorted by decreasing size (and by path
+// for equal sizes); if n is not positive, all subtrees are returned.
+func (o *SizeObserver) Top(n int) []SizeEntry {
+	entries := make([]SizeEntry, len(o.entries))
+	copy(entries, o.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Size != entries[j].Size {
+			return entries[i].Size > entries[j].Size
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Report writes the n heaviest subtrees to the stream, one per line.
+func (o *SizeObserver) Report(writer io.Writer, n int) error {
+	for _, entry := range o.Top(n) {
+		path := entry.Path
+		if path == "" {
+			path = "<root>"
+		}
+		if _, err := fmt.Fprintf(writer, "%12d  %s (%s)\n", entry.Size, path, entry.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *SizeObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	return true
+}
+
+func (o *SizeObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	if object.Kind() == reflect.String {
+		o.leaf(path, name, object, uint64(object.Len()))
+	} else if object.IsValid() {
+		o.leaf(path, name, object, 0)
+	}
+	return true
+}
+
+func (o *SizeObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.close()
+		return true
+	}
+	if object.IsNil() {
+		o.open(path, name, object, 0)
+		return true
+	}
+	// the pointed value lives in a block of its own
+	return o.open(path, name, object, o.block(object.Pointer(), object.Type().Elem(), uint64(object.Type().Elem().Size())))
+}
+
+func (o *SizeObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.close()
+		return true
+	}
+	if object.Kind() == reflect.Array || object.Cap() == 0 {
+		// arrays are stored inline
+		o.open(path, name, object, 0)
+		return true
+	}
+	return o.open(path, name, object, o.block(object.Pointer(), object.Type(), uint64(object.Cap())*uint64(object.Type().Elem().Size())))
+}
+
+func (o *SizeObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(path, name, object, 0)
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *SizeObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.close()
+		return true
+	}
+	if object.IsNil() {
+		o.open(path, name, object, 0)
+		return true
+	}
+	retained := o.block(object.Pointer(), object.Type(), mapSize(object))
+	if retained != sizeSeen {
+		retained += o.keys(object)
+	}
+	return o.open(path, name, object, retained)
+}
+
+// keys returns the memory referenced by the keys of a map (e.g. the bytes of
+// string keys), which Visit does not visit; the keys themselves are stored
+// in the map groups, and are already part of mapSize.
+func (o *SizeObserver) keys(object reflect.Value) uint64 {
+	var retained uint64
+	for _, key := range object.MapKeys() {
+		observer := &SizeObserver{seen: o.seen}
+		Visit("", "", key, nil, observer)
+		retained += observer.Total() - uint64(key.Type().Size())
+	}
+	return retained
+}
+
+func (o *SizeObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if !start {
+		o.close()
+		return true
+	}
+	var boxed uint64
+	if !object.IsNil() {
+		switch object.Elem().Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// pointer-shaped values are stored in the interface itself
+		default:
+			boxed = uint64(object.Elem().Type().Size())
+		}
+	}
+	o.open(path, name, object, boxed)
+	return true
+}
+
+func (o *SizeObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	if object.IsNil() {
+		o.leaf(path, name, object, 0)
+	} else {
+		o.leaf(path, name, object, o.block(object.Pointer(), object.Type(), channelHeader+uint64(object.Cap())*uint64(object.Type().Elem().Size())))
+	}
+	return true
+}
+
+func (o *SizeObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	o.leaf(path, name, object, 0)
+	return true
+}
+
+func (o *SizeObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	o.leaf(path, name, object, 0)
+	return true
+}
+
+// open starts a composite node retaining the given amount of memory of its
+// own; it returns false if the node has already been accounted for (i.e. if
+// its memory block has already been seen), in which case its children are
+// not visited.
+func (o *SizeObserver) open(path string, name string, object reflect.Value, retained uint64) bool {
+	if retained == sizeSeen {
+		o.frames = append(o.frames, &sizeFrame{path: chain(path, name), typ: object.Type()})
+		return false
+	}
+	o.frames = append(o.frames, &sizeFrame{path: chain(path, name), typ: object.Type(), retained: retained})
+	return true
+}
+
+// close completes the innermost composite node, recording its size and
+// adding the memory it retains to its parent's.
+func (o *SizeObserver) close() {
+	if len(o.frames) == 0 {
+		return
+	}
+	frame := o.frames[len(o.frames)-1]
+	o.frames = o.frames[:len(o.frames)-1]
+	o.record(frame.path, frame.typ, frame.retained)
+}
+
+// leaf records a node with no children.
+func (o *SizeObserver) leaf(path string, name string, object reflect.Value, retained uint64) {
+	if retained == sizeSeen {
+		retained = 0
+	}
+	o.record(chain(path, name), object.Type(), retained)
+}
+
+// record adds an entry for a node; the size of the node itself is only
+// included in its entry, since it is already part of its parent's size (as
+// a field, an element or a pointed value).
+func (o *SizeObserver) record(path string, typ reflect.Type, retained uint64) {
+	size := uint64(typ.Size()) + retained
+	o.entries = append(o.entries, SizeEntry{Path: path, Type: typ.String(), Size: size})
+	if len(o.frames) > 0 {
+		o.frames[len(o.frames)-1].retained += retained
+	}
+}
+
+// sizeSeen is returned by block for memory blocks that have already been
+// accounted for.
+const sizeSeen = ^uint64(0)
+
+// block returns the given size the first time a memory block is seen, and
+// sizeSeen afterwards.
+func (o *SizeObserver) block(address uintptr, typ reflect.Type, size uint64) uint64 {
+	key := sizeKey{address: address, typ: typ}
+	if o.seen[key] {
+		return sizeSeen
+	}
+	o.seen[key] = true
+	return size
+}
+
+// channelHeader is the approximate size of the runtime channel structure.
+const channelHeader = 96
+
+// mapSize estimates the memory used by a map: the runtime header plus
+// groups of eight slots, each holding a key and a value plus a control byte,
+// filled up to seven eighths.
+func mapSize(object reflect.Value) uint64 {
+	const header, slots = 48, 8
+	slot := uint64(object.Type().Key().Size() + object.Type().Elem().Size())
+	groups := (uint64(object.Len())*8/7 + slots - 1) / slots
+	return header + groups*(slots+slots*slot)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestDeepSizeMapKeys(t *testing.T) {
+	small := DeepSize(map[string]int{"a": 1})
+	if empty := DeepSize(map[string]int{"": 1}); small != empty+1 {
+		t.Errorf("expected the key bytes to be counted: %d vs %d", small, empty)
+	}
+	key := strings.Repeat("x", 1<<20)
+	if size := DeepSize(map[string]int{key: 1}); size < 1<<20 {
+		t.Errorf("expected at least %d bytes for a 1 MiB key, got %d", 1<<20, size)
+	}
+	p := new(int)
+	if shared, single := DeepSize(map[*int]*int{p: p}), DeepSize(map[*int]*int{p: nil}); shared != single {
+		t.Errorf("value pointed by both key and value counted twice: %d vs %d", shared, single)
+	}
+}
+
+func TestDeepSizeShared(t *testing.T) {
+	s := make([]byte, 1000)
+	type pair struct{ A, B []byte }
+	if size := DeepSize(pair{A: s, B: s}); size != uint64(unsafe.Sizeof(pair{})+1000) {
+		t.Errorf("expected shared slices to be counted once, got %d", size)
+	}
+}
+
+func TestSizeTop(t *testing.T) {
+	type record struct {
+		Name  string
+		Data  []byte
+		Count int
+	}
+	object := record{Name: "abc", Data: make([]byte, 100), Count: 1}
+	observer := NewSizeObserver()
+	Visit("", "", object, nil, observer)
+
+	expected := []SizeEntry{
+		{Path: "", Type: "reflector.record", Size: uint64(unsafe.Sizeof(object)) + 3 + 100},
+		{Path: "Data", Type: "[]uint8", Size: uint64(unsafe.Sizeof(object.Data)) + 100},
+		{Path: "Name", Type: "string", Size: uint64(unsafe.Sizeof(object.Name)) + 3},
+	}
+	top := observer.Top(3)
+	if fmt.Sprint(top) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, top)
+	}
+	if total := observer.Total(); total != expected[0].Size {
+		t.Errorf("expected a total of %d, got %d", expected[0].Size, total)
+	}
+	all := observer.Top(0)
+	if len(all) != 104 {
+		t.Fatalf("expected 104 entries, got %d", len(all))
+	}
+	if last := all[len(all)-1]; last.Path != "Data[9]" || last.Size != 1 {
+		t.Errorf("expected equal sizes to be sorted by path, got %v last", last)
+	}
+
+	var buffer strings.Builder
+	if err := observer.Report(&buffer, 2); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	report := fmt.Sprintf("%12d  <root> (reflector.record)\n%12d  Data ([]uint8)\n", expected[0].Size, expected[1].Size)
+	if buffer.String() != report {
+		t.Errorf("expected\n%s\ngot\n%s", report, buffer.String())
+	}
+
+	observer.Reset()
+	if observer.Total() != 0 || len(observer.Top(0)) != 0 {
+		t.Errorf("expected no entries after Reset")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Statistics summarises the contents of an object graph.
+type Statistics struct {
+	// Nodes is the total number of values visited.
+	Nodes int
+	// Kinds is the number of values of each kind.
+	Kinds map[reflect.Kind]int
+	// Types is the number of values of each concrete type.
+	Types map[string]int
+	// Nils is the number of nil pointers and interfaces.
+	Nils int
+	// MaxDepth is the depth of the most deeply nested value (the root is at
+	// depth 0), and MaxDepthPath its path.
+	MaxDepth     int
+	MaxDepthPath string
+	// LongestList is the length of the longest slice or array, and
+	// LongestListPath its path.
+	LongestList     int
+	LongestListPath string
+	// LargestMap is the number of entries in the largest map, and
+	// LargestMapPath its path.
+	LargestMap     int
+	LargestMapPath string
+	// StringBytes is the total length in bytes of all strings.
+	StringBytes int
+	// Unexported is the number of unexported struct fields.
+	Unexported int
+}
+
+// String returns a human-readable report of the statistics.
+func (s Statistics) String() string {
+	var buffer strings.Builder
+	fmt.Fprintf(&buffer, "nodes:         %d\n", s.Nodes)
+	fmt.Fprintf(&buffer, "nils:          %d\n", s.Nils)
+	fmt.Fprintf(&buffer, "max depth:     %d (%s)\n", s.MaxDepth, displayPath(s.MaxDepthPath))
+	fmt.Fprintf(&buffer, "longest list:  %d (%s)\n", s.LongestList, displayPath(s.LongestListPath))
+	fmt.Fprintf(&buffer, "largest map:   %d (%s)\n", s.LargestMap, displayPath(s.LargestMapPath))
+	fmt.Fprintf(&buffer, "string bytes:  %d\n", s.StringBytes)
+	fmt.Fprintf(&buffer, "unexported:    %d\n", s.Unexported)
+
+	kinds := make([]reflect.Kind, 0, len(s.Kinds))
+	for kind := range s.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if s.Kinds[kinds[i]] != s.Kinds[kinds[j]] {
+			return s.Kinds[kinds[i]] > s.Kinds[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	buffer.WriteString("kinds:\n")
+	for _, kind := range kinds {
+		fmt.Fprintf(&buffer, "  %8d  %s\n", s.Kinds[kind], kind)
+	}
+
+	types := make([]string, 0, len(s.Types))
+	for typ := range s.Types {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if s.Types[types[i]] != s.Types[types[j]] {
+			return s.Types[types[i]] > s.Types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	buffer.WriteString("types:\n")
+	for _, typ := range types {
+		fmt.Fprintf(&buffer, "  %8d  %s\n", s.Types[typ], typ)
+	}
+	return buffer.String()
+}
+
+// displayPath returns the path for display, where the root has no name.
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// StatisticsObserver is an Observer that gathers Statistics about the
+// visited object graph.
+type StatisticsObserver struct {
+	statistics Statistics
+	kinds      []reflect.Kind
+}
+
+// NewStatisticsObserver returns a new StatisticsObserver.
+func NewStatisticsObserver() *StatisticsObserver {
+	o := &StatisticsObserver{}
+	o.Reset()
+	return o
+}
+
+// Statistics returns the statistics gathered so far.
+func (o *StatisticsObserver) Statistics() Statistics {
+	return o.statistics
+}
+
+// Reset discards the statistics gathered so far.
+func (o *StatisticsObserver) Reset() {
+	o.statistics = Statistics{
+		Kinds: map[reflect.Kind]int{},
+		Types: map[string]int{},
+	}
+	o.kinds = nil
+}
+
+func (o *StatisticsObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	o.statistics.Nils++
+	return true
+}
+
+func (o *StatisticsObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	if object.Kind() == reflect.String {
+		o.statistics.StringBytes += object.Len()
+	}
+	o.node(path, name, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(path, name, start, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && object.Len() > o.statistics.LongestList {
+		o.statistics.LongestList = object.Len()
+		o.statistics.LongestListPath = chain(path, name)
+	}
+	o.composite(path, name, start, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(path, name, start, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && object.Len() > o.statistics.LargestMap {
+		o.statistics.LargestMap = object.Len()
+		o.statistics.LargestMapPath = chain(path, name)
+	}
+	o.composite(path, name, start, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(path, name, start, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	o.node(path, name, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	o.node(path, name, object)
+	return true
+}
+
+func (o *StatisticsObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	o.node(path, name, object)
+	return true
+}
+
+// composite accounts for a composite node when it starts, and keeps track of
+// the nesting.
+func (o *StatisticsObserver) composite(path string, name string, start bool, object reflect.Value) {
+	if start {
+		o.node(path, name, object)
+		o.kinds = append(o.kinds, object.Kind())
+	} else if len(o.kinds) > 0 {
+		o.kinds = o.kinds[:len(o.kinds)-1]
+	}
+}
+
+// node accounts for a value.
+func (o *StatisticsObserver) node(path string, name string, object reflect.Value) {
+	o.statistics.Nodes++
+	o.statistics.Kinds[object.Kind()]++
+	if object.IsValid() {
+		o.statistics.Types[object.Type().String()]++
+	}
+	if depth := len(o.kinds); depth > o.statistics.MaxDepth {
+		o.statistics.MaxDepth = depth
+		o.statistics.MaxDepthPath = chain(path, name)
+	}
+	if len(o.kinds) > 0 && o.kinds[len(o.kinds)-1] == reflect.Struct && !token.IsExported(name) {
+		o.statistics.Unexported++
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type statisticsInner struct {
+	Tags []string
+	n    int
+}
+
+type statisticsOuter struct {
+	Name  string
+	In    *statisticsInner
+	Nil   *statisticsInner
+	Any   interface{}
+	Map   map[string]int
+	Items [3]int
+}
+
+func TestStatistics(t *testing.T) {
+	object := statisticsOuter{
+		Name: "abc",
+		In:   &statisticsInner{Tags: []string{"x", "yz"}, n: 1},
+		Map:  map[string]int{"a": 1, "b": 2},
+	}
+	observer := NewStatisticsObserver()
+	Visit("", "", object, nil, observer)
+	expected := Statistics{
+		Nodes: 17,
+		Kinds: map[reflect.Kind]int{
+			reflect.Int:       6,
+			reflect.String:    3,
+			reflect.Ptr:       2,
+			reflect.Struct:    2,
+			reflect.Array:     1,
+			reflect.Interface: 1,
+			reflect.Map:       1,
+			reflect.Slice:     1,
+		},
+		Types: map[string]int{
+			"int":                        6,
+			"string":                     3,
+			"*reflector.statisticsInner": 2,
+			"[3]int":                     1,
+			"[]string":                   1,
+			"interface {}":               1,
+			"reflector.statisticsInner":  1,
+			"reflector.statisticsOuter":  1,
+			"map[string]int":             1,
+		},
+		Nils:            2,
+		MaxDepth:        4,
+		MaxDepthPath:    "In.Tags[0]",
+		LongestList:     3,
+		LongestListPath: "Items",
+		LargestMap:      2,
+		LargestMapPath:  "Map",
+		StringBytes:     6,
+		Unexported:      1,
+	}
+	if actual := observer.Statistics(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	report := observer.Statistics().String()
+	for _, line := range []string{"nodes:         17\n", "max depth:     4 (In.Tags[0])\n", "kinds:\n         6  int\n         3  string\n"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("expected %q in report:\n%s", line, report)
+		}
+	}
+
+	observer.Reset()
+	Visit("", "", "abc", nil, observer)
+	if actual := observer.Statistics(); actual.Nodes != 1 || actual.StringBytes != 3 || actual.MaxDepthPath != "" {
+		t.Errorf("unexpected statistics after reset: %+v", actual)
+	}
+	if report := observer.Statistics().String(); !strings.Contains(report, "max depth:     0 (<root>)") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
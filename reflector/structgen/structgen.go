@@ -0,0 +1,467 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package structgen infers Go struct types from sample JSON or YAML
+// documents and generates their source.
+package structgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Generator infers Go type declarations from sample documents, e.g. JSON or
+// YAML, and generates their gofmt-formatted source; this is the reverse of
+// dumping an object. All samples are unified into a single type: fields that
+// are missing from some samples or null become pointers (and are tagged
+// omitempty), all the elements of an array are unified into a single element
+// type, numbers are floats if any sample has a fractional part, and strings
+// are times if all samples are RFC 3339 timestamps. Values that cannot be
+// unified are interface{}. Nested objects get their own named types, named
+// after the field that holds them.
+type Generator struct {
+	name     string
+	pkg      string
+	tags     []string
+	root     *sampleShape
+	declared map[string]bool
+	buffer   bytes.Buffer
+	imports  map[string]bool
+}
+
+// NewGenerator returns a new Generator for a root type with the given name,
+// generating json tags.
+func NewGenerator(name string) *Generator {
+	return &Generator{
+		name: name,
+		tags: []string{"json"},
+		root: &sampleShape{},
+	}
+}
+
+// SetPackage sets the package name of the generated source; if empty (the
+// default), only the type declarations are generated.
+func (g *Generator) SetPackage(pkg string) {
+	g.pkg = pkg
+}
+
+// SetTags sets the struct tag keys generated for each field (e.g. "json"
+// and "yaml").
+func (g *Generator) SetTags(tags ...string) {
+	g.tags = tags
+}
+
+// AddSample adds an already decoded sample document, made of maps (with
+// string keys), slices and basic values, to the samples the types are
+// inferred from. The keys of Go maps are sorted, since their order is lost.
+func (g *Generator) AddSample(sample interface{}) error {
+	return g.root.merge(sample)
+}
+
+// AddJSON adds the sample JSON documents in the data (one or more, as in
+// newline-delimited JSON), preserving the order of object keys.
+func (g *Generator) AddJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	for {
+		sample, err := decodeJSONSample(decoder)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("structgen: invalid JSON sample: %v", err)
+		}
+		if err := g.AddSample(sample); err != nil {
+			return err
+		}
+	}
+}
+
+// AddYAML adds the sample YAML document in the data, preserving the order of
+// mapping keys.
+func (g *Generator) AddYAML(data []byte) error {
+	var sample interface{}
+	if err := yaml.Unmarshal(data, &sample); err != nil {
+		return fmt.Errorf("structgen: invalid YAML sample: %v", err)
+	}
+	// decode again into ordered mappings, where the document allows it
+	switch sample.(type) {
+	case map[interface{}]interface{}:
+		var object yaml.MapSlice
+		if err := yaml.Unmarshal(data, &object); err == nil {
+			return g.AddSample(object)
+		}
+	case []interface{}:
+		var list []yaml.MapSlice
+		if err := yaml.Unmarshal(data, &list); err == nil {
+			samples := make([]interface{}, len(list))
+			for i := range list {
+				samples[i] = list[i]
+			}
+			return g.AddSample(samples)
+		}
+	}
+	return g.AddSample(sample)
+}
+
+// Generate returns the gofmt-formatted source of the types inferred from the
+// samples added so far.
+func (g *Generator) Generate() ([]byte, error) {
+	g.declared = map[string]bool{}
+	g.imports = map[string]bool{}
+	g.buffer.Reset()
+
+	name := goIdentifier(g.name)
+	g.declared[name] = true
+	var declarations bytes.Buffer
+	pending := []sampleType{{name: name, shape: g.root}}
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+		g.buffer.Reset()
+		var nested []sampleType
+		if next.shape.kinds&^sampleNull == sampleObject {
+			fmt.Fprintf(&g.buffer, "type %s struct {\n", next.name)
+			nested = g.fields(next.name, next.shape)
+			g.buffer.WriteString("}\n\n")
+		} else {
+			fmt.Fprintf(&g.buffer, "type %s ", next.name)
+			g.buffer.WriteString(g.typeOf(next.name, next.shape, false, &nested))
+			g.buffer.WriteString("\n\n")
+		}
+		declarations.Write(g.buffer.Bytes())
+		pending = append(pending, nested...)
+	}
+
+	var source bytes.Buffer
+	if g.pkg != "" {
+		fmt.Fprintf(&source, "package %s\n\n", g.pkg)
+		if len(g.imports) > 0 {
+			imports := make([]string, 0, len(g.imports))
+			for path := range g.imports {
+				imports = append(imports, strconv.Quote(path))
+			}
+			sort.Strings(imports)
+			fmt.Fprintf(&source, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+		}
+	}
+	source.Write(bytes.TrimRight(declarations.Bytes(), "\n"))
+	source.WriteString("\n")
+	return format.Source(source.Bytes())
+}
+
+// sampleType is a named type to be declared.
+type sampleType struct {
+	name  string
+	shape *sampleShape
+}
+
+// fields writes the fields of a struct type, and returns the nested types
+// to be declared.
+func (g *Generator) fields(parent string, shape *sampleShape) []sampleType {
+	var nested []sampleType
+	names := map[string]bool{}
+	for _, key := range shape.keys {
+		field := shape.fields[key]
+		name := goIdentifier(key)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s%d", goIdentifier(key), i)
+		}
+		names[name] = true
+		optional := field.nulls > 0 || shape.fieldCounts[key] < shape.objects
+		// nested types are named after the field, or qualified by the name
+		// of the parent type if that is already taken
+		nestedName := name
+		if g.declared[nestedName] {
+			nestedName = parent + name
+		}
+		typ := g.typeOf(nestedName, field, optional, &nested)
+		var tags []string
+		for _, tag := range g.tags {
+			if optional {
+				tags = append(tags, fmt.Sprintf("%s:%q", tag, key+",omitempty"))
+			} else {
+				tags = append(tags, fmt.Sprintf("%s:%q", tag, key))
+			}
+		}
+		if len(tags) > 0 {
+			fmt.Fprintf(&g.buffer, "\t%s %s `%s`\n", name, typ, strings.Join(tags, " "))
+		} else {
+			fmt.Fprintf(&g.buffer, "\t%s %s\n", name, typ)
+		}
+	}
+	return nested
+}
+
+// typeOf returns the Go type of a shape; nested object types are named after
+// the given name, made unique, and added to the nested types to be declared.
+func (g *Generator) typeOf(name string, shape *sampleShape, optional bool, nested *[]sampleType) string {
+	var typ string
+	switch shape.kinds &^ sampleNull {
+	case sampleBool:
+		typ = "bool"
+	case sampleInteger:
+		typ = "int"
+	case sampleInteger | sampleFloat, sampleFloat:
+		typ = "float64"
+	case sampleString:
+		if shape.times == shape.strings {
+			typ = "time.Time"
+			g.imports["time"] = true
+		} else {
+			typ = "string"
+		}
+	case sampleObject:
+		typ = g.declare(name, shape, nested)
+	case sampleArray:
+		element := shape.element
+		if element == nil {
+			element = &sampleShape{}
+		}
+		return "[]" + g.typeOf(singular(name), element, element.nulls > 0, nested)
+	default:
+		return "interface{}"
+	}
+	if optional {
+		return "*" + typ
+	}
+	return typ
+}
+
+// declare reserves a unique name for a nested type, and returns it.
+func (g *Generator) declare(name string, shape *sampleShape, nested *[]sampleType) string {
+	unique := name
+	for i := 2; g.declared[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	g.declared[unique] = true
+	*nested = append(*nested, sampleType{name: unique, shape: shape})
+	return unique
+}
+
+// sampleKind is a set of the kinds of value a shape has been seen with.
+type sampleKind int
+
+const (
+	sampleNull sampleKind = 1 << iota
+	sampleBool
+	sampleInteger
+	sampleFloat
+	sampleString
+	sampleObject
+	sampleArray
+)
+
+// sampleShape is the unified shape of a set of sample values: the kinds they
+// have, the fields of the objects (in order of appearance, with the number
+// of objects each appears in) and the unified shape of the array elements.
+type sampleShape struct {
+	kinds       sampleKind
+	nulls       int
+	strings     int
+	times       int
+	objects     int
+	keys        []string
+	fields      map[string]*sampleShape
+	fieldCounts map[string]int
+	element     *sampleShape
+}
+
+// sampleObjectValue is a decoded object, with its keys in order.
+type sampleObjectValue struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// merge unifies a sample value into the shape.
+func (s *sampleShape) merge(sample interface{}) error {
+	switch sample := sample.(type) {
+	case nil:
+		s.kinds |= sampleNull
+		s.nulls++
+	case bool:
+		s.kinds |= sampleBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s.kinds |= sampleInteger
+	case float32, float64:
+		s.kinds |= sampleFloat
+	case json.Number:
+		if _, err := sample.Int64(); err == nil {
+			s.kinds |= sampleInteger
+		} else {
+			s.kinds |= sampleFloat
+		}
+	case string:
+		s.kinds |= sampleString
+		s.strings++
+		if _, err := time.Parse(time.RFC3339Nano, sample); err == nil {
+			s.times++
+		}
+	case []interface{}:
+		s.kinds |= sampleArray
+		if s.element == nil {
+			s.element = &sampleShape{}
+		}
+		for _, element := range sample {
+			if err := s.element.merge(element); err != nil {
+				return err
+			}
+		}
+	case *sampleObjectValue:
+		s.kinds |= sampleObject
+		s.objects++
+		if s.fields == nil {
+			s.fields = map[string]*sampleShape{}
+			s.fieldCounts = map[string]int{}
+		}
+		for _, key := range sample.keys {
+			field, ok := s.fields[key]
+			if !ok {
+				field = &sampleShape{}
+				s.fields[key] = field
+				s.keys = append(s.keys, key)
+			}
+			s.fieldCounts[key]++
+			if err := field.merge(sample.values[key]); err != nil {
+				return err
+			}
+		}
+	case yaml.MapSlice:
+		object := &sampleObjectValue{values: map[string]interface{}{}}
+		for _, item := range sample {
+			key := fmt.Sprint(item.Key)
+			if _, ok := object.values[key]; !ok {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = item.Value
+		}
+		return s.merge(object)
+	case map[string]interface{}:
+		object := &sampleObjectValue{values: sample}
+		for key := range sample {
+			object.keys = append(object.keys, key)
+		}
+		sort.Strings(object.keys)
+		return s.merge(object)
+	case map[interface{}]interface{}:
+		object := &sampleObjectValue{values: map[string]interface{}{}}
+		for key, value := range sample {
+			object.keys = append(object.keys, fmt.Sprint(key))
+			object.values[fmt.Sprint(key)] = value
+		}
+		sort.Strings(object.keys)
+		return s.merge(object)
+	default:
+		return fmt.Errorf("structgen: unsupported sample value of type %T", sample)
+	}
+	return nil
+}
+
+// decodeJSONSample decodes the next JSON value from the stream, preserving
+// the order of object keys.
+func decodeJSONSample(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &sampleObjectValue{values: map[string]interface{}{}}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			value, err := decodeJSONSample(decoder)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if _, ok := object.values[key.(string)]; !ok {
+				object.keys = append(object.keys, key.(string))
+			}
+			object.values[key.(string)] = value
+		}
+		_, err = decoder.Token()
+		return object, unexpectedEOF(err)
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeJSONSample(decoder)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, unexpectedEOF(err)
+	}
+	return token, nil
+}
+
+// unexpectedEOF turns the end of the data within a document into an error,
+// so that it is not mistaken for the end of the samples.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// goInitialisms are the words written in upper case in Go identifiers.
+var goInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "URI": true,
+	"URL": true, "UTF8": true, "UUID": true, "VM": true, "XML": true, "XMPP": true,
+	"XSRF": true, "XSS": true,
+}
+
+// goIdentifier turns a key into an exported Go identifier, e.g. "user_id"
+// into "UserID" and "first-name" into "FirstName".
+func goIdentifier(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var identifier strings.Builder
+	for _, word := range words {
+		if goInitialisms[strings.ToUpper(word)] {
+			identifier.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		identifier.WriteRune(unicode.ToUpper(runes[0]))
+		identifier.WriteString(string(runes[1:]))
+	}
+	switch {
+	case identifier.Len() == 0:
+		return "Field"
+	case unicode.IsDigit([]rune(identifier.String())[0]):
+		return "X" + identifier.String()
+	}
+	return identifier.String()
+}
+
+// singular returns the naive singular of a plural type name, e.g. "Item"
+// for "Items" and "Category" for "Categories", for the type of the elements
+// of arrays.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
@@ -0,0 +1,96 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package structgen
+
+import (
+	"testing"
+)
+
+func TestGeneratorJSON(t *testing.T) {
+	samples := `{"name": "a", "port": 80, "ratio": 1, "created": "2020-01-02T03:04:05Z", "servers": [{"host": "h", "up": true}], "owner": {"first-name": "x"}, "tags": ["a"], "mixed": [1, "a"], "null": null}
+{"name": "b", "port": 81, "ratio": 1.5, "created": "2021-01-02T03:04:05Z", "servers": [{"host": "i", "weight": 2}], "extra": 1}`
+	expected := "package config\n\n" +
+		"import (\n\t\"time\"\n)\n\n" +
+		"type Config struct {\n" +
+		"\tName    string        `json:\"name\" yaml:\"name\"`\n" +
+		"\tPort    int           `json:\"port\" yaml:\"port\"`\n" +
+		"\tRatio   float64       `json:\"ratio\" yaml:\"ratio\"`\n" +
+		"\tCreated time.Time     `json:\"created\" yaml:\"created\"`\n" +
+		"\tServers []Server      `json:\"servers\" yaml:\"servers\"`\n" +
+		"\tOwner   *Owner        `json:\"owner,omitempty\" yaml:\"owner,omitempty\"`\n" +
+		"\tTags    []string      `json:\"tags,omitempty\" yaml:\"tags,omitempty\"`\n" +
+		"\tMixed   []interface{} `json:\"mixed,omitempty\" yaml:\"mixed,omitempty\"`\n" +
+		"\tNull    interface{}   `json:\"null,omitempty\" yaml:\"null,omitempty\"`\n" +
+		"\tExtra   *int          `json:\"extra,omitempty\" yaml:\"extra,omitempty\"`\n" +
+		"}\n\n" +
+		"type Server struct {\n" +
+		"\tHost   string `json:\"host\" yaml:\"host\"`\n" +
+		"\tUp     *bool  `json:\"up,omitempty\" yaml:\"up,omitempty\"`\n" +
+		"\tWeight *int   `json:\"weight,omitempty\" yaml:\"weight,omitempty\"`\n" +
+		"}\n\n" +
+		"type Owner struct {\n" +
+		"\tFirstName string `json:\"first-name\" yaml:\"first-name\"`\n" +
+		"}\n"
+
+	generator := NewGenerator("Config")
+	generator.SetPackage("config")
+	generator.SetTags("json", "yaml")
+	if err := generator.AddJSON([]byte(samples)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	source, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(source) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, source)
+	}
+}
+
+func TestGeneratorYAML(t *testing.T) {
+	expected := "type Doc struct {\n" +
+		"\tB int     `json:\"b\"`\n" +
+		"\tA []AItem `json:\"a\"`\n" +
+		"}\n\n" +
+		"type AItem struct {\n" +
+		"\tX bool `json:\"x\"`\n" +
+		"}\n"
+	generator := NewGenerator("Doc")
+	if err := generator.AddYAML([]byte("b: 1\na:\n  - x: true\n")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	source, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(source) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, source)
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	for _, sample := range []string{`{"a":`, `[1, 2`, `{"a": 1} {"b"`, `{"a" 1}`, `]`} {
+		if err := NewGenerator("X").AddJSON([]byte(sample)); err == nil {
+			t.Errorf("%s: expected an error", sample)
+		}
+	}
+	if err := NewGenerator("X").AddYAML([]byte("a: [")); err == nil {
+		t.Errorf("expected an error for invalid YAML")
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"user_id":    "UserID",
+		"first-name": "FirstName",
+		"http url":   "HTTPURL",
+		"name":       "Name",
+	}
+	for key, expected := range tests {
+		if actual := goIdentifier(key); actual != expected {
+			t.Errorf("%s: expected %s, got %s", key, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// TableFormat is the output format of a TableObserver.
+type TableFormat int8
+
+const (
+	// TableText renders the table as plain text, with aligned columns.
+	TableText TableFormat = iota
+	// TableMarkdown renders the table in (GitHub-flavoured) Markdown.
+	TableMarkdown
+	// TableCSV renders the table as RFC 4180 comma separated values.
+	TableCSV
+)
+
+// TableObserver is an Observer that renders a slice (or array) of structs as
+// a table, with one row per element and one column per leaf field, identified
+// by its flattened path relative to the element (e.g. "Address.City" or
+// "Tags[0]"); if the visited object is not a list, it is rendered as a table
+// with a single row. Rows are buffered and written out by Flush.
+type TableObserver struct {
+	writer  io.Writer
+	format  TableFormat
+	tag     string
+	columns []string
+	list    bool
+	labels  []string
+	seen    []string
+	rows    []map[string]string
+}
+
+// NewTableObserver returns a TableObserver writing to the given stream in
+// the given format.
+func NewTableObserver(writer io.Writer, format TableFormat) *TableObserver {
+	return &TableObserver{
+		writer: writer,
+		format: format,
+	}
+}
+
+// SetTag makes the observer name fields after the given struct tag (e.g.
+// "json" or "csv") instead of their Go name, both in the column headers and
+// in the paths passed to SetColumns; fields whose tag is "-" are skipped.
+func (o *TableObserver) SetTag(tag string) {
+	o.tag = tag
+}
+
+// SetColumns selects which columns are rendered, and in which order, by
+// their flattened path; by default all columns are rendered, in the order
+// they are first encountered.
+func (o *TableObserver) SetColumns(columns ...string) {
+	o.columns = columns
+}
+
+// Reset discards all buffered rows, so the observer can be reused.
+func (o *TableObserver) Reset() {
+	o.list = false
+	o.labels = nil
+	o.seen = nil
+	o.rows = nil
+}
+
+// Flush writes the buffered rows to the stream and then resets the observer.
+func (o *TableObserver) Flush() error {
+	headers := o.columns
+	if len(headers) == 0 {
+		headers = o.seen
+	}
+	cells := make([][]string, 0, len(o.rows))
+	for _, row := range o.rows {
+		line := make([]string, len(headers))
+		for i, header := range headers {
+			line[i] = row[header]
+		}
+		cells = append(cells, line)
+	}
+	titles := make([]string, len(headers))
+	for i, header := range headers {
+		titles[i] = header
+		if header == "" {
+			titles[i] = "value"
+		}
+	}
+
+	var err error
+	switch o.format {
+	case TableMarkdown:
+		err = writeMarkdownTable(o.writer, titles, cells)
+	case TableCSV:
+		err = writeCSVTable(o.writer, titles, cells)
+	default:
+		err = writeTextTable(o.writer, titles, cells)
+	}
+	o.Reset()
+	return err
+}
+
+func (o *TableObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	// nil values leave their cells empty
+	o.enter()
+	return true
+}
+
+func (o *TableObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	if object.Kind() == reflect.Invalid {
+		o.cell(name, tags, "")
+	} else if object.CanInterface() {
+		o.cell(name, tags, fmt.Sprintf("%v", object.Interface()))
+	}
+	return true
+}
+
+func (o *TableObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, tags)
+	return true
+}
+
+func (o *TableObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start && len(o.labels) == 0 {
+		o.list = true
+	}
+	o.composite(name, start, tags)
+	return true
+}
+
+func (o *TableObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, tags)
+	return true
+}
+
+func (o *TableObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, tags)
+	return true
+}
+
+func (o *TableObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	o.composite(name, start, tags)
+	return true
+}
+
+func (o *TableObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	o.cell(name, tags, object.Type().String())
+	return true
+}
+
+func (o *TableObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	o.cell(name, tags, object.Type().String())
+	return true
+}
+
+func (o *TableObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	o.cell(name, tags, fmt.Sprintf("%#x", object.Pointer()))
+	return true
+}
+
+// composite tracks the opening and closing of nodes with children.
+func (o *TableObserver) composite(name string, start bool, tags string) {
+	if start {
+		o.enter()
+		o.labels = append(o.labels, o.label(name, tags))
+	} else if len(o.labels) > 0 {
+		o.labels = o.labels[:len(o.labels)-1]
+	}
+}
+
+// cell records a leaf value in the current row.
+func (o *TableObserver) cell(name string, tags string, value string) {
+	o.enter()
+	depth := o.depth()
+	if len(o.rows) == 0 || len(o.labels) < depth {
+		return
+	}
+	column := ""
+	if len(o.labels) == depth {
+		// the element itself is a leaf: single, unnamed column
+		o.store(column, value)
+		return
+	}
+	for _, label := range append(o.labels[depth+1:], o.label(name, tags)) {
+		if label == "-" {
+			return
+		}
+		column = chain(column, label)
+	}
+	o.store(column, value)
+}
+
+// store sets the value of a column in the current row.
+func (o *TableObserver) store(column string, value string) {
+	found := false
+	for _, seen := range o.seen {
+		if seen == column {
+			found = true
+			break
+		}
+	}
+	if !found {
+		o.seen = append(o.seen, column)
+	}
+	o.rows[len(o.rows)-1][column] = value
+}
+
+// enter opens a new row when the node being entered is an element of the
+// visited list (or the visited object itself, if it is not a list).
+func (o *TableObserver) enter() {
+	if len(o.labels) == o.depth() && !(o.list && len(o.labels) == 0) {
+		o.rows = append(o.rows, map[string]string{})
+	}
+}
+
+// depth returns the nesting level of the nodes that make up the rows.
+func (o *TableObserver) depth() int {
+	if o.list {
+		return 1
+	}
+	return 0
+}
+
+// label returns the name of a node as it appears in column paths: the tag
+// name if the observer is tag-driven, nothing for pointer and interface
+// values, which are transparent.
+func (o *TableObserver) label(name string, tags string) string {
+	if name == ".value" {
+		return ""
+	}
+	if o.tag != "" {
+		if value, ok := reflect.StructTag(tags).Lookup(o.tag); ok {
+			if value = strings.Split(value, ",")[0]; value != "" {
+				return value
+			}
+		}
+	}
+	return name
+}
+
+// writeTextTable writes a plain text table with aligned columns; new lines
+// in cells are escaped so that each row stays on a single line.
+func writeTextTable(writer io.Writer, headers []string, rows [][]string) error {
+	escaper := strings.NewReplacer("\r", `\r`, "\n", `\n`)
+	for _, row := range rows {
+		for i := range row {
+			row[i] = escaper.Replace(row[i])
+		}
+	}
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len([]rune(header))
+		for _, row := range rows {
+			widths[i] = max(widths[i], len([]rune(row[i])))
+		}
+	}
+	format := func(cells []string) string {
+		line := ""
+		for i, cell := range cells {
+			if i > 0 {
+				line += "  "
+			}
+			line += cell + pad(cell, widths[i])
+		}
+		return strings.TrimRight(line, " ")
+	}
+	rules := make([]string, len(headers))
+	for i := range headers {
+		rules[i] = strings.Repeat("-", widths[i])
+	}
+	lines := []string{format(headers), format(rules)}
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdownTable writes a Markdown table, escaping pipes and new lines.
+func writeMarkdownTable(writer io.Writer, headers []string, rows [][]string) error {
+	escaper := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	format := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escaper.Replace(cell)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	rules := make([]string, len(headers))
+	for i := range headers {
+		rules[i] = "---"
+	}
+	lines := []string{format(headers), format(rules)}
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSVTable writes an RFC 4180 compliant CSV table.
+func writeCSVTable(writer io.Writer, headers []string, rows [][]string) error {
+	w := csv.NewWriter(writer)
+	w.UseCRLF = true
+	if err := w.Write(headers); err != nil {
+		return err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
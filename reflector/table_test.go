@@ -0,0 +1,89 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"strings"
+	"testing"
+)
+
+type tableAddress struct {
+	City string `json:"city"`
+}
+
+type tablePerson struct {
+	Name    string        `json:"name"`
+	Age     int           `json:"age"`
+	Address *tableAddress `json:"address"`
+	Tags    []string      `json:"-"`
+	Note    string        `json:"note"`
+}
+
+var tablePeople = []tablePerson{
+	{Name: "Ann", Age: 42, Address: &tableAddress{City: "Rome"}, Tags: []string{"a"}, Note: "x,y"},
+	{Name: "Bob | B", Age: 7, Note: "line\nbreak"},
+}
+
+func TestTable(t *testing.T) {
+	tests := []struct {
+		format   TableFormat
+		expected string
+	}{
+		{TableText, "" +
+			"Name     Age  Address.City  Tags[0]  Note\n" +
+			"-------  ---  ------------  -------  -----------\n" +
+			"Ann      42   Rome          a        x,y\n" +
+			"Bob | B  7                           line\\nbreak\n"},
+		{TableMarkdown, "" +
+			"| Name | Age | Address.City | Tags[0] | Note |\n" +
+			"| --- | --- | --- | --- | --- |\n" +
+			"| Ann | 42 | Rome | a | x,y |\n" +
+			"| Bob \\| B | 7 |  |  | line<br>break |\n"},
+		{TableCSV, "" +
+			"Name,Age,Address.City,Tags[0],Note\r\n" +
+			"Ann,42,Rome,a,\"x,y\"\r\n" +
+			"Bob | B,7,,,\"line\r\nbreak\"\r\n"},
+	}
+	for _, test := range tests {
+		var buffer strings.Builder
+		observer := NewTableObserver(&buffer, test.format)
+		Visit("", "", tablePeople, nil, observer)
+		if err := observer.Flush(); err != nil {
+			t.Errorf("format %d: unexpected error %v", test.format, err)
+		}
+		if actual := buffer.String(); actual != test.expected {
+			t.Errorf("format %d: expected\n%q, got\n%q", test.format, test.expected, actual)
+		}
+	}
+}
+
+func TestTableOptions(t *testing.T) {
+	var buffer strings.Builder
+	observer := NewTableObserver(&buffer, TableText)
+	observer.SetTag("json")
+	observer.SetColumns("address.city", "name")
+
+	tests := []struct {
+		object   interface{}
+		columns  []string
+		expected string
+	}{
+		{tablePeople, []string{"address.city", "name"}, "address.city  name\n------------  -------\nRome          Ann\n              Bob | B\n"},
+		{tablePeople[0], []string{"address.city", "name"}, "address.city  name\n------------  ----\nRome          Ann\n"},
+		{[]int{1, 2}, nil, "value\n-----\n1\n2\n"},
+		{tablePeople, []string{"note", "tags"}, "note         tags\n-----------  ----\nx,y\nline\\nbreak\n"},
+	}
+	for _, test := range tests {
+		buffer.Reset()
+		observer.SetColumns(test.columns...)
+		Visit("", "", test.object, nil, observer)
+		if err := observer.Flush(); err != nil {
+			t.Errorf("%v: unexpected error %v", test.object, err)
+		}
+		if actual := buffer.String(); actual != test.expected {
+			t.Errorf("%v: expected\n%q, got\n%q", test.object, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// Theme assigns colours and styles to the elements of a text dump; an
+// element with no attributes is not coloured.
+type Theme struct {
+	// Names is used for field names and map keys.
+	Names []color.Attribute
+	// Types is used for type names.
+	Types []color.Attribute
+	// Strings is used for string values.
+	Strings []color.Attribute
+	// Numbers is used for integer, floating point and complex values.
+	Numbers []color.Attribute
+	// Values is used for all other leaf values (booleans, times...).
+	Values []color.Attribute
+	// Nils is used for nil and invalid values.
+	Nils []color.Attribute
+	// Tags is used for struct tags.
+	Tags []color.Attribute
+	// Punctuation is used for brackets, separators and tree connectors.
+	Punctuation []color.Attribute
+}
+
+// DarkTheme is meant for terminals with a dark background.
+var DarkTheme = &Theme{
+	Names:       []color.Attribute{color.FgHiWhite, color.Bold},
+	Types:       []color.Attribute{color.FgCyan},
+	Strings:     []color.Attribute{color.FgGreen},
+	Numbers:     []color.Attribute{color.FgHiMagenta},
+	Values:      []color.Attribute{color.FgHiYellow},
+	Nils:        []color.Attribute{color.FgHiRed},
+	Tags:        []color.Attribute{color.FgYellow},
+	Punctuation: []color.Attribute{color.FgHiBlack},
+}
+
+// LightTheme is meant for terminals with a light background.
+var LightTheme = &Theme{
+	Names:       []color.Attribute{color.FgBlack, color.Bold},
+	Types:       []color.Attribute{color.FgBlue},
+	Strings:     []color.Attribute{color.FgGreen},
+	Numbers:     []color.Attribute{color.FgMagenta},
+	Values:      []color.Attribute{color.FgRed},
+	Nils:        []color.Attribute{color.FgRed, color.Bold},
+	Tags:        []color.Attribute{color.FgYellow},
+	Punctuation: []color.Attribute{color.FgHiBlack},
+}
+
+// Colorizer applies a Theme to the elements of a text dump; a Colorizer with
+// no theme leaves all text as is.
+type Colorizer struct {
+	theme *Theme
+}
+
+// NewColorizer returns a Colorizer applying the given theme to the text
+// written to the given stream, unless colours are not supported there (see
+// ColorSupported) or the theme is nil, in which case text is left as is.
+func NewColorizer(writer io.Writer, theme *Theme) *Colorizer {
+	if theme == nil || !ColorSupported(writer) {
+		return &Colorizer{}
+	}
+	return &Colorizer{theme: theme}
+}
+
+// ColorSupported returns whether coloured output should be written to the
+// stream: this is not the case if the NO_COLOR environment variable is set
+// to a non-empty value (see https://no-color.org), if the terminal is dumb,
+// or if the stream is not a terminal.
+func ColorSupported(writer io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	file, ok := writer.(*os.File)
+	return ok && (isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd()))
+}
+
+// Name colours a field name or map key.
+func (c *Colorizer) Name(s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute { return t.Names }))
+}
+
+// Type colours a type name.
+func (c *Colorizer) Type(s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute { return t.Types }))
+}
+
+// Nil colours a nil or invalid value.
+func (c *Colorizer) Nil(s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute { return t.Nils }))
+}
+
+// Tag colours a struct tag.
+func (c *Colorizer) Tag(s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute { return t.Tags }))
+}
+
+// Punctuation colours brackets, separators and tree connectors.
+func (c *Colorizer) Punctuation(s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute { return t.Punctuation }))
+}
+
+// Leaf colours a formatted leaf value according to its kind.
+func (c *Colorizer) Leaf(kind reflect.Kind, s string) string {
+	return c.paint(s, c.attributes(func(t *Theme) []color.Attribute {
+		switch kind {
+		case reflect.Invalid:
+			return t.Nils
+		case reflect.String:
+			return t.Strings
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+			return t.Numbers
+		}
+		return t.Values
+	}))
+}
+
+// attributes returns the attributes selected from the theme, if any.
+func (c *Colorizer) attributes(selector func(*Theme) []color.Attribute) []color.Attribute {
+	if c == nil || c.theme == nil {
+		return nil
+	}
+	return selector(c.theme)
+}
+
+// paint applies the attributes to the string; colours are forced on, since
+// whether they are supported has already been established for the stream
+// the output goes to, which might not be the standard output.
+func (c *Colorizer) paint(s string, attributes []color.Attribute) string {
+	if len(attributes) == 0 || s == "" {
+		return s
+	}
+	painter := color.New(attributes...)
+	painter.EnableColor()
+	return painter.Sprint(s)
+}
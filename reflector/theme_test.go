@@ -0,0 +1,71 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestColorizer(t *testing.T) {
+	theme := &Theme{
+		Names:       []color.Attribute{color.Bold},
+		Types:       []color.Attribute{color.FgCyan},
+		Strings:     []color.Attribute{color.FgGreen},
+		Numbers:     []color.Attribute{color.FgMagenta},
+		Values:      []color.Attribute{color.FgYellow},
+		Nils:        []color.Attribute{color.FgRed},
+		Punctuation: []color.Attribute{color.FgBlack},
+	}
+	c := &Colorizer{theme: theme}
+	tests := []struct {
+		actual   string
+		expected string
+	}{
+		{c.Name("n"), "\x1b[1mn"},
+		{c.Type("t"), "\x1b[36mt"},
+		{c.Nil("nil"), "\x1b[31mnil"},
+		{c.Punctuation("{"), "\x1b[30m{"},
+		{c.Leaf(reflect.String, `"s"`), "\x1b[32m\"s\""},
+		{c.Leaf(reflect.Uint8, "1"), "\x1b[35m1"},
+		{c.Leaf(reflect.Complex128, "(1+2i)"), "\x1b[35m(1+2i)"},
+		{c.Leaf(reflect.Bool, "true"), "\x1b[33mtrue"},
+		{c.Leaf(reflect.Invalid, "nil"), "\x1b[31mnil"},
+		// no attributes, or nothing to colour: no codes at all
+		{c.Tag(`json:"x"`), `json:"x"`},
+		{c.Name(""), ""},
+	}
+	for i, test := range tests {
+		// the codes that reset attributes depend on the attributes
+		if test.actual != test.expected && !(strings.HasPrefix(test.actual, test.expected) && strings.HasPrefix(test.actual[len(test.expected):], "\x1b[")) {
+			t.Errorf("%d: expected %q, got %q", i, test.expected, test.actual)
+		}
+	}
+
+	for _, plain := range []*Colorizer{nil, {}, NewColorizer(&strings.Builder{}, DarkTheme), NewColorizer(os.Stdout, nil)} {
+		if actual := plain.Name("n") + plain.Leaf(reflect.Int, "1"); actual != "n1" {
+			t.Errorf("expected no colours, got %q", actual)
+		}
+	}
+}
+
+func TestColorSupported(t *testing.T) {
+	if ColorSupported(&strings.Builder{}) {
+		t.Errorf("expected no colours on a buffer")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if ColorSupported(os.Stdout) {
+		t.Errorf("expected no colours with NO_COLOR set")
+	}
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "dumb")
+	if ColorSupported(os.Stdout) {
+		t.Errorf("expected no colours on a dumb terminal")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOMLObserver is an Observer that renders the visited object as a TOML
+// document: structs and maps become tables, lists of structs or maps become
+// arrays of tables, any other list becomes an inline array. Struct tags (by
+// default the "toml" tag) can rename fields, skip them ("-") or omit them
+// when empty (",omitempty"). TOML has no null, so nil values are left out.
+// The visited object must be a struct or a map; the document is written out
+// by Flush.
+type TOMLObserver struct {
+	docBuilder
+	writer io.Writer
+	tag    string
+}
+
+// NewTOMLObserver returns a TOMLObserver writing to the given stream.
+func NewTOMLObserver(writer io.Writer) *TOMLObserver {
+	return &TOMLObserver{
+		writer: writer,
+		tag:    "toml",
+	}
+}
+
+// SetTag sets the struct tag used to name fields.
+func (o *TOMLObserver) SetTag(tag string) {
+	o.tag = tag
+}
+
+// Flush writes the TOML document to the stream and then resets the observer.
+func (o *TOMLObserver) Flush() error {
+	root := collapse(o.root)
+	o.docBuilder = docBuilder{}
+	if root == nil {
+		return nil
+	}
+	if root.kind != docStruct && root.kind != docMap {
+		return errors.New("reflector: TOML documents must be a struct or a map")
+	}
+	buffer := &bytes.Buffer{}
+	o.table(buffer, nil, false, root)
+	_, err := o.writer.Write(bytes.TrimLeft(buffer.Bytes(), "\n"))
+	return err
+}
+
+// table writes a table and its sub-tables; path is the list of keys leading
+// to the table, array tells whether it is an element of an array of tables.
+func (o *TOMLObserver) table(buffer *bytes.Buffer, path []string, array bool, node *docNode) {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+	if array {
+		fmt.Fprintf(buffer, "\n[[%s]]\n", strings.Join(keys, "."))
+	} else if len(path) > 0 {
+		fmt.Fprintf(buffer, "\n[%s]\n", strings.Join(keys, "."))
+	}
+
+	// simple values must all come before any sub-table
+	tables := []docField{}
+	for _, field := range node.fields(o.tag) {
+		switch {
+		case field.node.kind == docNil:
+			// there is no null in TOML
+		case field.node.kind == docStruct, field.node.kind == docMap, tomlTables(field.node):
+			tables = append(tables, field)
+		default:
+			fmt.Fprintf(buffer, "%s = %s\n", tomlKey(field.key), o.inline(field.node))
+		}
+	}
+	for _, field := range tables {
+		path := append(path[:len(path):len(path)], field.key)
+		if field.node.kind == docList {
+			for _, child := range field.node.children {
+				o.table(buffer, path, true, child)
+			}
+		} else {
+			o.table(buffer, path, false, field.node)
+		}
+	}
+}
+
+// inline returns the inline representation of a value.
+func (o *TOMLObserver) inline(node *docNode) string {
+	switch node.kind {
+	case docList:
+		values := []string{}
+		for _, child := range node.children {
+			if child.kind != docNil {
+				values = append(values, o.inline(child))
+			}
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case docStruct, docMap:
+		values := []string{}
+		for _, field := range node.fields(o.tag) {
+			if field.node.kind != docNil {
+				values = append(values, tomlKey(field.key)+" = "+o.inline(field.node))
+			}
+		}
+		if len(values) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(values, ", ") + " }"
+	}
+	return tomlValue(node.value)
+}
+
+// tomlTables returns whether a node is a non-empty list made only of structs
+// and maps, to be rendered as an array of tables.
+func tomlTables(node *docNode) bool {
+	if node.kind != docList || len(node.children) == 0 {
+		return false
+	}
+	for _, child := range node.children {
+		if child.kind != docStruct && child.kind != docMap {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlValue returns the TOML literal for a scalar value.
+func tomlValue(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return tomlString(value.String())
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		}
+		s := strconv.FormatFloat(f, 'g', -1, value.Type().Bits())
+		if !strings.ContainsAny(s, ".eEn") {
+			s += ".0"
+		}
+		return s
+	case reflect.Struct:
+		if value.Type() == timeType && value.CanInterface() {
+			return value.Interface().(time.Time).Format(time.RFC3339Nano)
+		}
+	}
+	return tomlString(fmt.Sprint(value))
+}
+
+// tomlKey returns a key as is if it is a valid bare key, quoted otherwise.
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for _, r := range key {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(key)
+		}
+	}
+	return key
+}
+
+// tomlString returns a string as a TOML basic string.
+func tomlString(s string) string {
+	var buffer strings.Builder
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buffer, `\u%04X`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
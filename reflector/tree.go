@@ -0,0 +1,263 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TreeObserver is an Observer that renders the visited object as a tree,
+// using box-drawing connectors (├──, └──, │) to show the parent-child
+// relationships and aligning the type and value columns; since the columns
+// can only be aligned once all nodes are known, the output is buffered and
+// written out by Flush.
+type TreeObserver struct {
+	writer    io.Writer
+	colorizer *Colorizer
+	formatter *Formatter
+	rows      []treeRow
+	frames    []treeFrame
+}
+
+// treeRow is a single line of the rendered tree.
+type treeRow struct {
+	prefix string
+	name   string
+	typ    string
+	value  string
+	kind   reflect.Kind
+	tags   string
+}
+
+// treeFrame keeps track of a composite node whose children are being
+// visited: remaining is the number of children not yet seen, which is what
+// tells whether the next child is the last one.
+type treeFrame struct {
+	remaining int
+	last      bool
+}
+
+// NewTreeObserver returns a TreeObserver writing to the given stream;
+// colorise enables the colouring of names, types and values with the
+// DarkTheme, if the stream supports it.
+func NewTreeObserver(writer io.Writer, colorise bool) *TreeObserver {
+	o := &TreeObserver{
+		writer:    writer,
+		colorizer: &Colorizer{},
+		formatter: DefaultFormatter,
+	}
+	if colorise {
+		o.SetTheme(DarkTheme)
+	}
+	return o
+}
+
+// SetTheme sets the colour theme, if the stream supports colours; a nil
+// theme disables colours.
+func (o *TreeObserver) SetTheme(theme *Theme) {
+	o.colorizer = NewColorizer(o.writer, theme)
+}
+
+// SetFormatter sets the Formatter used for leaf values.
+func (o *TreeObserver) SetFormatter(formatter *Formatter) {
+	o.formatter = formatter
+}
+
+// Reset discards all buffered rows, so the observer can be reused.
+func (o *TreeObserver) Reset() {
+	o.rows = nil
+	o.frames = nil
+}
+
+// Flush writes the buffered rows to the stream, with type and value columns
+// aligned, and then resets the observer.
+func (o *TreeObserver) Flush() error {
+	nameWidth, typeWidth, valueWidth := 0, 0, 0
+	for _, row := range o.rows {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(row.prefix+row.name))
+		typeWidth = max(typeWidth, utf8.RuneCountInString(row.typ))
+		valueWidth = max(valueWidth, utf8.RuneCountInString(row.value))
+	}
+
+	c := o.colorizer
+	for _, row := range o.rows {
+		line := c.Punctuation(row.prefix) + c.Name(row.name) + pad(row.prefix+row.name, nameWidth) + "  " +
+			c.Type(row.typ) + pad(row.typ, typeWidth)
+		if valueWidth > 0 {
+			switch row.kind {
+			case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+				line += "  " + c.Punctuation(row.value)
+			default:
+				line += "  " + c.Leaf(row.kind, row.value)
+			}
+			line += pad(row.value, valueWidth)
+		}
+		if row.tags != "" {
+			line += "  " + c.Tag("`"+row.tags+"`")
+		}
+		if _, err := fmt.Fprintln(o.writer, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	o.Reset()
+	return nil
+}
+
+// String returns the tree as it would be written by Flush, without colours
+// and without resetting the observer.
+func (o *TreeObserver) String() string {
+	var buffer strings.Builder
+	clone := &TreeObserver{writer: &buffer, colorizer: &Colorizer{}, rows: o.rows}
+	clone.Flush()
+	return buffer.String()
+}
+
+// IsLeaf returns whether the Formatter treats a composite value as a leaf,
+// which is then rendered as a single value.
+func (o *TreeObserver) IsLeaf(object reflect.Value) bool {
+	return o.formatter.IsLeaf(object)
+}
+
+func (o *TreeObserver) OnNil(path string, name string, tags string, typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	o.leaf(name, typ.String(), "<nil>", reflect.Invalid, "")
+	return true
+}
+
+func (o *TreeObserver) OnValue(path string, name string, tags string, object reflect.Value) bool {
+	switch {
+	case object.Kind() == reflect.Invalid:
+		o.leaf(name, "<invalid>", "", reflect.Invalid, tags)
+	case object.CanInterface():
+		o.leaf(name, object.Type().String(), o.formatter.FormatValue(object), object.Kind(), tags)
+	default:
+		o.leaf(name, object.Type().String(), "<unexported>", reflect.Invalid, tags)
+	}
+	return true
+}
+
+func (o *TreeObserver) OnPointer(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(name, object.Type().String(), "", object.Kind(), tags, 1)
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *TreeObserver) OnList(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(name, object.Type().String(), fmt.Sprintf("len=%d", object.Len()), object.Kind(), tags, object.Len())
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *TreeObserver) OnStruct(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(name, object.Type().String(), "", object.Kind(), tags, object.NumField())
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *TreeObserver) OnMap(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(name, object.Type().String(), fmt.Sprintf("len=%d", object.Len()), object.Kind(), tags, object.Len())
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *TreeObserver) OnInterface(path string, name string, start bool, tags string, object reflect.Value) bool {
+	if start {
+		o.open(name, object.Type().String(), "", object.Kind(), tags, 1)
+	} else {
+		o.close()
+	}
+	return true
+}
+
+func (o *TreeObserver) OnChannel(path string, name string, tags string, object reflect.Value) bool {
+	o.leaf(name, object.Type().String(), fmt.Sprintf("len=%d cap=%d", object.Len(), object.Cap()), object.Kind(), tags)
+	return true
+}
+
+func (o *TreeObserver) OnFunction(path string, name string, tags string, object reflect.Value) bool {
+	if object.IsNil() {
+		o.leaf(name, object.Type().String(), "<nil>", reflect.Invalid, tags)
+	} else {
+		o.leaf(name, object.Type().String(), "", object.Kind(), tags)
+	}
+	return true
+}
+
+func (o *TreeObserver) OnUnsafePointer(path string, name string, tags string, object reflect.Value) bool {
+	o.leaf(name, object.Type().String(), "0x"+strconv.FormatUint(uint64(object.Pointer()), 16), object.Kind(), tags)
+	return true
+}
+
+// leaf adds a node with no children.
+func (o *TreeObserver) leaf(name, typ, value string, kind reflect.Kind, tags string) {
+	o.rows = append(o.rows, treeRow{prefix: o.prefix(), name: name, typ: typ, value: value, kind: kind, tags: tags})
+}
+
+// open adds a composite node and makes it the parent of the following ones,
+// until the matching call to close.
+func (o *TreeObserver) open(name, typ, value string, kind reflect.Kind, tags string, children int) {
+	last := len(o.frames) == 0 || o.frames[len(o.frames)-1].remaining == 1
+	o.leaf(name, typ, value, kind, tags)
+	o.frames = append(o.frames, treeFrame{remaining: children, last: last})
+}
+
+// close pops the innermost composite node.
+func (o *TreeObserver) close() {
+	if len(o.frames) > 0 {
+		o.frames = o.frames[:len(o.frames)-1]
+	}
+}
+
+// prefix returns the connectors for the next node and accounts for it as a
+// child of the innermost open composite node; the root node has no prefix.
+func (o *TreeObserver) prefix() string {
+	if len(o.frames) == 0 {
+		return ""
+	}
+	var prefix strings.Builder
+	// frames[0] is the root, which has no connector of its own
+	for _, frame := range o.frames[1:] {
+		if frame.last {
+			prefix.WriteString("    ")
+		} else {
+			prefix.WriteString("│   ")
+		}
+	}
+	parent := &o.frames[len(o.frames)-1]
+	parent.remaining--
+	if parent.remaining <= 0 {
+		prefix.WriteString("└── ")
+	} else {
+		prefix.WriteString("├── ")
+	}
+	return prefix.String()
+}
+
+// pad returns the spaces needed to bring s to the given width.
+func pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return strings.Repeat(" ", n)
+	}
+	return ""
+}
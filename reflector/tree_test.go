@@ -0,0 +1,77 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type treeShape struct {
+	Name   string
+	Points []treePoint
+	Origin *treePoint
+}
+
+type treePoint struct{ X, Y int }
+
+func TestTree(t *testing.T) {
+	object := treeShape{Name: "square", Points: []treePoint{{1, 2}, {3, 4}}, Origin: &treePoint{}}
+	expected := "s               reflector.treeShape\n" +
+		"├── Name        string                 \"square\"\n" +
+		"├── Points      []reflector.treePoint  len=2\n" +
+		"│   ├── [0]     reflector.treePoint\n" +
+		"│   │   ├── X   int                    1\n" +
+		"│   │   └── Y   int                    2\n" +
+		"│   └── [1]     reflector.treePoint\n" +
+		"│       ├── X   int                    3\n" +
+		"│       └── Y   int                    4\n" +
+		"└── Origin      *reflector.treePoint\n" +
+		"    └── .value  reflector.treePoint\n" +
+		"        ├── X   int                    0\n" +
+		"        └── Y   int                    0\n"
+	var buffer strings.Builder
+	observer := NewTreeObserver(&buffer, false)
+	Visit("", "s", object, nil, observer)
+	if actual := observer.String(); actual != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, actual)
+	}
+	if err := observer.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if actual := buffer.String(); actual != expected {
+		t.Errorf("expected flushed\n%s\ngot\n%s", expected, actual)
+	}
+	if actual := observer.String(); actual != "" {
+		t.Errorf("expected no rows after Flush, got\n%s", actual)
+	}
+}
+
+func TestTreeLeaves(t *testing.T) {
+	type record struct {
+		Data []byte
+		When time.Time
+	}
+	object := record{Data: []byte("hi"), When: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	formatter := NewFormatter()
+	formatter.Bytes = BytesHex
+	formatter.TimeLayout = "2006-01-02"
+	observer := NewTreeObserver(&strings.Builder{}, false)
+	observer.SetFormatter(formatter)
+	Visit("", "r", object, nil, observer)
+	actual := observer.String()
+	for _, expected := range []string{"6869", "2020-01-02"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %q in:\n%s", expected, actual)
+		}
+	}
+	for _, unexpected := range []string{"[0]", "wall", "<unexported>"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("unexpected %q in:\n%s", unexpected, actual)
+		}
+	}
+}
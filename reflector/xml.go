@@ -0,0 +1,176 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reflector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// XMLObserver is an Observer that renders the visited object as indented
+// XML: structs and maps become elements named after their fields and keys,
+// lists become repeated elements named after the list itself. Struct tags
+// (by default the "xml" tag) can rename fields, skip them ("-"), omit them
+// when empty (",omitempty"), make them attributes of the enclosing element
+// (",attr") or its character data (",chardata"). Map keys that are not valid
+// XML names are rendered as <entry key="..."> elements. Nil values are left
+// out. The document is written out by Flush.
+type XMLObserver struct {
+	docBuilder
+	writer io.Writer
+	tag    string
+}
+
+// NewXMLObserver returns an XMLObserver writing to the given stream.
+func NewXMLObserver(writer io.Writer) *XMLObserver {
+	return &XMLObserver{
+		writer: writer,
+		tag:    "xml",
+	}
+}
+
+// SetTag sets the struct tag used to name fields and mark attributes.
+func (o *XMLObserver) SetTag(tag string) {
+	o.tag = tag
+}
+
+// Flush writes the XML document to the stream and then resets the observer.
+func (o *XMLObserver) Flush() error {
+	root := collapse(o.root)
+	o.docBuilder = docBuilder{}
+	if root == nil {
+		return nil
+	}
+	name := root.name
+	if !isXMLName(name) {
+		name = "root"
+		if root.value.IsValid() && isXMLName(root.value.Type().Name()) {
+			name = root.value.Type().Name()
+		}
+	}
+	buffer := &bytes.Buffer{}
+	if root.kind == docList {
+		// the elements of a top level list need an enclosing element
+		o.wrapper(buffer, name, root, 0)
+	} else {
+		o.element(buffer, name, "", root, 0)
+	}
+	_, err := buffer.WriteTo(o.writer)
+	return err
+}
+
+// element writes a node as an element with the given name; the attributes
+// string, if not empty, is written as is in the opening tag.
+func (o *XMLObserver) element(buffer *bytes.Buffer, name string, attributes string, node *docNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch node.kind {
+	case docNil:
+		return
+	case docScalar:
+		fmt.Fprintf(buffer, "%s<%s%s>%s</%s>\n", indent, name, attributes, xmlEscape(xmlText(node.value)), name)
+	case docList:
+		for _, child := range node.children {
+			if child.kind == docList {
+				o.wrapper(buffer, name, child, depth)
+			} else {
+				o.element(buffer, name, attributes, child, depth)
+			}
+		}
+	case docStruct, docMap:
+		text := ""
+		children := []docField{}
+		for _, field := range node.fields(o.tag) {
+			switch {
+			case node.kind == docMap && !isXMLName(field.key):
+				children = append(children, field)
+			case strings.Contains(field.options, ",attr"):
+				if field.node.kind == docScalar {
+					attributes += fmt.Sprintf(" %s=\"%s\"", field.key, xmlEscape(xmlText(field.node.value)))
+				}
+			case strings.Contains(field.options, ",chardata"):
+				if field.node.kind == docScalar {
+					text += xmlText(field.node.value)
+				}
+			default:
+				children = append(children, field)
+			}
+		}
+		if len(children) == 0 {
+			fmt.Fprintf(buffer, "%s<%s%s>%s</%s>\n", indent, name, attributes, xmlEscape(text), name)
+			return
+		}
+		fmt.Fprintf(buffer, "%s<%s%s>%s\n", indent, name, attributes, xmlEscape(text))
+		for _, child := range children {
+			if isXMLName(child.key) {
+				o.element(buffer, child.key, "", child.node, depth+1)
+			} else {
+				o.element(buffer, "entry", fmt.Sprintf(" key=\"%s\"", xmlEscape(child.key)), child.node, depth+1)
+			}
+		}
+		fmt.Fprintf(buffer, "%s</%s>\n", indent, name)
+	}
+}
+
+// wrapper writes a list as an element with the given name, enclosing one
+// <item> element per list element.
+func (o *XMLObserver) wrapper(buffer *bytes.Buffer, name string, node *docNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(buffer, "%s<%s>\n", indent, name)
+	o.element(buffer, "item", "", node, depth+1)
+	fmt.Fprintf(buffer, "%s</%s>\n", indent, name)
+}
+
+// xmlText returns the textual representation of a scalar value.
+func xmlText(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return strconv.FormatFloat(f, 'g', -1, value.Type().Bits())
+	case reflect.Struct:
+		if value.Type() == timeType && value.CanInterface() {
+			return value.Interface().(time.Time).Format(time.RFC3339Nano)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// xmlEscape escapes a string for use in character data and attributes.
+func xmlEscape(s string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
+// isXMLName returns whether the string is a valid XML element name.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'))) {
+			return false
+		}
+	}
+	return true
+}